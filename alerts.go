@@ -0,0 +1,116 @@
+package sego
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+// SavedQuery is run against the documents every indexing run adds or
+// changes, matches are reported as alerts
+type SavedQuery struct {
+	Name    string  `json:"name"`
+	Query   string  `json:"query"`
+	MinRank float64 `json:"min_rank"`
+	// alerts are POSTed here as JSON besides being printed
+	Webhook string `json:"webhook,omitempty"`
+}
+
+// Alert says a new or changed document matched a saved query
+type Alert struct {
+	Query string    `json:"query"`
+	Path  string    `json:"path"`
+	Title string    `json:"title,omitempty"`
+	Rank  float64   `json:"rank"`
+	Time  time.Time `json:"time"`
+}
+
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// docHashes maps the path of every document to its content hash, to find the
+// changes of an indexing run with changedSince
+func (m *Model) docHashes() map[string]string {
+	hashes := make(map[string]string)
+	for _, id := range m.docIDs() {
+		if doc, ok := m.Docs[id]; ok {
+			hashes[doc.Path] = doc.Hash
+		}
+	}
+	return hashes
+}
+
+// changedSince returns the IDs of documents that are new or have different
+// content than in before
+func (m *Model) changedSince(before map[string]string) map[string]bool {
+	changed := make(map[string]bool)
+	for _, id := range m.docIDs() {
+		if doc, ok := m.Docs[id]; ok {
+			if hash, existed := before[doc.Path]; !existed || hash != doc.Hash {
+				changed[id] = true
+			}
+		}
+	}
+	return changed
+}
+
+// alert runs the saved queries and reports the changed documents matching
+// them with at least their MinRank
+func (m *Model) alert(queries []SavedQuery, changed map[string]bool) {
+	for _, a := range m.matchAlerts(queries, changed) {
+		notify(a.query, a.alert)
+	}
+}
+
+// pendingAlert is an alert not sent yet and the saved query it is for
+type pendingAlert struct {
+	query SavedQuery
+	alert *Alert
+}
+
+// matchAlerts runs the saved queries and returns the alerts for the changed
+// documents matching them with at least their MinRank, to be sent once the
+// index isn't locked anymore
+func (m *Model) matchAlerts(queries []SavedQuery, changed map[string]bool) []pendingAlert {
+	if len(changed) == 0 {
+		return nil
+	}
+	now := time.Now()
+	alerts := make([]pendingAlert, 0)
+	for _, q := range queries {
+		for _, r := range m.search(q.Query) {
+			if !changed[r.ID] || r.Rank <= 0 || r.Rank < q.MinRank {
+				continue
+			}
+			name := q.Name
+			if name == "" {
+				name = q.Query
+			}
+			alerts = append(alerts, pendingAlert{q, &Alert{Query: name, Path: r.Path, Title: r.Title, Rank: r.Rank, Time: now}})
+		}
+	}
+	return alerts
+}
+
+func notify(q SavedQuery, alert *Alert) {
+	data, err := json.Marshal(alert)
+	if err != nil {
+		log.Printf("Alert for %s: %s", alert.Path, err)
+		return
+	}
+	os.Stdout.Write(append(data, '\n'))
+	if q.Webhook == "" {
+		return
+	}
+	resp, err := webhookClient.Post(q.Webhook, "application/json", bytes.NewReader(data))
+	if err != nil {
+		log.Printf("Alert webhook %s: %s", q.Webhook, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("Alert webhook %s: %s", q.Webhook, resp.Status)
+	}
+}
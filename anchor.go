@@ -0,0 +1,42 @@
+package sego
+
+// computeAnchors collects the terms of the anchor text of the links to every
+// crawled page from the links of all the others
+func (m *Model) computeAnchors() {
+	m.Anchors = nil
+	for _, links := range m.Links {
+		for _, l := range links {
+			tokens := tokenize(l.Text, m.Lexer)
+			if len(tokens) == 0 {
+				continue
+			}
+			if m.Anchors == nil {
+				m.Anchors = make(map[string]TermFreq)
+			}
+			terms, ok := m.Anchors[l.URL]
+			if !ok {
+				terms = make(TermFreq)
+				m.Anchors[l.URL] = terms
+			}
+			for _, token := range tokens {
+				terms[token]++
+			}
+		}
+	}
+}
+
+// anchorRank scores the anchor text of links to a page like its content,
+// weighted by AnchorBoost, so pages are found by what others call them even
+// when they don't use those words themselves
+func (m *Model) anchorRank(p string, weights []termWeight) float64 {
+	terms := m.Anchors[p]
+	if len(terms) == 0 {
+		return 0
+	}
+	length := sumTF(terms)
+	var rank float64
+	for _, w := range weights {
+		rank += calculateTF(w.Term, terms, length) * w.Weight
+	}
+	return float64(m.AnchorBoost) * rank
+}
@@ -0,0 +1,243 @@
+package sego
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Queries can be built as a tree of nodes instead of a string, so values
+// don't have to be escaped or quoted and clauses can nest:
+//
+//	index, err := sego.Open("index.json")
+//	...
+//	results, err := index.SearchAST(&sego.BoolNode{
+//		Must:    []sego.Node{&sego.TermNode{Term: "shader"}},
+//		Should:  []sego.Node{&sego.PhraseNode{Terms: []string{"uniform", "buffer"}}},
+//		MustNot: []sego.Node{&sego.FilterNode{Field: "tag", Value: "deprecated"}},
+//	})
+
+// Node is a clause of a query
+type Node interface {
+	// match returns the documents of docs the clause matches
+	match(m *Model, docs []string) (bitmap, error)
+	// terms are the analyzed terms the clause ranks documents by
+	terms(m *Model) []string
+}
+
+// TermNode matches documents containing a word, analyzed like query text
+type TermNode struct {
+	Term string
+	// match the terms starting with Term instead, like "shad*"
+	Prefix bool
+}
+
+// PhraseNode matches documents containing all its words. Positions aren't
+// indexed, so they may be apart or in another order.
+type PhraseNode struct {
+	Terms []string
+}
+
+// BoolNode combines clauses: documents have to match every Must clause and
+// no MustNot clause. Without Must clauses they have to match a Should clause,
+// otherwise Should clauses only add to the rank.
+type BoolNode struct {
+	Must    []Node
+	Should  []Node
+	MustNot []Node
+}
+
+// FilterNode matches documents by a field like lang, tag or version without
+// adding to their rank
+type FilterNode struct {
+	Field string
+	Value string
+}
+
+// PathNode matches documents by their path or URL with a glob, "**" matching
+// any number of directories. Globs not starting with "/" or a scheme match
+// the end of the path, like "old-docs/**".
+type PathNode struct {
+	Glob string
+}
+
+func (n *TermNode) terms(m *Model) []string {
+	if n.Prefix {
+		return m.expandPrefixes([]string{n.Term})
+	}
+	return m.analyzeQuery(n.Term, nil)
+}
+
+func (n *TermNode) match(m *Model, docs []string) (bitmap, error) {
+	if !n.Prefix {
+		// a word analyzed into several terms, like "deprecated-api", matches
+		// documents containing all of them
+		return m.requireTerms(docs, []string{n.Term}, nil, fullBitmap(len(docs))), nil
+	}
+	tokens := n.terms(m)
+	matches := newBitmap(len(docs))
+	for i, id := range docs {
+		for _, token := range tokens {
+			if m.TF[id][token] > 0 {
+				matches.set(i)
+				break
+			}
+		}
+	}
+	return matches, nil
+}
+
+func (n *PhraseNode) terms(m *Model) []string {
+	tokens := make([]string, 0, len(n.Terms))
+	for _, term := range n.Terms {
+		tokens = append(tokens, m.analyzeQuery(term, nil)...)
+	}
+	return tokens
+}
+
+func (n *PhraseNode) match(m *Model, docs []string) (bitmap, error) {
+	return m.requireTerms(docs, n.Terms, nil, fullBitmap(len(docs))), nil
+}
+
+func (n *FilterNode) terms(m *Model) []string {
+	return nil
+}
+
+func (n *FilterNode) match(m *Model, docs []string) (bitmap, error) {
+	filter := Filter{Field: strings.ToLower(n.Field), Value: strings.ToLower(n.Value)}
+	if _, ok := filterFields[filter.Field]; !ok {
+		return nil, fmt.Errorf("unknown filter field %q", n.Field)
+	}
+	return m.filterBitmap(docs, []Filter{filter}), nil
+}
+
+func (n *PathNode) terms(m *Model) []string {
+	return nil
+}
+
+func (n *PathNode) match(m *Model, docs []string) (bitmap, error) {
+	glob := n.Glob
+	if !strings.HasPrefix(glob, "/") && !strings.Contains(glob, "://") {
+		glob = "**/" + glob
+	}
+	re, err := globToRegexp(glob)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path glob %q: %w", n.Glob, err)
+	}
+	matches := newBitmap(len(docs))
+	for i, id := range docs {
+		if re.MatchString(m.docPath(id)) {
+			matches.set(i)
+		}
+	}
+	return matches, nil
+}
+
+// terms are those of the Must and Should clauses, what documents must not
+// contain doesn't rank the others
+func (n *BoolNode) terms(m *Model) []string {
+	tokens := make([]string, 0)
+	for _, clause := range n.Must {
+		tokens = append(tokens, clause.terms(m)...)
+	}
+	for _, clause := range n.Should {
+		tokens = append(tokens, clause.terms(m)...)
+	}
+	return tokens
+}
+
+func (n *BoolNode) match(m *Model, docs []string) (bitmap, error) {
+	matches := fullBitmap(len(docs))
+	for _, clause := range n.Must {
+		b, err := clause.match(m, docs)
+		if err != nil {
+			return nil, err
+		}
+		matches.and(b)
+	}
+	if len(n.Should) > 0 {
+		should := newBitmap(len(docs))
+		for _, clause := range n.Should {
+			b, err := clause.match(m, docs)
+			if err != nil {
+				return nil, err
+			}
+			should.or(b)
+		}
+		if len(n.Must) == 0 {
+			matches.and(should)
+		}
+	}
+	for _, clause := range n.MustNot {
+		b, err := clause.match(m, docs)
+		if err != nil {
+			return nil, err
+		}
+		matches.andNot(b)
+	}
+	return matches, nil
+}
+
+// versionFilters are the version filters of a query tree outside MustNot
+// clauses, which decide whether it searches only the newest versions
+func versionFilters(node Node) []Filter {
+	switch n := node.(type) {
+	case *FilterNode:
+		if strings.EqualFold(n.Field, "version") {
+			return []Filter{{Field: "version", Value: strings.ToLower(n.Value)}}
+		}
+	case *BoolNode:
+		filters := make([]Filter, 0)
+		for _, clause := range append(append([]Node(nil), n.Must...), n.Should...) {
+			filters = append(filters, versionFilters(clause)...)
+		}
+		return filters
+	}
+	return nil
+}
+
+// SearchAST ranks the documents a query tree matches like a query string,
+// by its terms outside MustNot clauses
+func (m *Model) SearchAST(query Node) (SearchResults, error) {
+	results, _, err := m.searchASTTimed(query)
+	return results, err
+}
+
+func (m *Model) searchASTTimed(query Node) (SearchResults, *SearchTiming, error) {
+	timing := &SearchTiming{}
+	start := time.Now()
+	lap := func(d *time.Duration) {
+		now := time.Now()
+		*d = now.Sub(start)
+		start = now
+	}
+
+	terms := query.terms(m)
+	lap(&timing.Tokenize)
+
+	docs := m.docIDs()
+	allowed, err := query.match(m, docs)
+	if err != nil {
+		return nil, nil, err
+	}
+	allowed = m.latestVersions(docs, versionFilters(query), allowed)
+	n := m.corpus.size(len(docs))
+	terms = m.dropStopwords(terms, n)
+	plan := &searchPlan{
+		docs:        docs,
+		allowed:     allowed,
+		tokens:      terms,
+		weights:     m.queryWeights(terms, n),
+		gramWeights: m.gramWeights(terms, n),
+		now:         time.Now(),
+	}
+	lap(&timing.Candidates)
+
+	results := m.scoreShards(plan)
+	lap(&timing.Score)
+	sort.Sort(results)
+	results = m.foldChunks(results)
+	lap(&timing.Sort)
+	return results, timing, nil
+}
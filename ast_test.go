@@ -0,0 +1,65 @@
+package sego
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestSearchAST(t *testing.T) {
+	config := newConfig()
+	m := newModel()
+	for path, content := range map[string]string{
+		"a.txt":     "vertex shader uniforms",
+		"b.txt":     "fragment shader uniform buffer",
+		"old/c.txt": "vertex shader",
+	} {
+		if err := m.apply(&IngestMessage{Path: path, Content: content}, config); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "index.json")
+	if err := m.saveAsJson(path); err != nil {
+		t.Fatal(err)
+	}
+	index, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		query Node
+		want  []string
+	}{
+		{"term", &TermNode{Term: "vertex"}, []string{"a.txt", "old/c.txt"}},
+		{"prefix", &TermNode{Term: "frag", Prefix: true}, []string{"b.txt"}},
+		{"phrase", &PhraseNode{Terms: []string{"uniform", "buffer"}}, []string{"b.txt"}},
+		{"must not", &BoolNode{
+			Must:    []Node{&TermNode{Term: "shader"}},
+			MustNot: []Node{&PathNode{Glob: "old/**"}},
+		}, []string{"a.txt", "b.txt"}},
+		{"should", &BoolNode{
+			Should: []Node{&TermNode{Term: "fragment"}, &PathNode{Glob: "old/**"}},
+		}, []string{"b.txt", "old/c.txt"}},
+	}
+	for _, test := range tests {
+		results, err := index.SearchAST(test.query)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		got := make([]string, 0)
+		for _, r := range results {
+			got = append(got, r.Path)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s matches %v, want %v", test.name, got, test.want)
+		}
+	}
+
+	if _, err := index.SearchAST(&FilterNode{Field: "color", Value: "red"}); err == nil {
+		t.Error("unknown filter field searched")
+	}
+}
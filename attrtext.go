@@ -0,0 +1,76 @@
+package sego
+
+import (
+	"bytes"
+	"strings"
+
+	xhtml "golang.org/x/net/html"
+)
+
+// attributes whose text describes what an element shows, for pages that say
+// more in images and icons than in their text
+var textAttributes = map[string]bool{"alt": true, "title": true, "aria-label": true}
+
+// attributeText collects the alt text, titles and ARIA labels of the elements
+// of an HTML page
+func attributeText(content []byte) string {
+	var text strings.Builder
+	tokenizer := xhtml.NewTokenizer(bytes.NewReader(content))
+	for {
+		switch tokenizer.Next() {
+		case xhtml.ErrorToken:
+			return text.String()
+		case xhtml.StartTagToken, xhtml.SelfClosingTagToken:
+			for {
+				key, value, more := tokenizer.TagAttr()
+				if textAttributes[string(key)] && len(value) > 0 {
+					text.Write(value)
+					text.WriteByte('\n')
+				}
+				if !more {
+					break
+				}
+			}
+		}
+	}
+}
+
+// indexAttributes keeps the terms of the attribute text of the HTML document
+// id apart from its content, they count AttrBoost times as much
+func (m *Model) indexAttributes(id string, content []byte, opts LexerOptions, config *Config) {
+	delete(m.AttrText, id)
+	if config.AttributeBoost <= 0 {
+		return
+	}
+	m.AttrBoost = config.AttributeBoost
+	if opts.Markup != MarkupHTML && !looksLikeHTML(content) {
+		return
+	}
+	tokens := tokenize(attributeText(content), opts)
+	if len(tokens) == 0 {
+		return
+	}
+	tf := make(TermFreq)
+	for _, token := range tokens {
+		tf[token]++
+	}
+	if m.AttrText == nil {
+		m.AttrText = make(map[string]TermFreq)
+	}
+	m.AttrText[id] = tf
+}
+
+// attributeRank scores the attribute text of a document like its content,
+// weighted by AttrBoost
+func (m *Model) attributeRank(id string, weights []termWeight) float64 {
+	terms := m.AttrText[id]
+	if len(terms) == 0 {
+		return 0
+	}
+	length := sumTF(terms)
+	var rank float64
+	for _, w := range weights {
+		rank += calculateTF(w.Term, terms, length) * w.Weight
+	}
+	return float64(m.AttrBoost) * rank
+}
@@ -0,0 +1,106 @@
+package sego
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// most queries one batch request may have
+const maxBatchQueries = 1000
+
+// SearchBatch runs queries on up to the model's query threads at once, the
+// documents are listed once for all of them
+func (m *Model) SearchBatch(queries []string) []SearchResults {
+	results := make([]SearchResults, len(queries))
+	docs := m.docIDs()
+	// queries already run side by side
+	reader := *m
+	reader.threads = 1
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < queryThreads(m.threads) && i < len(queries); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i], _ = reader.searchDocs(queries[i], docs)
+			}
+		}()
+	}
+	for i := range queries {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return results
+}
+
+type batchRequest struct {
+	Queries []string `json:"queries"`
+	// results per query, 10 if 0
+	N int `json:"n"`
+	// result fields to return, all if empty
+	Fields []string `json:"fields"`
+}
+
+type batchResponse struct {
+	Responses []any `json:"responses"`
+}
+
+// handleSearchBatch answers many queries posted as {"queries": [...], "n": 10}
+// in one response, in the same order
+func (s *server) handleSearchBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		httpError(w, http.StatusMethodNotAllowed, fmt.Errorf("use POST"))
+		return
+	}
+	span := tracing.startRemote(r.Header.Get("traceparent"), "POST /search/batch")
+	defer span.finish()
+	var req batchRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		httpError(w, http.StatusBadRequest, err)
+		return
+	}
+	if len(req.Queries) > maxBatchQueries {
+		httpError(w, http.StatusBadRequest, fmt.Errorf("%d queries, at most %d are allowed", len(req.Queries), maxBatchQueries))
+		return
+	}
+	if req.N < 0 {
+		httpError(w, http.StatusBadRequest, fmt.Errorf("invalid n %d", req.N))
+		return
+	}
+	limit := req.N
+	if limit == 0 {
+		limit = 10
+	}
+	fields, err := checkFields(req.Fields)
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err)
+		return
+	}
+	span.set("sego.queries", len(req.Queries))
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	blocklist := s.blocklist.get()
+	response := batchResponse{Responses: make([]any, len(req.Queries))}
+	for i, results := range s.model.SearchBatch(req.Queries) {
+		results = s.model.collapseVersions(results)
+		results = s.model.pin(results, s.pins.match(req.Queries[i]))
+		results = blocklist.filter(results)
+		one := &searchResponse{Query: req.Queries[i], Total: len(results)}
+		if len(results) > limit {
+			results = results[:limit]
+		}
+		if wantsSnippets(fields) {
+			s.model.snippets(results, req.Queries[i], s.config)
+		}
+		one.Results = results
+		response.Responses[i] = one.respond(fields)
+	}
+	writeJson(w, http.StatusOK, response)
+}
@@ -0,0 +1,116 @@
+package sego
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+func readQueries(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	queries := make([]string, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if query := strings.TrimSpace(scanner.Text()); query != "" && !strings.HasPrefix(query, "#") {
+			queries = append(queries, query)
+		}
+	}
+	return queries, scanner.Err()
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(float64(len(sorted)-1) * p)
+	return sorted[i]
+}
+
+// bench runs every query repeat times spread over concurrency goroutines and
+// returns the latency of each search
+func (m *Model) bench(queries []string, concurrency int, repeat int) []time.Duration {
+	jobs := make(chan string)
+	latencies := make([]time.Duration, 0, len(queries)*repeat)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for query := range jobs {
+				start := time.Now()
+				m.search(query)
+				elapsed := time.Since(start)
+
+				mu.Lock()
+				latencies = append(latencies, elapsed)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for r := 0; r < repeat; r++ {
+		for _, query := range queries {
+			jobs <- query
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return latencies
+}
+
+func runBench(args []string) {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	indexPath := fs.String("index", "index-new.json", "index to search")
+	queriesPath := fs.String("queries", "", "file with one query per line")
+	concurrency := fs.Int("concurrency", 1, "number of searches running at the same time")
+	repeat := fs.Int("repeat", 1, "how often to replay the queries")
+	fs.Parse(args)
+	if *queriesPath == "" || *concurrency < 1 || *repeat < 1 {
+		log.Fatal("usage: sego bench -queries queries.txt [-concurrency n] [-repeat n]")
+	}
+
+	model, err := newModelFromJson(*indexPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	queries, err := readQueries(*queriesPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(queries) == 0 {
+		log.Fatalf("%s: no queries", *queriesPath)
+	}
+
+	start := time.Now()
+	latencies := model.bench(queries, *concurrency, *repeat)
+	elapsed := time.Since(start)
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	var sum time.Duration
+	for _, l := range latencies {
+		sum += l
+	}
+
+	fmt.Printf("queries:     %d (%d distinct, concurrency %d)\n", len(latencies), len(queries), *concurrency)
+	fmt.Printf("elapsed:     %s\n", elapsed)
+	fmt.Printf("qps:         %.1f\n", float64(len(latencies))/elapsed.Seconds())
+	fmt.Printf("latency avg: %s\n", sum/time.Duration(len(latencies)))
+	fmt.Printf("latency p50: %s\n", percentile(latencies, 0.50))
+	fmt.Printf("latency p90: %s\n", percentile(latencies, 0.90))
+	fmt.Printf("latency p99: %s\n", percentile(latencies, 0.99))
+	fmt.Printf("latency max: %s\n", latencies[len(latencies)-1])
+}
@@ -0,0 +1,43 @@
+package sego
+
+// bitmap is a set of document numbers
+type bitmap []uint64
+
+func newBitmap(n int) bitmap {
+	return make(bitmap, (n+63)/64)
+}
+
+func (b bitmap) set(i int) {
+	b[i/64] |= 1 << (i % 64)
+}
+
+func (b bitmap) has(i int) bool {
+	return b[i/64]&(1<<(i%64)) != 0
+}
+
+func (b bitmap) and(other bitmap) {
+	for i := range b {
+		b[i] &= other[i]
+	}
+}
+
+func (b bitmap) or(other bitmap) {
+	for i := range b {
+		b[i] |= other[i]
+	}
+}
+
+func (b bitmap) andNot(other bitmap) {
+	for i := range b {
+		b[i] &^= other[i]
+	}
+}
+
+// fullBitmap has the numbers 0..n-1
+func fullBitmap(n int) bitmap {
+	b := newBitmap(n)
+	for i := 0; i < n; i++ {
+		b.set(i)
+	}
+	return b
+}
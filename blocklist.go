@@ -0,0 +1,94 @@
+package sego
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Blocklist holds patterns of paths or URLs hidden from results, a pattern
+// matching a directory like "docs/v1" or "https://example.com/old" hides
+// everything below it
+type Blocklist []string
+
+func loadBlocklist(file string) (Blocklist, error) {
+	patterns, err := readWordList(file)
+	if err != nil {
+		return nil, err
+	}
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("%s: pattern %q: %w", file, pattern, err)
+		}
+	}
+	return Blocklist(patterns), nil
+}
+
+// blocked reports whether p, its name or one of the directories above it
+// matches a pattern
+func (b Blocklist) blocked(p string) bool {
+	for _, pattern := range b {
+		if ok, _ := path.Match(pattern, path.Base(p)); ok {
+			return true
+		}
+		for i := len(p); i > 0; i = strings.LastIndexByte(p[:i], '/') {
+			if ok, _ := path.Match(pattern, p[:i]); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// filter leaves out the blocked results
+func (b Blocklist) filter(results SearchResults) SearchResults {
+	if len(b) == 0 {
+		return results
+	}
+	kept := make(SearchResults, 0, len(results))
+	for _, r := range results {
+		if !b.blocked(r.Path) {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
+
+// blocklistFile reads a blocklist again whenever it changes, so documents are
+// hidden without restarting the server
+type blocklistFile struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	list    Blocklist
+}
+
+// get returns the current blocklist, the previous one if the file can't be read
+func (f *blocklistFile) get() Blocklist {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	info, err := os.Stat(f.path)
+	if err != nil {
+		log.Printf("Blocklist: %s", err)
+		return f.list
+	}
+	if info.ModTime().Equal(f.modTime) {
+		return f.list
+	}
+	list, err := loadBlocklist(f.path)
+	if err != nil {
+		log.Printf("Blocklist: %s", err)
+		return f.list
+	}
+	f.list = list
+	f.modTime = info.ModTime()
+	return f.list
+}
@@ -0,0 +1,127 @@
+package sego
+
+import (
+	"bytes"
+	"strings"
+
+	xhtml "golang.org/x/net/html"
+)
+
+// elements around the content of a page rather than part of it
+var boilerplateTags = map[string]bool{"nav": true, "header": true, "footer": true, "aside": true}
+
+// ARIA roles of such elements
+var boilerplateRoles = map[string]bool{
+	"navigation": true, "banner": true, "contentinfo": true, "complementary": true, "search": true,
+}
+
+// words in the classes and IDs of such elements, like "site-nav" or
+// "sidebar_left". Not "header", page headers like div.page-header hold the
+// title.
+var boilerplateWords = map[string]bool{
+	"nav": true, "navbar": true, "navigation": true, "menu": true, "sidebar": true,
+	"breadcrumb": true, "breadcrumbs": true, "footer": true,
+	"cookie": true, "cookies": true, "share": true, "social": true, "masthead": true,
+}
+
+// elements the words in classes and IDs can mark as such, those holding the
+// parts around the content, never text like h1.page-header or a.share
+var boilerplateContainers = map[string]bool{
+	"div": true, "section": true, "ul": true, "ol": true, "form": true,
+}
+
+// isContent reports whether n is where a page has its content, the
+// heuristics leave it and what is in it alone
+func isContent(n *xhtml.Node) bool {
+	return n.Data == "main" || n.Data == "article" || attr(n, "role") == "main"
+}
+
+func isBoilerplate(n *xhtml.Node) bool {
+	if boilerplateTags[n.Data] || boilerplateRoles[attr(n, "role")] {
+		return true
+	}
+	if !boilerplateContainers[n.Data] {
+		return false
+	}
+	for _, name := range strings.Fields(attr(n, "class") + " " + attr(n, "id")) {
+		for _, word := range strings.FieldsFunc(strings.ToLower(name), func(r rune) bool { return r == '-' || r == '_' }) {
+			if boilerplateWords[word] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// cleanHTML leaves out the parts of the HTML page at docPath that aren't its
+// content: all but what the content selector of its extract rule matches,
+// those matching the exclude selectors and, with StripBoilerplate, the
+// navigation, headers, footers and sidebars around its main content
+func (c *Config) cleanHTML(docPath string, content []byte) ([]byte, error) {
+	rule, err := c.extractRule(docPath)
+	if err != nil {
+		return nil, err
+	}
+	excludes := c.ExcludeSelectors
+	if rule != nil && rule.Exclude != "" {
+		excludes = append(excludes[:len(excludes):len(excludes)], rule.Exclude)
+	}
+	var exclude selector
+	for _, s := range excludes {
+		sel, err := cachedSelector(s)
+		if err != nil {
+			return nil, err
+		}
+		exclude = append(exclude[:len(exclude):len(exclude)], sel...)
+	}
+	// what the rule picks is the content, the heuristics needn't guess it
+	strip := c.StripBoilerplate
+	var picked selector
+	if rule != nil && rule.Content != "" {
+		if picked, err = cachedSelector(rule.Content); err != nil {
+			return nil, err
+		}
+		strip = false
+	}
+	if !strip && len(exclude) == 0 && picked == nil {
+		return content, nil
+	}
+
+	doc, err := xhtml.Parse(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	if picked != nil {
+		keepOnly(doc, picked)
+	}
+
+	removed := make([]*xhtml.Node, 0)
+	var walk func(n *xhtml.Node, inContent bool)
+	walk = func(n *xhtml.Node, inContent bool) {
+		if n.Type == xhtml.ElementNode {
+			if exclude.matches(n) || (strip && !inContent && isBoilerplate(n)) {
+				removed = append(removed, n)
+				return
+			}
+			inContent = inContent || isContent(n)
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child, inContent)
+		}
+	}
+	walk(doc, false)
+	for _, n := range removed {
+		n.Parent.RemoveChild(n)
+	}
+
+	var out bytes.Buffer
+	if err := xhtml.Render(&out, doc); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// cleansHTML reports whether HTML pages are cleaned before they are indexed
+func (c *Config) cleansHTML() bool {
+	return c.StripBoilerplate || len(c.ExcludeSelectors) > 0 || len(c.ExtractRules) > 0
+}
@@ -0,0 +1,35 @@
+package sego
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStripBoilerplate(t *testing.T) {
+	page := `<html><body>
+		<nav>site menu</nav>
+		<div class="page-header"><h1 class="page-header">Vertex Arrays</h1></div>
+		<div class="sidebar-left">related pages</div>
+		<ul id="main-menu"><li>home</li></ul>
+		<p class="share-note">Buffers are shared between contexts.</p>
+		<article><header>Article title</header><div class="social">quoted post</div></article>
+		<footer>copyright</footer>
+	</body></html>`
+	config := newConfig()
+	config.StripBoilerplate = true
+	cleaned, err := config.cleanHTML("page.html", []byte(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := string(cleaned)
+	for _, kept := range []string{"Vertex Arrays", "Buffers are shared", "Article title", "quoted post"} {
+		if !strings.Contains(text, kept) {
+			t.Errorf("%q was stripped", kept)
+		}
+	}
+	for _, stripped := range []string{"site menu", "related pages", "home", "copyright"} {
+		if strings.Contains(text, stripped) {
+			t.Errorf("%q was kept", stripped)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package sego
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// boost is the factor a document's rank is multiplied with for a query
+func (m *Model) boost(id string, tokens []string, now time.Time) float64 {
+	var boost float64 = 1
+	if doc, ok := m.Docs[id]; ok && doc.Boost > 0 {
+		boost *= float64(doc.Boost)
+	}
+	if m.Code != nil {
+		boost *= m.Code.nameBoost(m.docPath(id), tokens)
+	}
+	if m.PageRankWeight > 0 {
+		boost *= 1 + m.PageRankWeight*m.PageRank[m.docPath(id)]
+	}
+	if m.Quality != nil {
+		boost *= m.quality(id, now)
+	}
+	if b, ok := m.Boosts[m.docPath(id)]; ok {
+		boost *= b
+	}
+	return boost
+}
+
+// loadBoosts reads a JSON object of document paths or URLs and the factor
+// curators want their rank multiplied with, e.g. {"faq.html": 3, "old.html": 0.1}
+func loadBoosts(path string) (map[string]float64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var boosts map[string]float64
+	if err := json.Unmarshal(data, &boosts); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for p, b := range boosts {
+		if b < 0 {
+			return nil, fmt.Errorf("%s: negative boost %g for %s", path, b, p)
+		}
+	}
+	return boosts, nil
+}
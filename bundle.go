@@ -0,0 +1,144 @@
+package sego
+
+import (
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// a bundled binary ends with the index, its length and this
+const bundleMagic = "SEGOIDX1"
+
+const bundleTrailerSize = 8 + len(bundleMagic)
+
+// bundledIndex returns the index appended to the executable file at path,
+// ok is false for a plain sego binary. base is the size without the index.
+func bundledIndex(path string) (index []byte, base int64, ok bool, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, 0, false, err
+	}
+	size := info.Size()
+	if size < int64(bundleTrailerSize) {
+		return nil, size, false, nil
+	}
+	trailer := make([]byte, bundleTrailerSize)
+	if _, err := f.ReadAt(trailer, size-int64(bundleTrailerSize)); err != nil {
+		return nil, 0, false, err
+	}
+	if !bytes.Equal(trailer[8:], []byte(bundleMagic)) {
+		return nil, size, false, nil
+	}
+	n := int64(binary.LittleEndian.Uint64(trailer[:8]))
+	base = size - int64(bundleTrailerSize) - n
+	if n <= 0 || base < 0 {
+		return nil, 0, false, fmt.Errorf("%s: broken bundled index", path)
+	}
+	index = make([]byte, n)
+	if _, err := f.ReadAt(index, base); err != nil {
+		return nil, 0, false, err
+	}
+	return index, base, true, nil
+}
+
+// bundle writes a copy of the sego binary at base with index appended to out
+func bundle(base string, index []byte, out string) error {
+	_, size, _, err := bundledIndex(base)
+	if err != nil {
+		return err
+	}
+	in, err := os.Open(base)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	f, err := os.OpenFile(out, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0755)
+	if err != nil {
+		return err
+	}
+	// leave out what base has bundled itself
+	if _, err := io.Copy(f, io.LimitReader(in, size)); err != nil {
+		f.Close()
+		return err
+	}
+	trailer := binary.LittleEndian.AppendUint64(nil, uint64(len(index)))
+	trailer = append(trailer, bundleMagic...)
+	if _, err := f.Write(append(index, trailer...)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func runBundle(args []string) {
+	fs := flag.NewFlagSet("bundle", flag.ExitOnError)
+	indexPath := fs.String("index", "index-new.json", "index to bundle")
+	out := fs.String("o", "", "where to write the binary serving it")
+	base := fs.String("base", "", "sego binary to bundle the index with, e.g. one built for another platform (default this one)")
+	fs.Parse(args)
+	if *out == "" || fs.NArg() > 0 {
+		log.Fatal("usage: sego bundle [-index index.json] [-base sego] -o <binary>")
+	}
+	if *base == "" {
+		exe, err := os.Executable()
+		if err != nil {
+			log.Fatal(err)
+		}
+		*base = exe
+	}
+
+	data, err := os.ReadFile(*indexPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// check that it loads before shipping it
+	if _, err := parseModel(data); err != nil {
+		log.Fatalf("%s: %s", *indexPath, err)
+	}
+	if err := bundle(*base, data, *out); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Wrote %s serving %s", *out, *indexPath)
+}
+
+// runBundled serves the index bundled with the binary, it takes just the
+// address to listen on, by default from $PORT
+func runBundled(index []byte, args []string) {
+	fs := flag.NewFlagSet("bundled", flag.ExitOnError)
+	addr := ":8080"
+	if port := os.Getenv("PORT"); port != "" {
+		addr = ":" + port
+	}
+	fs.StringVar(&addr, "addr", addr, "address to listen on")
+	lameDuck := fs.Duration("lame-duck", 5*time.Second, "how long to keep serving while reporting not ready on shutdown")
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		log.Fatal("usage: [PORT=8080] <bundled binary> [-addr :8080] [-lame-duck 5s]")
+	}
+
+	model, err := parseModel(index)
+	if err != nil {
+		log.Fatal(err)
+	}
+	s := &server{model: model, config: newConfig(), mu: &sync.RWMutex{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/search", s.handleSearch)
+	mux.HandleFunc("/livez", s.handleLivez)
+	mux.HandleFunc("/readyz", s.handleReadyz)
+	log.Printf("Serving %d documents on %s", len(model.docIDs()), addr)
+	if err := s.listen(addr, mux, *lameDuck); err != nil {
+		log.Fatal(err)
+	}
+}
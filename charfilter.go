@@ -0,0 +1,67 @@
+package sego
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/runenames"
+)
+
+type EmojiPolicy string
+
+const (
+	// "👍" => "👍"
+	EmojiKeep EmojiPolicy = "keep"
+	// "great 👍" => "GREAT"
+	EmojiDrop EmojiPolicy = "drop"
+	// "👍" => "THUMBS", "UP", "SIGN"
+	EmojiNames EmojiPolicy = "names"
+)
+
+// filterChars cleans up text before it is split into tokens: zero-width
+// characters and soft hyphens no longer break up words, control characters
+// separate them and emoji are kept, dropped or spelled out
+func (o LexerOptions) filterChars(text string) string {
+	if !o.StripInvisible && (o.Emoji == "" || o.Emoji == EmojiKeep) {
+		return text
+	}
+	var b strings.Builder
+	b.Grow(len(text))
+	for _, r := range text {
+		switch {
+		case o.Emoji != "" && o.Emoji != EmojiKeep && isEmoji(r):
+			if o.Emoji == EmojiNames && !isEmojiModifier(r) {
+				b.WriteByte(' ')
+				b.WriteString(runenames.Name(r))
+				b.WriteByte(' ')
+			}
+		case !o.StripInvisible:
+			b.WriteRune(r)
+		case r == '\u200b':
+			// a zero-width space still separates words
+			b.WriteByte(' ')
+		case unicode.Is(unicode.Cf, r), unicode.Is(unicode.Variation_Selector, r):
+		case unicode.IsControl(r):
+			b.WriteByte(' ')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// isEmoji reports whether r is a pictograph or one of the characters emoji
+// are composed of
+func isEmoji(r rune) bool {
+	switch {
+	case r >= 0x1f000 && r <= 0x1faff, r >= 0x2600 && r <= 0x27bf, r >= 0x2b00 && r <= 0x2bff:
+		return unicode.Is(unicode.So, r) || isEmojiModifier(r)
+	}
+	return isEmojiModifier(r)
+}
+
+// isEmojiModifier reports whether r only changes the emoji before it, like
+// skin tones, joiners, variation selectors and tags
+func isEmojiModifier(r rune) bool {
+	return r >= 0x1f3fb && r <= 0x1f3ff || r == '\u200d' || r == '\ufe0f' || r >= 0xe0020 && r <= 0xe007f
+}
@@ -0,0 +1,127 @@
+package sego
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+)
+
+// check verifies that the derived parts of the index agree with the term
+// frequencies and returns a description of every problem found
+func (m *Model) check() []string {
+	problems := make([]string, 0)
+
+	df := make(DocFreq)
+	for id, tf := range m.TF {
+		path := m.docPath(id)
+		for term, n := range tf {
+			if n <= 0 {
+				problems = append(problems, fmt.Sprintf("%s: empty posting for %q", path, term))
+				continue
+			}
+			df[term]++
+		}
+
+		doc, ok := m.Docs[id]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s: no document entry", path))
+		} else if length := sumTF(tf); doc.Length != length && !(m.Pruned && doc.Length > length) {
+			problems = append(problems, fmt.Sprintf("%s: length is %d, terms add up to %d", path, doc.Length, length))
+		}
+	}
+
+	for term, n := range m.DF {
+		if df[term] != n {
+			problems = append(problems, fmt.Sprintf("DF of %q is %d, found in %d documents", term, n, df[term]))
+		}
+	}
+	for term, n := range df {
+		if _, ok := m.DF[term]; !ok {
+			problems = append(problems, fmt.Sprintf("DF of %q is missing, found in %d documents", term, n))
+		}
+	}
+
+	for id := range m.Docs {
+		if _, ok := m.TF[id]; !ok {
+			problems = append(problems, fmt.Sprintf("%s: document entry without terms", m.docPath(id)))
+		}
+	}
+
+	sort.Strings(problems)
+	return problems
+}
+
+// repair recomputes everything check verifies from the term frequencies
+func (m *Model) repair() {
+	if m.Docs == nil {
+		m.Docs = make(map[string]*Document)
+	}
+
+	m.DF = make(DocFreq)
+	for id, tf := range m.TF {
+		for term, n := range tf {
+			if n <= 0 {
+				delete(tf, term)
+				continue
+			}
+			m.DF[term]++
+		}
+
+		doc, ok := m.Docs[id]
+		if !ok {
+			doc = &Document{Path: id}
+			m.Docs[id] = doc
+		}
+		// pruned documents keep their lengths
+		if length := sumTF(tf); doc.Length < length || !m.Pruned {
+			doc.Length = length
+		}
+	}
+
+	for id, doc := range m.Docs {
+		if _, ok := m.TF[id]; !ok {
+			if m.paths[doc.Path] == id {
+				delete(m.paths, doc.Path)
+			}
+			delete(m.Docs, id)
+			delete(m.Vectors, id)
+			m.removeGrams(id)
+		}
+	}
+}
+
+func runCheck(args []string) {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	repair := fs.Bool("repair", false, "fix the problems found and write the index back")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		log.Fatal("usage: sego check [-repair] <index>")
+	}
+
+	model, err := newModelFromJson(fs.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	problems := model.check()
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+	if len(problems) == 0 {
+		log.Printf("%s: ok", fs.Arg(0))
+		return
+	}
+
+	if !*repair {
+		log.Printf("%s: %d problems", fs.Arg(0), len(problems))
+		os.Exit(1)
+	}
+
+	model.repair()
+	if err := model.saveAsJson(fs.Arg(0)); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("%s: repaired %d problems", fs.Arg(0), len(problems))
+}
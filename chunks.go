@@ -0,0 +1,111 @@
+package sego
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseChunks parses "size:overlap" or "size" into words per chunk and words
+// chunks share with the one before
+func (c *Config) parseChunks(s string) error {
+	size, overlap, _ := strings.Cut(s, ":")
+	n, err := strconv.Atoi(size)
+	if err != nil || n <= 0 {
+		return fmt.Errorf("chunk size %q is not a positive number", size)
+	}
+	o := 0
+	if overlap != "" {
+		if o, err = strconv.Atoi(overlap); err != nil || o < 0 || o >= n {
+			return fmt.Errorf("chunk overlap %q is not a number from 0 to below the size", overlap)
+		}
+	}
+	c.ChunkSize, c.ChunkOverlap = n, o
+	return nil
+}
+
+// splitChunks splits text into windows of size words, each starting overlap
+// words before the end of the one before
+func splitChunks(text string, size, overlap int) []string {
+	words := strings.Fields(text)
+	if len(words) <= size {
+		return nil
+	}
+	chunks := make([]string, 0, len(words)/(size-overlap)+1)
+	for start := 0; ; start += size - overlap {
+		end := start + size
+		if end >= len(words) {
+			chunks = append(chunks, strings.Join(words[start:], " "))
+			return chunks
+		}
+		chunks = append(chunks, strings.Join(words[start:end], " "))
+	}
+}
+
+// pageText is the text of a page to split into chunks, escaped for HTML
+// pages so it can be analyzed as markup like the page
+func pageText(content []byte, isHTML bool) string {
+	if !isHTML {
+		return string(content)
+	}
+	sections := splitHTMLSections(content, 0)
+	if len(sections) == 0 {
+		return ""
+	}
+	return sections[0].body.String()
+}
+
+// indexChunks indexes the chunks of the page d, each under the path of the
+// page with its number, and removes those it no longer has
+func (m *Model) indexChunks(d *extracted, chunks []string, sizeLimited string, config *Config, stats *IndexStats) error {
+	page := extractMetadata(d.content)
+	page.merge(d.meta)
+	keep := make(map[string]bool, len(chunks))
+	for i, chunk := range chunks {
+		sub := *d
+		sub.parent = d.path
+		sub.chunk = i + 1
+		sub.path = fmt.Sprintf("%s#chunk-%d", d.path, sub.chunk)
+		sub.content = []byte(chunk)
+		meta := *page
+		sub.meta = &meta
+		keep[sub.path] = true
+		if err := m.indexExtracted(&sub, sizeLimited, config, stats); err != nil {
+			return err
+		}
+	}
+	m.removeSections(d.path, keep)
+	return nil
+}
+
+// foldChunks replaces the chunks of a document among sorted results by the
+// document, ranked as its best chunk
+func (m *Model) foldChunks(results SearchResults) SearchResults {
+	if m.ChunkSize == 0 {
+		return results
+	}
+	folded := results[:0]
+	seen := make(map[string]bool)
+	for _, r := range results {
+		if m.foldChunk(&r, seen) {
+			folded = append(folded, r)
+		}
+	}
+	return folded
+}
+
+// foldChunk turns the result for a chunk into one for its document, and
+// reports whether it is the first for the document, results coming best
+// first
+func (m *Model) foldChunk(r *SearchResult, seen map[string]bool) bool {
+	doc, ok := m.Docs[r.ID]
+	if !ok || doc.Chunk == 0 {
+		return true
+	}
+	if seen[doc.Parent] {
+		return false
+	}
+	seen[doc.Parent] = true
+	r.Path, r.Parent = doc.Parent, ""
+	return true
+}
@@ -0,0 +1,25 @@
+package sego
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitChunks(t *testing.T) {
+	tests := []struct {
+		text          string
+		size, overlap int
+		want          []string
+	}{
+		{"a b c", 3, 0, nil},
+		{"a b c d e", 2, 0, []string{"a b", "c d", "e"}},
+		{"a b c d e", 3, 1, []string{"a b c", "c d e"}},
+		{"a b c d e f", 4, 2, []string{"a b c d", "c d e f"}},
+		{"a  b\nc\td", 3, 2, []string{"a b c", "b c d"}},
+	}
+	for _, test := range tests {
+		if got := splitChunks(test.text, test.size, test.overlap); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q by %d:%d split into %q, want %q", test.text, test.size, test.overlap, got, test.want)
+		}
+	}
+}
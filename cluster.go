@@ -0,0 +1,165 @@
+package sego
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// Cluster is a group of similar search results
+type Cluster struct {
+	// the terms that best describe the group
+	Label   string        `json:"label"`
+	Terms   []string      `json:"terms"`
+	Results SearchResults `json:"results"`
+}
+
+type sparseVector map[string]float64
+
+func (v sparseVector) normalize() {
+	var norm float64
+	for _, x := range v {
+		norm += x * x
+	}
+	norm = math.Sqrt(norm)
+	if norm == 0 {
+		return
+	}
+	for term := range v {
+		v[term] /= norm
+	}
+}
+
+func (v sparseVector) dot(other sparseVector) float64 {
+	if len(other) < len(v) {
+		v, other = other, v
+	}
+	var sum float64
+	for term, x := range v {
+		sum += x * other[term]
+	}
+	return sum
+}
+
+// tfidfVector is the unit length TF-IDF vector of a document
+func (m *Model) tfidfVector(id string, n int) sparseVector {
+	v := make(sparseVector)
+	length := m.docLength(id)
+	for term := range m.TF[id] {
+		if !isWordToken(term) {
+			continue
+		}
+		v[term] = calculateTF(term, m.TF[id], length) * calculateIDF(m.DF[term], n, IDFSmooth)
+	}
+	v.normalize()
+	return v
+}
+
+// cluster groups results into at most k clusters by spherical k-means over
+// their TF-IDF vectors. Seeds are picked farthest-first starting with the best
+// result so the same results always cluster the same way.
+func (m *Model) cluster(results SearchResults, k int) []Cluster {
+	if len(results) == 0 || k <= 0 {
+		return nil
+	}
+	if k > len(results) {
+		k = len(results)
+	}
+	n := len(m.docIDs())
+	vectors := make([]sparseVector, len(results))
+	for i, r := range results {
+		vectors[i] = m.tfidfVector(r.ID, n)
+	}
+
+	centroids := []sparseVector{vectors[0]}
+	for len(centroids) < k {
+		farthest, distance := -1, -1.0
+		for i, v := range vectors {
+			closest := 0.0
+			for _, c := range centroids {
+				closest = math.Max(closest, v.dot(c))
+			}
+			if d := 1 - closest; d > distance {
+				farthest, distance = i, d
+			}
+		}
+		// the rest are duplicates of the seeds
+		if distance <= 0 {
+			break
+		}
+		centroids = append(centroids, vectors[farthest])
+	}
+
+	assignment := make([]int, len(vectors))
+	for iteration := 0; iteration < 20; iteration++ {
+		changed := false
+		for i, v := range vectors {
+			best, similarity := 0, -1.0
+			for c, centroid := range centroids {
+				if s := v.dot(centroid); s > similarity {
+					best, similarity = c, s
+				}
+			}
+			if assignment[i] != best {
+				assignment[i] = best
+				changed = true
+			}
+		}
+		if !changed && iteration > 0 {
+			break
+		}
+		for c := range centroids {
+			centroid := make(sparseVector)
+			for i, v := range vectors {
+				if assignment[i] != c {
+					continue
+				}
+				for term, x := range v {
+					centroid[term] += x
+				}
+			}
+			centroid.normalize()
+			centroids[c] = centroid
+		}
+	}
+
+	clusters := make([]Cluster, len(centroids))
+	for i, r := range results {
+		c := &clusters[assignment[i]]
+		c.Results = append(c.Results, r)
+	}
+	for c := range clusters {
+		clusters[c].Terms = topVectorTerms(centroids[c], 3)
+		clusters[c].Label = strings.ToLower(strings.Join(clusters[c].Terms, " "))
+	}
+
+	// results are sorted, so the cluster with the best result comes first
+	// when ordered by their first result
+	nonEmpty := clusters[:0]
+	for _, c := range clusters {
+		if len(c.Results) > 0 {
+			nonEmpty = append(nonEmpty, c)
+		}
+	}
+	sort.Slice(nonEmpty, func(i, j int) bool {
+		return SearchResults{nonEmpty[i].Results[0], nonEmpty[j].Results[0]}.Less(0, 1)
+	})
+	return nonEmpty
+}
+
+func topVectorTerms(v sparseVector, n int) []string {
+	terms := make([]string, 0, len(v))
+	for term := range v {
+		terms = append(terms, term)
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if v[terms[i]] != v[terms[j]] {
+			return v[terms[i]] > v[terms[j]]
+		}
+		return terms[i] < terms[j]
+	})
+	if len(terms) > n {
+		terms = terms[:n]
+	}
+	return terms
+}
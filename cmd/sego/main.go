@@ -0,0 +1,9 @@
+// Command sego indexes and searches documents, the index itself can be
+// used as a library by importing github.com/ecrax/sego
+package main
+
+import "github.com/ecrax/sego"
+
+func main() {
+	sego.Main()
+}
@@ -0,0 +1,85 @@
+package sego
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path"
+	"strings"
+)
+
+// CodeOptions tune ranking for source code repositories
+type CodeOptions struct {
+	// up to how much more a document counts when the query matches its file name
+	NameBoost float32 `json:"name_boost"`
+	// how much more README files count
+	ReadmeBoost float32 `json:"readme_boost"`
+}
+
+var codeExtensions = []string{
+	".go", ".c", ".h", ".cc", ".cpp", ".hpp", ".cs", ".java", ".kt", ".scala",
+	".js", ".jsx", ".ts", ".tsx", ".py", ".rb", ".rs", ".php", ".swift", ".m",
+	".sh", ".lua", ".zig", ".hs", ".ml", ".ex", ".sql", ".proto", ".glsl",
+}
+
+// applyCodeMode routes source files to the code analyzer and prose to the
+// plain one unless the config says otherwise
+func (c *Config) applyCodeMode() {
+	c.Gitignore = true
+	route := func(ext, analyzer string) {
+		if _, ok := c.Extensions[ext]; !ok {
+			c.Extensions[ext] = analyzer
+		}
+	}
+	for _, ext := range codeExtensions {
+		route(ext, "code")
+	}
+	for _, ext := range []string{".md", ".txt", ".rst", ""} {
+		route(ext, "plain")
+	}
+	for _, ext := range []string{".html", ".htm", ".xhtml"} {
+		route(ext, "html")
+	}
+}
+
+// gitTracked returns the files under dir that git knows about
+func gitTracked(dir string) (map[string]bool, error) {
+	cmd := exec.Command("git", "-C", dir, "ls-files", "-z")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git ls-files in %s: %w: %s", dir, err, strings.TrimSpace(stderr.String()))
+	}
+
+	tracked := make(map[string]bool)
+	for _, file := range bytes.Split(out, []byte{0}) {
+		if len(file) > 0 {
+			tracked[path.Join(dir, string(file))] = true
+		}
+	}
+	return tracked, nil
+}
+
+func isReadme(p string) bool {
+	return strings.HasPrefix(strings.ToLower(path.Base(p)), "readme")
+}
+
+// nameBoost rewards documents whose file name contains the query tokens
+func (o *CodeOptions) nameBoost(p string, tokens []string) float64 {
+	if len(tokens) == 0 {
+		return 1
+	}
+
+	name := make(map[string]bool)
+	for _, token := range tokenize(path.Base(p), LexerOptions{Markup: MarkupNone, Identifiers: true}) {
+		name[token] = true
+	}
+	matched := 0
+	for _, token := range tokens {
+		if name[token] {
+			matched++
+		}
+	}
+	return 1 + float64(o.NameBoost)*float64(matched)/float64(len(tokens))
+}
@@ -0,0 +1,152 @@
+package sego
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+)
+
+// removeDocument marks a document as deleted, it disappears from results right
+// away but its terms stay in the index until it is compacted
+func (m *Model) removeDocument(path string) bool {
+	sections := m.removeSections(path, map[string]bool{path: true})
+	id, ok := m.docID(path)
+	if !ok {
+		return sections > 0
+	}
+	if m.Deleted == nil {
+		m.Deleted = make(map[string]bool)
+	}
+	m.Deleted[id] = true
+	m.version++
+	return true
+}
+
+// compact drops deleted and expired documents and terms no document uses anymore and
+// returns how many documents and terms were removed
+func (m *Model) compact() (docs int, terms int) {
+	now := time.Now()
+	for id, doc := range m.Docs {
+		if doc.expired(now) {
+			if m.Deleted == nil {
+				m.Deleted = make(map[string]bool)
+			}
+			m.Deleted[id] = true
+		}
+	}
+	for id := range m.Deleted {
+		for term := range m.TF[id] {
+			m.DF[term]--
+		}
+		if path := m.docPath(id); m.paths[path] == id {
+			delete(m.paths, path)
+		}
+		delete(m.TF, id)
+		delete(m.Docs, id)
+		delete(m.Vectors, id)
+		delete(m.AttrText, id)
+		m.removeGrams(id)
+		docs++
+	}
+	m.Deleted = nil
+	m.indexVectors()
+	m.version++
+
+	referenced := make(map[string]bool)
+	for _, tf := range m.TF {
+		for term, n := range tf {
+			if n > 0 {
+				referenced[term] = true
+			}
+		}
+	}
+	for term, n := range m.DF {
+		if n <= 0 || !referenced[term] {
+			delete(m.DF, term)
+			terms++
+		}
+	}
+
+	return docs, terms
+}
+
+func runDelete(args []string) {
+	fs := flag.NewFlagSet("delete", flag.ExitOnError)
+	indexPath := fs.String("index", "index-new.json", "index to delete documents from")
+	query := fs.String("query", "", "delete the documents matching this, like 'path:old-docs/** OR tag:deprecated'")
+	dryRun := fs.Bool("dry-run", false, "list the documents -query matches without deleting them")
+	fs.Parse(args)
+	if fs.NArg() == 0 && *query == "" {
+		log.Fatal("usage: sego delete [-index index.json] [-query query [-dry-run]] <path>...")
+	}
+
+	model, err := newModelFromJson(*indexPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *query != "" {
+		selection, err := parseSelection(*query)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var paths []string
+		if *dryRun {
+			paths, err = model.matchingPaths(selection, nil)
+		} else {
+			paths, err = model.deleteMatching(selection)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, path := range paths {
+			fmt.Println(path)
+		}
+		if *dryRun {
+			return
+		}
+		log.Printf("Deleted %d documents", len(paths))
+	}
+
+	for _, path := range fs.Args() {
+		if !model.removeDocument(path) {
+			log.Printf("Not indexed: %s", path)
+		}
+	}
+
+	if err := model.saveAsJson(*indexPath); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func runCompact(args []string) {
+	fs := flag.NewFlagSet("compact", flag.ExitOnError)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		log.Fatal("usage: sego compact <index>")
+	}
+
+	model, err := newModelFromJson(fs.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	before, err := json.MarshalIndent(model, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	docs, terms := model.compact()
+	after, err := json.MarshalIndent(model, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := model.saveAsJson(fs.Arg(0)); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Removed %d documents and %d terms, reclaimed %s (%s => %s)",
+		docs, terms, formatSize(int64(len(before)-len(after))),
+		formatSize(int64(len(before))), formatSize(int64(len(after))))
+}
@@ -0,0 +1,389 @@
+package sego
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	// named analyzers, these extend or replace the built-in html, plain and code ones
+	Analyzers map[string]LexerOptions `json:"analyzers"`
+	// ".go" => "code"
+	Extensions map[string]string `json:"extensions"`
+	// "text/html" => "html", "text/*" => "plain"
+	MimeTypes map[string]string `json:"mime_types"`
+
+	// 0 means unlimited
+	MaxFileSize     int64 `json:"max_file_size"`
+	MaxTokensPerDoc int   `json:"max_tokens_per_doc"`
+	// term tables over this many bytes are spilled to disk while indexing
+	MaxMemory int64 `json:"max_memory"`
+	// what to do with documents over a limit: "truncate" or "skip"
+	OnLimit string `json:"on_limit"`
+
+	// descend into symlinked directories, symlinked files are always indexed
+	FollowSymlinks bool `json:"follow_symlinks"`
+	// file names or paths relative to the indexed folder to leave out, e.g. "*.min.js"
+	Exclude []string `json:"exclude"`
+
+	// index a source code repository: honor .gitignore, split identifiers and
+	// boost file name and README matches
+	Code        bool    `json:"code"`
+	Gitignore   bool    `json:"gitignore"`
+	GitTracked  bool    `json:"git_tracked"`
+	NameBoost   float32 `json:"name_boost"`
+	ReadmeBoost float32 `json:"readme_boost"`
+
+	// also index the outputs of Jupyter notebook cells
+	NotebookOutputs bool `json:"notebook_outputs"`
+
+	// recognize text in images and scanned PDFs
+	OCR          bool   `json:"ocr"`
+	OCRLanguages string `json:"ocr_languages"`
+	// recognized text is kept here by content hash, "" disables the cache
+	OCRCache  string `json:"ocr_cache"`
+	Tesseract string `json:"tesseract"`
+	Pdftotext string `json:"pdftotext"`
+	Pdftoppm  string `json:"pdftoppm"`
+
+	// MIME type => command extracting text and metadata from such files
+	Extractors map[string]*ExternalExtractor `json:"extractors"`
+	// Go plugins with token filters for analyzers
+	Plugins []string `json:"plugins"`
+
+	// database rows to index
+	SQL *SQLOptions `json:"sql"`
+
+	// how long documents without an expiry date of their own stay searchable,
+	// e.g. "720h", "" means forever
+	TTL string `json:"ttl"`
+
+	// also index the n-grams of terms for substring and prefix matching
+	NGrams *NGramOptions `json:"ngrams"`
+
+	// regexp whose first group is the version of a document in its path, e.g.
+	// "^docs.gl/([^/]+)/", searches default to the newest version
+	VersionPattern string `json:"version_pattern"`
+
+	// how much the alt text, titles and ARIA labels of HTML documents count
+	// compared to their content, 0 leaves them out
+	AttributeBoost float32 `json:"attribute_boost"`
+
+	// leave out the navigation, headers, footers and sidebars of HTML pages,
+	// and the elements matching these CSS selectors, e.g. ".sidebar"
+	StripBoilerplate bool     `json:"strip_boilerplate"`
+	ExcludeSelectors []string `json:"exclude_selectors"`
+	// which parts of the HTML pages of sites or paths to index, the first
+	// rule matching a page applies
+	ExtractRules []ExtractRule `json:"extract_rules"`
+
+	// index HTML and Markdown pages as a document per section under headings
+	// of up to this level, linking to their anchors, 0 indexes them whole
+	Sections int `json:"sections"`
+	// or as overlapping chunks of this many words, for documents longer than
+	// that, found as the document ranked by its best chunk
+	ChunkSize    int `json:"chunk_size"`
+	ChunkOverlap int `json:"chunk_overlap"`
+
+	// signals of document quality like length, path depth and freshness and
+	// how much they add to the rank, e.g. {"weights": {"freshness": 0.5}}
+	Quality *QualityOptions `json:"quality"`
+
+	// JSON file of document paths and rank multipliers, e.g. {"faq.html": 3}
+	Boosts string `json:"boosts"`
+
+	// embed documents for semantic search
+	Embedding *EmbeddingOptions `json:"embedding"`
+
+	// cron expression the daemon rebuilds the index at, e.g. "0 3 * * *"
+	Reindex string `json:"reindex"`
+	// queries new documents are checked against when refreshing feeds,
+	// ingesting and rebuilding in the daemon
+	Alerts []SavedQuery `json:"alerts"`
+	// notified when indexing completes or fails and when the daemon reloads
+	Webhooks []Webhook `json:"webhooks"`
+}
+
+func (c *Config) ttl() time.Duration {
+	ttl, err := time.ParseDuration(c.TTL)
+	if err != nil {
+		return 0
+	}
+	return ttl
+}
+
+func newConfig() *Config {
+	return &Config{
+		Analyzers:  make(map[string]LexerOptions),
+		Extensions: make(map[string]string),
+		MimeTypes:  make(map[string]string),
+		OnLimit:    "truncate",
+
+		NameBoost:   2,
+		ReadmeBoost: 1.5,
+
+		OCRLanguages: "eng",
+		OCRCache:     defaultOCRCache(),
+		Tesseract:    "tesseract",
+		Pdftotext:    "pdftotext",
+		Pdftoppm:     "pdftoppm",
+	}
+}
+
+func defaultOCRCache() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "sego", "ocr")
+}
+
+// registerFlags adds flags for the config file and the settings that can be
+// overridden on the command line, flags after -config win over the file
+func (c *Config) registerFlags(fs *flag.FlagSet) {
+	fs.Func("config", "config file with analyzers, their routing and limits", c.load)
+	fs.Func("ttl", "expire documents without an expiry date of their own after this long, e.g. 720h", func(s string) error {
+		if err := checkTTL(s); err != nil {
+			return err
+		}
+		c.TTL = s
+		return nil
+	})
+	fs.Func("plugin", "Go plugin providing token filters or quality signals (repeatable)", func(s string) error {
+		c.Plugins = append(c.Plugins, s)
+		return nil
+	})
+	fs.Func("versions", "regexp whose first group is the version of a document in its path, \"auto\" for folders like gl3 or v1.2", func(s string) error {
+		if s == "auto" {
+			s = autoVersionPattern
+		}
+		if _, err := compileVersionPattern(s); err != nil {
+			return err
+		}
+		c.VersionPattern = s
+		return nil
+	})
+	fs.Func("attribute-boost", "also index the alt text, titles and ARIA labels of HTML documents, counting this much compared to their content, e.g. 0.3", func(s string) error {
+		return parseFloat32(s, &c.AttributeBoost)
+	})
+	fs.BoolVar(&c.StripBoilerplate, "strip-boilerplate", c.StripBoilerplate, "leave out the navigation, headers, footers and sidebars of HTML pages")
+	fs.Func("exclude-selector", "leave out the elements of HTML pages matching this CSS selector, e.g. .sidebar (repeatable)", func(s string) error {
+		if _, err := parseSelector(s); err != nil {
+			return err
+		}
+		c.ExcludeSelectors = append(c.ExcludeSelectors, s)
+		return nil
+	})
+	fs.Func("sections", "index HTML and Markdown pages as a document per section under headings of up to this level, e.g. 3, linking to their anchors", func(s string) error {
+		level, err := strconv.Atoi(s)
+		if err != nil || level < 0 || level > 6 {
+			return fmt.Errorf("heading level %q is not a number from 0 to 6", s)
+		}
+		c.Sections = level
+		return c.checkSplitting()
+	})
+	fs.Func("chunks", "index documents longer than size words as chunks of that many sharing overlap words, e.g. 300:50, ranked by their best chunk", func(s string) error {
+		if err := c.parseChunks(s); err != nil {
+			return err
+		}
+		return c.checkSplitting()
+	})
+	fs.StringVar(&c.Boosts, "boosts", c.Boosts, "JSON file of document paths and rank multipliers to pin or demote them")
+	fs.Func("ngrams", "also index the n-grams of terms from min to max runes for substring matching, e.g. 3:5", func(s string) error {
+		c.NGrams = &NGramOptions{}
+		return c.NGrams.parse(s)
+	})
+	fs.Func("edge-ngrams", "also index the prefixes of terms from min to max runes for completion, e.g. 2:10", func(s string) error {
+		c.NGrams = &NGramOptions{Edge: true}
+		return c.NGrams.parse(s)
+	})
+	fs.Func("max-file-size", "largest file to index, e.g. 10MB (default unlimited)", func(s string) error {
+		n, err := parseSize(s)
+		c.MaxFileSize = n
+		return err
+	})
+	fs.Func("max-memory", "spill term tables to disk while indexing when they take more than this, e.g. 2GB (default unlimited)", func(s string) error {
+		n, err := parseSize(s)
+		c.MaxMemory = n
+		return err
+	})
+	fs.IntVar(&c.MaxTokensPerDoc, "max-tokens-per-doc", c.MaxTokensPerDoc, "most tokens to index per document (default unlimited)")
+	fs.BoolVar(&c.FollowSymlinks, "follow-symlinks", c.FollowSymlinks, "descend into symlinked directories")
+	fs.Func("exclude", "leave out files or directories matching this pattern (repeatable)", func(s string) error {
+		if _, err := path.Match(s, ""); err != nil {
+			return err
+		}
+		c.Exclude = append(c.Exclude, s)
+		return nil
+	})
+	fs.BoolVar(&c.Code, "code", c.Code, "index a source code repository")
+	fs.BoolVar(&c.Gitignore, "gitignore", c.Gitignore, "leave out files ignored by .gitignore")
+	fs.BoolVar(&c.GitTracked, "git-tracked", c.GitTracked, "only index files tracked by git")
+	fs.Func("name-boost", "code mode: up to how much more file name matches count (default 2)", func(s string) error {
+		return parseFloat32(s, &c.NameBoost)
+	})
+	fs.Func("readme-boost", "code mode: how much more README files count (default 1.5)", func(s string) error {
+		return parseFloat32(s, &c.ReadmeBoost)
+	})
+	fs.BoolVar(&c.NotebookOutputs, "notebook-outputs", c.NotebookOutputs, "also index the outputs of notebook cells")
+	fs.BoolVar(&c.OCR, "ocr", c.OCR, "recognize text in images and scanned PDFs with tesseract")
+	fs.StringVar(&c.OCRLanguages, "ocr-languages", c.OCRLanguages, "tesseract languages, e.g. eng+deu")
+	fs.StringVar(&c.OCRCache, "ocr-cache", c.OCRCache, "directory recognized text is cached in (empty disables)")
+	fs.StringVar(&c.Tesseract, "tesseract", c.Tesseract, "tesseract binary")
+	fs.Func("on-limit", "truncate or skip documents over a limit (default truncate)", func(s string) error {
+		if s != "truncate" && s != "skip" {
+			return fmt.Errorf("unknown limit policy %q", s)
+		}
+		c.OnLimit = s
+		return nil
+	})
+}
+
+func loadConfig(path string) (*Config, error) {
+	config := newConfig()
+	if err := config.load(path); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// load merges the config file at path into c
+func (c *Config) load(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, c); err != nil {
+		return err
+	}
+	// the index keeps the analyzers, their dictionaries have to be found
+	// wherever it is searched from
+	for name, opts := range c.Analyzers {
+		if opts.Dictionary != "" {
+			if opts.Dictionary, err = filepath.Abs(opts.Dictionary); err != nil {
+				return err
+			}
+			c.Analyzers[name] = opts
+		}
+	}
+	if err := c.check(); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// check validates what the config file sets so mistakes fail before indexing
+func (c *Config) check() error {
+	if err := checkTTL(c.TTL); err != nil {
+		return err
+	}
+	if c.VersionPattern != "" {
+		if _, err := compileVersionPattern(c.VersionPattern); err != nil {
+			return err
+		}
+	}
+	for _, s := range c.ExcludeSelectors {
+		if _, err := cachedSelector(s); err != nil {
+			return err
+		}
+	}
+	for i := range c.ExtractRules {
+		if err := c.ExtractRules[i].check(); err != nil {
+			return err
+		}
+	}
+	return c.checkSplitting()
+}
+
+// checkTTL rejects a ttl that isn't a duration or is negative, which would
+// otherwise turn expiry off
+func checkTTL(s string) error {
+	if s == "" {
+		return nil
+	}
+	ttl, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("ttl: %w", err)
+	}
+	if ttl < 0 {
+		return fmt.Errorf("ttl %q is negative", s)
+	}
+	return nil
+}
+
+// checkSplitting rejects splitting pages both into sections and chunks
+func (c *Config) checkSplitting() error {
+	if c.Sections > 0 && c.ChunkSize > 0 {
+		return fmt.Errorf("pages can be split into sections or chunks, not both")
+	}
+	return nil
+}
+
+// mediaTypeOf guesses the MIME type of a file from its extension or else its
+// content, without parameters like charset
+func mediaTypeOf(filePath string, content []byte) string {
+	contentType := mime.TypeByExtension(strings.ToLower(path.Ext(filePath)))
+	if contentType == "" {
+		contentType = http.DetectContentType(content)
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return mediaType
+}
+
+func builtinAnalyzers(base LexerOptions) map[string]LexerOptions {
+	html := base
+	html.Markup = MarkupHTML
+
+	plain := base
+	plain.Markup = MarkupNone
+
+	code := plain
+	code.Identifiers = true
+
+	return map[string]LexerOptions{
+		"html":  html,
+		"plain": plain,
+		"code":  code,
+	}
+}
+
+// analyzerName picks the analyzer for a file by its extension first and its
+// MIME type second, "" means the model's default lexer options
+func (c *Config) analyzerName(filePath string, content []byte) string {
+	ext := strings.ToLower(path.Ext(filePath))
+	if name, ok := c.Extensions[ext]; ok {
+		return name
+	}
+
+	if len(c.MimeTypes) == 0 {
+		return ""
+	}
+
+	mediaType := mediaTypeOf(filePath, content)
+	if mediaType == "" {
+		return ""
+	}
+	if name, ok := c.MimeTypes[mediaType]; ok {
+		return name
+	}
+	major, _, _ := strings.Cut(mediaType, "/")
+	return c.MimeTypes[major+"/*"]
+}
+
+func parseFloat32(s string, f *float32) error {
+	v, err := strconv.ParseFloat(s, 32)
+	*f = float32(v)
+	return err
+}
@@ -0,0 +1,34 @@
+package sego
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigTTL(t *testing.T) {
+	dir := t.TempDir()
+	tests := map[string]string{
+		`{"ttl": "7days"}`: "ttl",
+		`{"ttl": "-1h"}`:   "ttl",
+		`{"ttl": "720h"}`:  "",
+		`{}`:               "",
+	}
+	for content, wantErr := range tests {
+		path := filepath.Join(dir, "config.json")
+		if err := os.WriteFile(path, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+		config, err := loadConfig(path)
+		switch {
+		case wantErr == "" && err != nil:
+			t.Errorf("%s: %s", content, err)
+		case wantErr != "" && (err == nil || !strings.Contains(err.Error(), wantErr)):
+			t.Errorf("%s: error %v, want one naming %s", content, err, wantErr)
+		case content == `{"ttl": "720h"}` && config.ttl() != 720*time.Hour:
+			t.Errorf("%s: ttl %s", content, config.ttl())
+		}
+	}
+}
@@ -0,0 +1,642 @@
+package sego
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+type CrawlOptions struct {
+	Concurrency int
+	PerHost     int
+	// minimum time between two requests to the same host
+	Delay   time.Duration
+	Retries int
+	// first retry waits this long, every further one twice as long
+	Backoff  time.Duration
+	Timeout  time.Duration
+	MaxPages int
+	MaxDepth int
+	// only follow links to the hosts of the seed URLs
+	SameHost  bool
+	UserAgent string
+	// where the frontier is persisted, "" disables resuming
+	StatePath string
+	// save the frontier and index every n pages
+	Checkpoint int
+}
+
+func (o *CrawlOptions) registerFlags(fs *flag.FlagSet) {
+	fs.IntVar(&o.Concurrency, "concurrency", 4, "pages fetched at the same time")
+	fs.IntVar(&o.PerHost, "per-host", 1, "pages fetched at the same time from one host")
+	fs.DurationVar(&o.Delay, "delay", time.Second, "minimum time between requests to one host")
+	fs.IntVar(&o.Retries, "retries", 3, "retries for failed requests")
+	fs.DurationVar(&o.Backoff, "backoff", time.Second, "wait before the first retry, doubled for each further one")
+	fs.DurationVar(&o.Timeout, "timeout", 30*time.Second, "timeout for one request")
+	fs.IntVar(&o.MaxPages, "max-pages", 0, "stop after this many pages (0 means no limit)")
+	fs.IntVar(&o.MaxDepth, "max-depth", 0, "don't follow links further than this from a seed (0 means no limit)")
+	fs.BoolVar(&o.SameHost, "same-host", true, "only follow links to the hosts of the seed URLs")
+	fs.StringVar(&o.UserAgent, "user-agent", "sego-crawler/1.0", "User-Agent header to send")
+	fs.StringVar(&o.StatePath, "state", "crawl-state.json", "file the frontier is saved to and resumed from")
+	fs.IntVar(&o.Checkpoint, "checkpoint", 50, "save the frontier and index every n pages")
+}
+
+type frontierEntry struct {
+	URL   string `json:"url"`
+	Depth int    `json:"depth"`
+}
+
+// crawlState is everything needed to resume a crawl
+type crawlState struct {
+	Queue []frontierEntry `json:"queue"`
+	// URLs that were queued at some point, so they are never queued twice
+	Seen    map[string]bool `json:"seen"`
+	Fetched int             `json:"fetched"`
+	Hosts   map[string]bool `json:"hosts"`
+}
+
+func newCrawlState() *crawlState {
+	return &crawlState{
+		Queue: make([]frontierEntry, 0),
+		Seen:  make(map[string]bool),
+		Hosts: make(map[string]bool),
+	}
+}
+
+func loadCrawlState(path string) (*crawlState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	state := newCrawlState()
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func (s *crawlState) save(path string, inflight map[string]frontierEntry) error {
+	// pages being fetched right now have to be fetched again after a resume
+	saved := *s
+	saved.Queue = append([]frontierEntry{}, s.Queue...)
+	for _, entry := range inflight {
+		saved.Queue = append(saved.Queue, entry)
+	}
+
+	data, err := json.Marshal(&saved)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0666); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *crawlState) enqueue(rawURL string, depth int) {
+	if s.Seen[rawURL] {
+		return
+	}
+	s.Seen[rawURL] = true
+	s.Queue = append(s.Queue, frontierEntry{URL: rawURL, Depth: depth})
+}
+
+// seed queues a URL to start crawling from, its host is one links stay on
+// with SameHost
+func (s *crawlState) seed(u *url.URL) {
+	s.Hosts[normalizeHost(u)] = true
+	s.enqueue(normalizeLink(u, ""), 0)
+}
+
+// hostLimiter caps concurrent requests to a host and spaces them out
+type hostLimiter struct {
+	slots chan struct{}
+	mu    sync.Mutex
+	next  time.Time
+}
+
+func (h *hostLimiter) acquire(delay time.Duration) {
+	h.slots <- struct{}{}
+	h.mu.Lock()
+	if wait := time.Until(h.next); wait > 0 {
+		time.Sleep(wait)
+	}
+	h.next = time.Now().Add(delay)
+	h.mu.Unlock()
+}
+
+func (h *hostLimiter) release() {
+	<-h.slots
+}
+
+type crawler struct {
+	opts   *CrawlOptions
+	client *http.Client
+
+	mu    sync.Mutex
+	hosts map[string]*hostLimiter
+}
+
+func newCrawler(opts *CrawlOptions) *crawler {
+	return &crawler{
+		opts:   opts,
+		client: &http.Client{Timeout: opts.Timeout},
+		hosts:  make(map[string]*hostLimiter),
+	}
+}
+
+func (c *crawler) limiter(host string) *hostLimiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	h, ok := c.hosts[host]
+	if !ok {
+		h = &hostLimiter{slots: make(chan struct{}, c.opts.PerHost)}
+		c.hosts[host] = h
+	}
+	return h
+}
+
+type link struct {
+	URL  string `json:"url"`
+	Text string `json:"text,omitempty"`
+}
+
+type page struct {
+	URL         string
+	ContentType string
+	// the server said the page didn't change since it was last crawled, there
+	// is no body then
+	NotModified  bool
+	ETag         string
+	LastModified string
+	// X-Robots-Tag headers
+	Robots []string
+	Body   []byte
+}
+
+// errRetry marks failures worth retrying, like network errors, 429 and 5xx
+type errRetry struct {
+	err        error
+	status     int
+	retryAfter time.Duration
+}
+
+func (e *errRetry) Error() string {
+	if e.err != nil {
+		return e.err.Error()
+	}
+	return fmt.Sprintf("status %d", e.status)
+}
+
+// validators identify the version of a page crawled before
+type validators struct {
+	etag         string
+	lastModified string
+}
+
+func (c *crawler) get(u *url.URL, maxSize int64, cached validators) (*page, error) {
+	h := c.limiter(u.Host)
+	h.acquire(c.opts.Delay)
+	defer h.release()
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", c.opts.UserAgent)
+	if cached.etag != "" {
+		req.Header.Set("If-None-Match", cached.etag)
+	}
+	if cached.lastModified != "" {
+		req.Header.Set("If-Modified-Since", cached.lastModified)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, &errRetry{err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		retry := &errRetry{status: resp.StatusCode}
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			retry.retryAfter = time.Duration(seconds) * time.Second
+		}
+		return nil, retry
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		return &page{URL: resp.Request.URL.String(), NotModified: true}, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSize))
+	if err != nil {
+		return nil, &errRetry{err: err}
+	}
+
+	return &page{
+		URL:          resp.Request.URL.String(),
+		ContentType:  resp.Header.Get("Content-Type"),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Robots:       resp.Header.Values("X-Robots-Tag"),
+		Body:         body,
+	}, nil
+}
+
+// fetch gets a page, retrying with exponential backoff
+func (c *crawler) fetch(rawURL string, maxSize int64, cached validators) (*page, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	backoff := c.opts.Backoff
+	for attempt := 0; ; attempt++ {
+		p, err := c.get(u, maxSize, cached)
+		var retry *errRetry
+		if err == nil || !errors.As(err, &retry) {
+			return p, err
+		}
+		if attempt >= c.opts.Retries {
+			return nil, fmt.Errorf("giving up after %d attempts: %w", attempt+1, err)
+		}
+
+		wait := backoff
+		if retry.retryAfter > wait {
+			wait = retry.retryAfter
+		}
+		log.Printf("Retrying in %s: %s (%s)", wait, rawURL, err)
+		time.Sleep(wait)
+		backoff *= 2
+	}
+}
+
+func isHTML(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
+
+func isText(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return strings.HasPrefix(mediaType, "text/") || isHTML(contentType)
+}
+
+// query parameters that track where a visitor came from rather than select
+// what the page shows
+var trackingParams = map[string]bool{
+	"fbclid": true, "gclid": true, "dclid": true, "msclkid": true, "mc_cid": true, "mc_eid": true,
+	"_ga": true, "_gl": true, "igshid": true, "ref_src": true,
+}
+
+func isTrackingParam(name string) bool {
+	return strings.HasPrefix(name, "utm_") || trackingParams[name]
+}
+
+// normalizeLink resolves href against base and drops what doesn't identify a
+// different page, so the variants of a URL are crawled once. It returns "" for
+// links that can't be crawled.
+func normalizeLink(base *url.URL, href string) string {
+	u, err := base.Parse(strings.TrimSpace(href))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+	u.Host = normalizeHost(u)
+	if u.Path == "" {
+		u.Path = "/"
+	}
+
+	if u.RawQuery != "" {
+		query, err := url.ParseQuery(u.RawQuery)
+		if err == nil {
+			for name := range query {
+				if isTrackingParam(strings.ToLower(name)) {
+					delete(query, name)
+				}
+			}
+			// Encode sorts by name
+			u.RawQuery = query.Encode()
+		}
+	}
+	u.ForceQuery = false
+	return u.String()
+}
+
+// normalizeHost is the host of u lowercased and without the default port of
+// its scheme, as links are compared by
+func normalizeHost(u *url.URL) string {
+	host := strings.ToLower(u.Host)
+	if port := u.Port(); (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		host = strings.ToLower(u.Hostname())
+	}
+	return host
+}
+
+// extractLinks returns the links of an HTML page along with their anchor text
+func extractLinks(pageURL string, body []byte) []link {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil
+	}
+
+	links := make([]link, 0)
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	var current *link
+	var text strings.Builder
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return links
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			switch token.Data {
+			case "base":
+				for _, attr := range token.Attr {
+					if attr.Key == "href" {
+						if u, err := base.Parse(attr.Val); err == nil {
+							base = u
+						}
+					}
+				}
+			case "a":
+				for _, attr := range token.Attr {
+					if attr.Key == "href" {
+						if u := normalizeLink(base, attr.Val); u != "" {
+							current = &link{URL: u}
+							text.Reset()
+						}
+					}
+				}
+			}
+		case html.TextToken:
+			if current != nil {
+				text.Write(tokenizer.Text())
+			}
+		case html.EndTagToken:
+			if current != nil {
+				if name, _ := tokenizer.TagName(); string(name) == "a" {
+					current.Text = strings.Join(strings.Fields(text.String()), " ")
+					links = append(links, *current)
+					current = nil
+				}
+			}
+		}
+	}
+}
+
+// follow queues the pages links point to that may be crawled
+func (c *crawler) follow(state *crawlState, links []link, depth int) {
+	for _, l := range links {
+		u, err := url.Parse(l.URL)
+		if err != nil || (c.opts.SameHost && !state.Hosts[u.Host]) {
+			continue
+		}
+		state.enqueue(l.URL, depth)
+	}
+}
+
+type fetchResult struct {
+	entry frontierEntry
+	page  *page
+	err   error
+}
+
+// crawl fetches pages breadth first starting from the state's queue and adds
+// them to the model, saving progress every Checkpoint pages
+func (c *crawler) crawl(m *Model, state *crawlState, config *Config, indexPath string) error {
+	maxSize := config.MaxFileSize
+	if maxSize <= 0 {
+		maxSize = 10 << 20
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	stopping := false
+
+	stats := newIndexStats()
+	m.Stats = stats
+	results := make(chan fetchResult)
+	inflight := make(map[string]frontierEntry)
+	// content hash => URL of the page indexed with it
+	seen := make(map[string]string)
+	for _, id := range m.docIDs() {
+		if doc := m.Docs[id]; doc != nil && doc.Hash != "" {
+			seen[doc.Hash] = doc.Path
+		}
+	}
+
+	checkpoint := func() error {
+		m.computeAnchors()
+		m.computePageRank()
+		if err := m.saveAsJson(indexPath); err != nil {
+			return err
+		}
+		if c.opts.StatePath != "" {
+			return state.save(c.opts.StatePath, inflight)
+		}
+		return nil
+	}
+
+	for {
+		for !stopping && len(inflight) < c.opts.Concurrency && len(state.Queue) > 0 &&
+			(c.opts.MaxPages == 0 || state.Fetched+len(inflight) < c.opts.MaxPages) {
+			entry := state.Queue[0]
+			state.Queue = state.Queue[1:]
+			inflight[entry.URL] = entry
+			var cached validators
+			if doc, ok := m.document(entry.URL); ok {
+				cached = validators{doc.ETag, doc.LastModified}
+			}
+			go func(entry frontierEntry) {
+				p, err := c.fetch(entry.URL, maxSize, cached)
+				results <- fetchResult{entry, p, err}
+			}(entry)
+		}
+		if len(inflight) == 0 {
+			break
+		}
+
+		var r fetchResult
+		select {
+		case <-interrupt:
+			log.Printf("Interrupted, waiting for %d requests to finish", len(inflight))
+			stopping = true
+			continue
+		case r = <-results:
+		}
+		delete(inflight, r.entry.URL)
+		state.Fetched++
+		stats.Files++
+
+		if r.err != nil {
+			stats.skip(r.entry.URL, r.err.Error())
+			continue
+		}
+		if r.page.NotModified {
+			stats.Unchanged++
+			if c.opts.MaxDepth == 0 || r.entry.Depth < c.opts.MaxDepth {
+				c.follow(state, m.Links[r.entry.URL], r.entry.Depth+1)
+			}
+			continue
+		}
+		if !isText(r.page.ContentType) {
+			stats.skip(r.entry.URL, "not text: "+r.page.ContentType)
+			continue
+		}
+
+		var robots robotsDirectives
+		for _, value := range r.page.Robots {
+			robots.parse(value)
+		}
+		canonical := ""
+		if isHTML(r.page.ContentType) {
+			canonical = headDirectives(r.page.URL, r.page.Body, &robots)
+		}
+
+		// a duplicate of another page, crawl that one instead
+		if canonical != "" && canonical != r.entry.URL && canonical != r.page.URL {
+			if u, err := url.Parse(canonical); err == nil && (!c.opts.SameHost || state.Hosts[u.Host]) {
+				m.removeDocument(r.entry.URL)
+				stats.skip(r.entry.URL, "canonical is "+canonical)
+				state.enqueue(canonical, r.entry.Depth)
+				continue
+			}
+		}
+
+		hash := contentHash(r.page.Body)
+		if robots.noindex {
+			m.removeDocument(r.entry.URL)
+			stats.skip(r.entry.URL, "noindex")
+		} else if other, ok := seen[hash]; ok && other != r.entry.URL {
+			m.removeDocument(r.entry.URL)
+			stats.skip(r.entry.URL, "duplicate of "+other)
+		} else {
+			seen[hash] = r.entry.URL
+			log.Printf("Indexing: %s", r.entry.URL)
+			if err := m.indexDocument(r.entry.URL, r.page.Body, "", config, stats); err != nil {
+				return err
+			}
+			if doc, ok := m.document(r.entry.URL); ok {
+				doc.ETag = r.page.ETag
+				doc.LastModified = r.page.LastModified
+			}
+		}
+
+		// pages at the maximum depth still count for anchor text and PageRank,
+		// their links just aren't followed
+		links := make([]link, 0)
+		if isHTML(r.page.ContentType) && !robots.nofollow {
+			for _, l := range extractLinks(r.page.URL, r.page.Body) {
+				// links of a page to itself say nothing about it
+				if l.URL != r.page.URL && l.URL != r.entry.URL {
+					links = append(links, l)
+				}
+			}
+		}
+		m.setLinks(r.entry.URL, links)
+		if c.opts.MaxDepth == 0 || r.entry.Depth < c.opts.MaxDepth {
+			c.follow(state, links, r.entry.Depth+1)
+		}
+
+		if c.opts.Checkpoint > 0 && state.Fetched%c.opts.Checkpoint == 0 {
+			if err := checkpoint(); err != nil {
+				return err
+			}
+		}
+	}
+
+	log.Printf("Crawled %s, %d URLs left in the frontier", stats, len(state.Queue))
+	if err := checkpoint(); err != nil {
+		return err
+	}
+	if len(state.Queue) == 0 && !stopping && c.opts.StatePath != "" {
+		// nothing left to resume
+		return os.Remove(c.opts.StatePath)
+	}
+	return nil
+}
+
+func runCrawl(args []string) {
+	fs := flag.NewFlagSet("crawl", flag.ExitOnError)
+	indexPath := fs.String("o", "index-new.json", "where to write the index")
+	model := newModel()
+	model.Lexer.registerFlags(fs)
+	config := newConfig()
+	config.registerFlags(fs)
+	opts := &CrawlOptions{}
+	opts.registerFlags(fs)
+	anchorBoost := fs.Float64("anchor-boost", 2, "how much the anchor text of links to a page counts compared to its content")
+	pageRankWeight := fs.Float64("pagerank-weight", 0.5, "how much more the page with the highest PageRank counts, 0 disables")
+	update := fs.Bool("update", false, "recrawl into the index at -o, fetching only pages that changed since")
+	fs.Parse(args)
+	if opts.Concurrency < 1 || opts.PerHost < 1 {
+		log.Fatal("-concurrency and -per-host have to be at least 1")
+	}
+
+	state := newCrawlState()
+	if opts.StatePath != "" {
+		if saved, err := loadCrawlState(opts.StatePath); err == nil {
+			log.Printf("Resuming crawl from %s, %d URLs in the frontier", opts.StatePath, len(saved.Queue))
+			state = saved
+			if model, err = newModelFromJson(*indexPath); err != nil {
+				log.Fatal(err)
+			}
+		} else if !errors.Is(err, os.ErrNotExist) {
+			log.Fatal(err)
+		}
+	}
+	if *update && state.Fetched == 0 {
+		if _, err := os.Stat(*indexPath); err == nil {
+			if model, err = newModelFromJson(*indexPath); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+
+	for _, seed := range fs.Args() {
+		u, err := url.Parse(seed)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			log.Fatalf("not an http(s) URL: %s", seed)
+		}
+		state.seed(u)
+	}
+	if len(state.Queue) == 0 {
+		log.Fatal("usage: sego crawl [flags] <url>...")
+	}
+
+	model.AnchorBoost = float32(*anchorBoost)
+	model.PageRankWeight = *pageRankWeight
+	if config.Boosts != "" {
+		boosts, err := loadBoosts(config.Boosts)
+		if err != nil {
+			log.Fatal(err)
+		}
+		model.Boosts = boosts
+	}
+	model.setupAnalyzers(config)
+	if err := newCrawler(opts).crawl(model, state, config, *indexPath); err != nil {
+		config.fatal(*indexPath, err)
+	}
+	config.emit(&Event{Event: EventIndexComplete, Index: *indexPath, Stats: model.Stats})
+}
@@ -0,0 +1,24 @@
+package sego
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestSeedHostNormalized(t *testing.T) {
+	state := newCrawlState()
+	u, err := url.Parse("https://Example.com:443/docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	state.seed(u)
+	c := newCrawler(&CrawlOptions{SameHost: true})
+	body := []byte(`<a href="/docs/setup">setup</a><a href="https://other.org/">other</a>`)
+	c.follow(state, extractLinks("https://example.com/docs", body), 1)
+	if !state.Seen["https://example.com/docs/setup"] {
+		t.Errorf("link on the seed's host wasn't queued: %v", state.Queue)
+	}
+	if state.Seen["https://other.org/"] {
+		t.Error("link to another host was queued")
+	}
+}
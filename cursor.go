@@ -0,0 +1,174 @@
+package sego
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// scrolls open at once, beyond that new ones are refused until some end
+const maxCursors = 1000
+
+// longest a scroll is kept between pages
+const maxScroll = time.Hour
+
+var errTooManyCursors = errors.New("too many open cursors")
+
+// cursor is a scroll through the results of a search as they were ranked when
+// it started. It keeps what identifies them, the rest is looked up for each
+// page from the index searched, even once the served one is swapped.
+type cursor struct {
+	model   *Model
+	query   string
+	total   int
+	results []cursorResult
+	// results per page
+	size    int
+	fields  []string
+	ttl     time.Duration
+	expires time.Time
+}
+
+// cursorResult is what a cursor keeps of a result
+type cursorResult struct {
+	id       string
+	path     string
+	rank     float64
+	versions []string
+	more     int
+	pinned   bool
+}
+
+func newCursorResults(results SearchResults) []cursorResult {
+	kept := make([]cursorResult, len(results))
+	for i, r := range results {
+		kept[i] = cursorResult{id: r.ID, path: r.Path, rank: r.Rank, versions: r.Versions, more: r.More, pinned: r.Pinned}
+	}
+	return kept
+}
+
+// cursorPage makes the results of a page of a scroll, with their documents'
+// titles and descriptions as they are now
+func (m *Model) cursorPage(page []cursorResult) SearchResults {
+	results := make(SearchResults, len(page))
+	for i, c := range page {
+		r := SearchResult{ID: c.id, Path: c.path, Rank: c.rank, Versions: c.versions, More: c.more, Pinned: c.pinned}
+		if doc, ok := m.Docs[c.id]; ok {
+			r.Title = doc.Title
+			r.Description = doc.Description
+			r.Version = doc.Version
+			if doc.Parent != c.path {
+				r.Parent = doc.Parent
+			}
+		}
+		results[i] = r
+	}
+	return results
+}
+
+// cursorStore holds the open scrolls of a server
+type cursorStore struct {
+	mu      sync.Mutex
+	cursors map[string]*cursor
+}
+
+// open keeps c and returns its ID
+func (cs *cursorStore) open(c *cursor) (string, error) {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	c.expires = now.Add(c.ttl)
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if cs.cursors == nil {
+		cs.cursors = make(map[string]*cursor)
+	}
+	for id, c := range cs.cursors {
+		if now.After(c.expires) {
+			delete(cs.cursors, id)
+		}
+	}
+	if len(cs.cursors) >= maxCursors {
+		return "", errTooManyCursors
+	}
+	cs.cursors[hex.EncodeToString(id[:])] = c
+	return hex.EncodeToString(id[:]), nil
+}
+
+// next takes the next n results of the scroll id, its page size if n is 0,
+// and returns the cursor with them, nil if it's unknown or expired. The
+// cursor is closed once nothing is left.
+func (cs *cursorStore) next(id string, n int) (*cursor, []cursorResult, bool) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	c, ok := cs.cursors[id]
+	if !ok || time.Now().After(c.expires) {
+		delete(cs.cursors, id)
+		return nil, nil, false
+	}
+	if n == 0 {
+		n = c.size
+	}
+	if n > len(c.results) {
+		n = len(c.results)
+	}
+	page := c.results[:n]
+	c.results = c.results[n:]
+	c.expires = time.Now().Add(c.ttl)
+	more := len(c.results) > 0
+	if !more {
+		delete(cs.cursors, id)
+	}
+	return c, page, more
+}
+
+// parseScroll reads how long to keep a scroll between pages, 0 if not asked
+// for
+func parseScroll(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	ttl, err := time.ParseDuration(s)
+	if err != nil || ttl <= 0 || ttl > maxScroll {
+		return 0, fmt.Errorf("invalid scroll %q", s)
+	}
+	return ttl, nil
+}
+
+// handleScroll answers GET /search?cursor=... with the next page of a scroll
+func (s *server) handleScroll(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	n := 0
+	if v := params.Get("n"); v != "" {
+		var err error
+		if n, err = strconv.Atoi(v); err != nil || n < 1 {
+			httpError(w, http.StatusBadRequest, fmt.Errorf("invalid n %q", v))
+			return
+		}
+	}
+	c, kept, more := s.cursors.next(params.Get("cursor"), n)
+	if c == nil {
+		httpError(w, http.StatusNotFound, fmt.Errorf("unknown or expired cursor %q", params.Get("cursor")))
+		return
+	}
+	// a model updated live changes under the lock, a swapped one no more
+	s.mu.RLock()
+	page := c.model.cursorPage(kept)
+	if wantsSnippets(c.fields) {
+		c.model.snippets(page, c.query, s.config)
+	}
+	s.mu.RUnlock()
+	response := searchResponse{Query: c.query, Total: c.total, Results: page}
+	if more {
+		response.Cursor = params.Get("cursor")
+	}
+	writeJson(w, http.StatusOK, response.respond(c.fields))
+}
@@ -0,0 +1,50 @@
+package sego
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestScrollSurvivesSwap(t *testing.T) {
+	config := newConfig()
+	m := newModel()
+	for i := 0; i < 5; i++ {
+		path := fmt.Sprintf("%d.txt", i)
+		if err := m.apply(&IngestMessage{Path: path, Content: "shader page"}, config); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := &server{model: m, config: newConfig(), mu: &sync.RWMutex{}}
+
+	get := func(url string) (int, searchResponse) {
+		w := httptest.NewRecorder()
+		s.handleSearch(w, httptest.NewRequest(http.MethodGet, url, nil))
+		var response searchResponse
+		json.NewDecoder(w.Body).Decode(&response)
+		return w.Code, response
+	}
+	code, first := get("/search?q=shader&n=2&scroll=1m")
+	if code != http.StatusOK || first.Cursor == "" {
+		t.Fatalf("search answered %d without a cursor", code)
+	}
+	code, second := get("/search?cursor=" + first.Cursor)
+	if code != http.StatusOK || len(second.Results) != 2 {
+		t.Fatalf("scroll answered %d with %d results", code, len(second.Results))
+	}
+	if second.Results[0].Description == "" || second.Results[0].Path == first.Results[0].Path {
+		t.Errorf("second page starts with %+v", second.Results[0])
+	}
+
+	s.swap(newModel())
+	code, third := get("/search?cursor=" + second.Cursor)
+	if code != http.StatusOK || len(third.Results) != 1 || third.Cursor != "" {
+		t.Fatalf("scroll after swap answered %d with %d results", code, len(third.Results))
+	}
+	if third.Results[0].Description == "" {
+		t.Errorf("last page lost its documents: %+v", third.Results[0])
+	}
+}
@@ -0,0 +1,141 @@
+package sego
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// copyFile copies src to dst, a missing src is no error
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// rebuild runs sego with command writing to a copy of the index and swaps
+// the result in when it succeeds. Running it as its own process keeps a
+// failing run from taking the server down.
+func (s *server) rebuild(indexPath string, command []string) error {
+	next := indexPath + ".next"
+	// start from the current index so -update and resumed crawls work
+	if err := copyFile(indexPath, next); err != nil {
+		return err
+	}
+	defer os.Remove(next)
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	args := append([]string{command[0], "-o", next}, command[1:]...)
+	cmd := exec.Command(exe, args...)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return err
+	}
+
+	model, err := newModelFromJson(next)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(next, indexPath); err != nil {
+		return err
+	}
+	s.swap(model)
+	return nil
+}
+
+func runDaemon(args []string) {
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	indexPath := fs.String("index", "index-new.json", "index to serve and rebuild")
+	addr := fs.String("addr", ":8080", "address to listen on")
+	configPath := fs.String("config", "", "config file with the reindex schedule, passed on to the command")
+	schedule := fs.String("schedule", "", "cron expression to rebuild the index at, e.g. \"0 3 * * *\" (default the config's reindex)")
+	lameDuck := fs.Duration("lame-duck", 5*time.Second, "how long to keep serving while reporting not ready on shutdown")
+	fs.Parse(args)
+	command := fs.Args()
+	if len(command) == 0 || (command[0] != "index" && command[0] != "crawl") {
+		log.Fatal("usage: sego daemon [flags] index|crawl [flags] <source>...")
+	}
+	config := newConfig()
+	if *configPath != "" {
+		if err := config.load(*configPath); err != nil {
+			log.Fatal(err)
+		}
+		if *schedule == "" {
+			*schedule = config.Reindex
+		}
+		command = append([]string{command[0], "-config", *configPath}, command[1:]...)
+	}
+	if *schedule == "" {
+		log.Fatal("no schedule, set reindex in the config or pass -schedule")
+	}
+	cron, err := parseCron(*schedule)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	s := &server{config: config, mu: &sync.RWMutex{}}
+	if _, err := os.Stat(*indexPath); errors.Is(err, os.ErrNotExist) {
+		log.Printf("No index at %s yet, building it", *indexPath)
+		if err := s.rebuild(*indexPath, command); err != nil {
+			log.Fatal(err)
+		}
+	} else if s.model, err = newModelFromJson(*indexPath); err != nil {
+		log.Fatal(err)
+	}
+
+	go func() {
+		for {
+			next := cron.next(time.Now())
+			if next.IsZero() {
+				log.Printf("Schedule %q never fires again", *schedule)
+				return
+			}
+			log.Printf("Next rebuild at %s", next.Format(time.RFC3339))
+			time.Sleep(time.Until(next))
+			s.mu.RLock()
+			before := s.model.docHashes()
+			s.mu.RUnlock()
+			if err := s.rebuild(*indexPath, command); err != nil {
+				log.Printf("Rebuilding %s failed, still serving the old index: %s", *indexPath, err)
+				config.emit(&Event{Event: EventError, Index: *indexPath, Error: err.Error()})
+				continue
+			}
+			log.Printf("Rebuilt %s", *indexPath)
+			s.mu.RLock()
+			stats := s.model.Stats
+			alerts := s.model.matchAlerts(config.Alerts, s.model.changedSince(before))
+			s.mu.RUnlock()
+			config.emit(&Event{Event: EventReload, Index: *indexPath, Stats: stats})
+			for _, a := range alerts {
+				notify(a.query, a.alert)
+			}
+		}
+	}()
+
+	log.Printf("Serving %s on %s", *indexPath, *addr)
+	if err := s.listen(*addr, s.routes(false), *lameDuck); err != nil {
+		log.Fatal(err)
+	}
+}
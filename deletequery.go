@@ -0,0 +1,188 @@
+package sego
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// parseSelection parses a query picking documents rather than ranking them,
+// like `path:old-docs/** OR tag:deprecated`. Conditions are path globs,
+// filters like lang:en, words and prefixes like shad*, a leading "-" negates
+// one. Conditions next to each other all have to hold, OR separates
+// alternatives.
+func parseSelection(query string) (Node, error) {
+	root := &BoolNode{}
+	group := &BoolNode{}
+	add := func() error {
+		if len(group.Must) == 0 && len(group.MustNot) == 0 {
+			return fmt.Errorf("empty alternative in %q", query)
+		}
+		root.Should = append(root.Should, group)
+		group = &BoolNode{}
+		return nil
+	}
+	for _, word := range strings.Fields(query) {
+		switch word {
+		case "OR":
+			if err := add(); err != nil {
+				return nil, err
+			}
+			continue
+		case "AND":
+			continue
+		}
+		negate := false
+		if rest, ok := strings.CutPrefix(word, "-"); ok && rest != "" {
+			negate, word = true, rest
+		}
+		var node Node
+		field, value, ok := strings.Cut(word, ":")
+		_, known := filterFields[strings.ToLower(field)]
+		switch {
+		case ok && strings.EqualFold(field, "path") && value != "":
+			if _, err := globToRegexp(value); err != nil {
+				return nil, fmt.Errorf("invalid path glob %q: %w", value, err)
+			}
+			node = &PathNode{Glob: value}
+		case ok && known && value != "":
+			node = &FilterNode{Field: field, Value: value}
+		case strings.HasSuffix(word, "*") && len(word) > 1:
+			node = &TermNode{Term: strings.TrimSuffix(word, "*"), Prefix: true}
+		default:
+			node = &TermNode{Term: word}
+		}
+		if negate {
+			group.MustNot = append(group.MustNot, node)
+		} else {
+			group.Must = append(group.Must, node)
+		}
+	}
+	if err := add(); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// matchingPaths returns the paths of the documents query matches, skipping
+// those hidden is true for
+func (m *Model) matchingPaths(query Node, hidden map[string]bool) ([]string, error) {
+	docs := m.docIDs()
+	matches, err := query.match(m, docs)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0)
+	for i, id := range docs {
+		if path := m.docPath(id); matches.has(i) && !hidden[path] {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
+// deleteMatching removes the documents query matches and returns their paths
+func (m *Model) deleteMatching(query Node) ([]string, error) {
+	paths, err := m.matchingPaths(query, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		m.removeDocument(path)
+	}
+	return paths, nil
+}
+
+// deleteMatching deletes the documents query matches like a delete message
+// for each, so they are logged and replayed one by one, and returns their
+// paths. Only matches are returned when dryRun is set.
+func (in *ingester) deleteMatching(query Node, dryRun bool) ([]string, error) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	paths := make([]string, 0)
+	if in.segments != nil {
+		seen := make(map[string]bool)
+		segments := append(in.segments.set.segments[:len(in.segments.set.segments):len(in.segments.set.segments)], in.segments.buffer)
+		for i, seg := range segments {
+			// documents deleted from the buffer are gone from its model, all
+			// it removes is only hidden in the older segments
+			var hidden map[string]bool
+			if i < len(in.segments.set.segments) {
+				hidden = mergedPaths(in.segments.set.hidden[i], in.segments.buffer.removes)
+			}
+			found, err := seg.model.matchingPaths(query, hidden)
+			if err != nil {
+				return nil, err
+			}
+			for _, path := range found {
+				if !seen[path] {
+					seen[path] = true
+					paths = append(paths, path)
+				}
+			}
+		}
+	} else {
+		var err error
+		if paths, err = in.model.matchingPaths(query, nil); err != nil {
+			return nil, err
+		}
+	}
+	if dryRun {
+		return paths, nil
+	}
+
+	for _, path := range paths {
+		msg := &IngestMessage{Op: "delete", Path: path}
+		if in.log != nil {
+			if err := in.log.append(msg); err != nil {
+				return nil, err
+			}
+		}
+		if err := in.applyMessage(msg); err != nil {
+			return nil, err
+		}
+		in.dirty = true
+	}
+	return paths, nil
+}
+
+// mergedPaths is the union of two sets of paths
+func mergedPaths(a, b map[string]bool) map[string]bool {
+	if len(b) == 0 {
+		return a
+	}
+	merged := make(map[string]bool, len(a)+len(b))
+	for path := range a {
+		merged[path] = true
+	}
+	for path := range b {
+		merged[path] = true
+	}
+	return merged
+}
+
+// handleDelete answers POST /delete?query=... by deleting the documents the
+// query matches, listing them without deleting with dry_run=1
+func (in *ingester) handleDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		httpError(w, http.StatusMethodNotAllowed, fmt.Errorf("use POST"))
+		return
+	}
+	query, err := parseSelection(r.URL.Query().Get("query"))
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err)
+		return
+	}
+	dryRun := r.URL.Query().Get("dry_run") != ""
+	paths, err := in.deleteMatching(query, dryRun)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if dryRun {
+		writeJson(w, http.StatusOK, map[string]any{"matched": len(paths), "paths": paths})
+		return
+	}
+	writeJson(w, http.StatusOK, map[string]any{"deleted": len(paths), "paths": paths})
+}
@@ -0,0 +1,104 @@
+package sego
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestMatchingPaths(t *testing.T) {
+	config := newConfig()
+	m := newModel()
+	for path, content := range map[string]string{
+		"a.txt":     "this uses the deprecated-api still",
+		"b.txt":     "the api is fine",
+		"c.txt":     "nothing deprecated here",
+		"old/d.txt": "shaders and shadows",
+	} {
+		if err := m.apply(&IngestMessage{Path: path, Content: content}, config); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"deprecated-api", []string{"a.txt"}},
+		{"api", []string{"a.txt", "b.txt"}},
+		{"deprecated -api", []string{"c.txt"}},
+		{"shad*", []string{"old/d.txt"}},
+		{"path:old/** OR fine", []string{"b.txt", "old/d.txt"}},
+		{"missing", []string{}},
+	}
+	for _, test := range tests {
+		query, err := parseSelection(test.query)
+		if err != nil {
+			t.Fatalf("%q: %s", test.query, err)
+		}
+		got, err := m.matchingPaths(query, nil)
+		if err != nil {
+			t.Fatalf("%q: %s", test.query, err)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q matches %v, want %v", test.query, got, test.want)
+		}
+	}
+}
+
+func TestParseSelectionErrors(t *testing.T) {
+	for _, query := range []string{"", "OR api", "api OR", "AND"} {
+		if _, err := parseSelection(query); err == nil {
+			t.Errorf("%q parsed", query)
+		}
+	}
+}
+
+func TestDeleteMatchingBuffered(t *testing.T) {
+	dir := t.TempDir()
+	config := newConfig()
+	flushed := newModel()
+	if err := flushed.apply(&IngestMessage{Path: "flushed.txt", Content: "obsolete page"}, config); err != nil {
+		t.Fatal(err)
+	}
+	if err := flushed.saveSegmented(dir); err != nil {
+		t.Fatal(err)
+	}
+	w, err := openSegmentWriter(dir, config, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := &ingester{mu: w.mu, config: config, segments: w}
+	for _, msg := range []*IngestMessage{
+		{Path: "buffered.txt", Content: "obsolete draft"},
+		{Path: "kept.txt", Content: "current page"},
+	} {
+		if err := in.applyMessage(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	query, err := parseSelection("obsolete")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deleted, err := in.deleteMatching(query, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(deleted)
+	if want := []string{"buffered.txt", "flushed.txt"}; !reflect.DeepEqual(deleted, want) {
+		t.Fatalf("deleted %v, want %v", deleted, want)
+	}
+	left, err := in.deleteMatching(query, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(left) != 0 {
+		t.Errorf("%v still match after deleting", left)
+	}
+	if _, ok := w.buffer.model.document("kept.txt"); !ok {
+		t.Error("kept.txt was deleted")
+	}
+}
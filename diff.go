@@ -0,0 +1,134 @@
+package sego
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+)
+
+type termCount struct {
+	Term  string
+	Count int
+}
+
+// topTerms returns the terms of df not in other, most frequent first
+func topTerms(df DocFreq, other DocFreq, limit int) []termCount {
+	result := make([]termCount, 0)
+	for term, n := range df {
+		if _, ok := other[term]; !ok {
+			result = append(result, termCount{term, n})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Term < result[j].Term
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+func termFreqEqual(a, b TermFreq) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for term, n := range a {
+		if b[term] != n {
+			return false
+		}
+	}
+	return true
+}
+
+// diffModels writes the documents added, removed and changed between before and
+// after, followed by the terms that appeared or disappeared
+func diffModels(w io.Writer, before, after *Model, top int) {
+	// documents are compared by path, IDs differ between indexes
+	oldDocs := make(map[string]bool)
+	for _, path := range before.docPaths() {
+		oldDocs[path] = true
+	}
+
+	added, removed, changed := 0, 0, 0
+	for _, path := range after.docPaths() {
+		newID, _ := after.docID(path)
+		if !oldDocs[path] {
+			fmt.Fprintf(w, "+ %s (%d terms)\n", path, len(after.TF[newID]))
+			added++
+			continue
+		}
+		delete(oldDocs, path)
+
+		oldID, _ := before.docID(path)
+		oldTF, newTF := before.TF[oldID], after.TF[newID]
+		if termFreqEqual(oldTF, newTF) {
+			continue
+		}
+		gained, lost := 0, 0
+		for term := range newTF {
+			if _, ok := oldTF[term]; !ok {
+				gained++
+			}
+		}
+		for term := range oldTF {
+			if _, ok := newTF[term]; !ok {
+				lost++
+			}
+		}
+		fmt.Fprintf(w, "~ %s (+%d -%d terms, %d => %d tokens)\n", path, gained, lost,
+			before.docLength(oldID), after.docLength(newID))
+		changed++
+	}
+
+	for _, path := range before.docPaths() {
+		if oldDocs[path] {
+			fmt.Fprintf(w, "- %s\n", path)
+			removed++
+		}
+	}
+
+	fmt.Fprintf(w, "\n%d added, %d removed, %d changed documents\n", added, removed, changed)
+
+	newTerms := topTerms(after.DF, before.DF, 0)
+	oldTerms := topTerms(before.DF, after.DF, 0)
+	fmt.Fprintf(w, "%d => %d terms, %d new, %d gone\n", len(before.DF), len(after.DF), len(newTerms), len(oldTerms))
+
+	if top > 0 && len(newTerms) > 0 {
+		fmt.Fprintln(w, "\nnew top terms:")
+		for _, t := range topTerms(after.DF, before.DF, top) {
+			fmt.Fprintf(w, "  %s (df %d)\n", t.Term, t.Count)
+		}
+	}
+	if top > 0 && len(oldTerms) > 0 {
+		fmt.Fprintln(w, "\ngone top terms:")
+		for _, t := range topTerms(before.DF, after.DF, top) {
+			fmt.Fprintf(w, "  %s (df %d)\n", t.Term, t.Count)
+		}
+	}
+}
+
+func runDiff(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	top := fs.Int("top", 20, "how many new and gone terms to list")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		log.Fatal("usage: sego diff [-top n] <old index> <new index>")
+	}
+
+	before, err := newModelFromJson(fs.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	after, err := newModelFromJson(fs.Arg(1))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	diffModels(os.Stdout, before, after, *top)
+}
@@ -0,0 +1,165 @@
+package sego
+
+import (
+	"flag"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Documents are keyed by an ID that stays the same when the file, URL or row
+// they were indexed from moves. Indexes written before IDs existed used the
+// path as the key, those keys simply stay the IDs of their documents.
+
+// migrateIDs gives documents of older indexes their path and builds the path
+// lookup
+func (m *Model) migrateIDs() {
+	if m.Docs == nil {
+		m.Docs = make(map[string]*Document)
+	}
+	for id := range m.TF {
+		if doc, ok := m.Docs[id]; !ok || doc == nil {
+			m.Docs[id] = &Document{Path: id}
+		} else if doc.Path == "" {
+			doc.Path = id
+		}
+	}
+	m.paths = make(map[string]string, len(m.Docs))
+	for id, doc := range m.Docs {
+		if doc.Path == "" {
+			doc.Path = id
+		}
+		// a deleted document may have been replaced by one moved to its path
+		if other, ok := m.paths[doc.Path]; ok && m.Deleted[id] && !m.Deleted[other] {
+			continue
+		}
+		m.paths[doc.Path] = id
+	}
+}
+
+// docID returns the ID of the document indexed from path
+func (m *Model) docID(path string) (string, bool) {
+	id, ok := m.paths[path]
+	return id, ok
+}
+
+// assignID returns the ID of the document at path, allocating one if it is new
+func (m *Model) assignID(path string) string {
+	if id, ok := m.paths[path]; ok {
+		return id
+	}
+	if m.paths == nil {
+		m.paths = make(map[string]string)
+	}
+	for {
+		m.NextID++
+		id := strconv.FormatUint(m.NextID, 36)
+		// an older index might use it as a path
+		if _, taken := m.TF[id]; taken {
+			continue
+		}
+		if _, taken := m.Docs[id]; taken {
+			continue
+		}
+		m.paths[path] = id
+		return id
+	}
+}
+
+// docPath returns where the document with id was indexed from
+func (m *Model) docPath(id string) string {
+	if doc, ok := m.Docs[id]; ok && doc.Path != "" {
+		return doc.Path
+	}
+	return id
+}
+
+// document returns the metadata of the document indexed from path
+func (m *Model) document(path string) (*Document, bool) {
+	id, ok := m.paths[path]
+	if !ok {
+		return nil, false
+	}
+	doc, ok := m.Docs[id]
+	return doc, ok
+}
+
+// move re-paths the documents at from, or below it if from ends in a slash,
+// without reindexing them and returns how many moved
+func (m *Model) move(from, to string) int {
+	if from == to {
+		return 0
+	}
+	// paths are collected first, adding to a map while ranging over it may or
+	// may not visit what was added
+	moves := make(map[string]string)
+	for path := range m.paths {
+		switch {
+		case path == from:
+			moves[path] = to
+		case strings.HasPrefix(path, from+"#"):
+			moves[path] = to + strings.TrimPrefix(path, from)
+		case strings.HasSuffix(from, "/") && strings.HasPrefix(path, from):
+			moves[path] = to + strings.TrimPrefix(path, from)
+		}
+	}
+	ids := make(map[string]string, len(moves))
+	for path := range moves {
+		ids[path] = m.paths[path]
+		delete(m.paths, path)
+	}
+	// whatever else was indexed at the destinations is replaced, pages with
+	// all their sections, before any moved section has its new page as parent
+	for _, newPath := range moves {
+		page, _, _ := strings.Cut(newPath, "#")
+		m.removeDocument(page)
+		m.removeDocument(newPath)
+	}
+	for path, newPath := range moves {
+		id := ids[path]
+		m.paths[newPath] = id
+		m.Docs[id].Path = newPath
+		if m.Docs[id].Parent != "" {
+			m.Docs[id].Parent, _, _ = strings.Cut(newPath, "#")
+			m.sections = nil
+		}
+	}
+	if len(moves) > 0 {
+		m.version++
+	}
+	return len(moves)
+}
+
+func runMove(args []string) {
+	fs := flag.NewFlagSet("mv", flag.ExitOnError)
+	indexPath := fs.String("index", "index-new.json", "index to move documents in")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		log.Fatal("usage: sego mv [-index index.json] <old path> <new path>, with trailing slashes to move a folder")
+	}
+
+	model, err := newModelFromJson(*indexPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	moved := model.move(fs.Arg(0), fs.Arg(1))
+	if moved == 0 {
+		log.Fatalf("%s is not indexed", fs.Arg(0))
+	}
+	if err := model.saveAsJson(*indexPath); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Moved %d documents", moved)
+}
+
+// docPaths returns the paths of the documents that weren't deleted, sorted
+func (m *Model) docPaths() []string {
+	ids := m.docIDs()
+	paths := make([]string, 0, len(ids))
+	for _, id := range ids {
+		paths = append(paths, m.docPath(id))
+	}
+	sort.Strings(paths)
+	return paths
+}
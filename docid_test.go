@@ -0,0 +1,77 @@
+package sego
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestMove(t *testing.T) {
+	config := newConfig()
+	m := newModel()
+	for i := 0; i < 20; i++ {
+		if err := m.apply(&IngestMessage{Path: fmt.Sprintf("a/%02d.txt", i), Content: "text"}, config); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.apply(&IngestMessage{Path: "b.txt", Content: "other"}, config); err != nil {
+		t.Fatal(err)
+	}
+
+	// the new paths are below the old ones, none may move twice
+	if moved := m.move("a/", "a/old/"); moved != 20 {
+		t.Errorf("moved %d documents, want 20", moved)
+	}
+	paths := make([]string, 0)
+	for _, id := range m.docIDs() {
+		paths = append(paths, m.docPath(id))
+	}
+	sort.Strings(paths)
+	want := []string{"b.txt"}
+	for i := 0; i < 20; i++ {
+		want = append(want, fmt.Sprintf("a/old/%02d.txt", i))
+	}
+	sort.Strings(want)
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("paths after moving are %v, want %v", paths, want)
+	}
+
+	if moved := m.move("a/old/00.txt", "b.txt"); moved != 1 {
+		t.Errorf("moved %d documents, want 1", moved)
+	}
+	if doc, ok := m.document("b.txt"); !ok || m.TF[m.paths["b.txt"]]["TEXT"] == 0 {
+		t.Errorf("b.txt is %v, not the moved document", doc)
+	}
+	if n := len(m.docIDs()); n != 20 {
+		t.Errorf("%d documents after replacing b.txt, want 20", n)
+	}
+}
+
+func TestMoveSections(t *testing.T) {
+	config := newConfig()
+	config.Sections = 2
+	m := newModel()
+	for path, content := range map[string]string{
+		"guide.md": "# Guide\n\nintro\n\n## Install\n\nsteps\n\n## Usage\n\nflags\n",
+		"old.md":   "# Old\n\nreplaced\n\n## Usage\n\nold flags\n",
+	} {
+		if err := m.apply(&IngestMessage{Path: path, Content: content}, config); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if moved := m.move("guide.md", "old.md"); moved != 3 {
+		t.Errorf("moved %d sections, want 3", moved)
+	}
+	paths := make([]string, 0)
+	for _, id := range m.docIDs() {
+		paths = append(paths, m.docPath(id))
+		if doc := m.Docs[id]; doc.Parent != "old.md" {
+			t.Errorf("%s has parent %q", doc.Path, doc.Parent)
+		}
+	}
+	sort.Strings(paths)
+	if want := []string{"old.md#guide", "old.md#install", "old.md#usage"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("paths after moving are %v, want %v", paths, want)
+	}
+}
@@ -0,0 +1,276 @@
+package sego
+
+import (
+	"bufio"
+	"bytes"
+	"html"
+	"regexp"
+	"strings"
+	"time"
+
+	xhtml "golang.org/x/net/html"
+)
+
+// Document holds what we know about an indexed file besides its terms
+type Document struct {
+	// file, URL or key the document was indexed from, with the anchor of its
+	// heading for sections of a page
+	Path string `json:"path,omitempty"`
+	// path of the page a section or chunk is part of, and the number of the
+	// chunk
+	Parent string `json:"parent,omitempty"`
+	Chunk  int    `json:"chunk,omitempty"`
+	// of the content before extraction, to recognize moved files
+	Hash string `json:"hash,omitempty"`
+	// number of tokens
+	Length int `json:"length,omitempty"`
+	// rank multiplier, 0 means 1
+	Boost float32  `json:"boost,omitempty"`
+	Lang  string   `json:"lang,omitempty"`
+	Tags  []string `json:"tags,omitempty"`
+	// of the software documented, from the path, e.g. "gl3"
+	Version string `json:"version,omitempty"`
+	// fingerprint of the terms of a versioned document to find its copies in
+	// other versions
+	SimHash uint64 `json:"simhash,omitempty"`
+
+	Title       string     `json:"title,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Author      string     `json:"author,omitempty"`
+	URL         string     `json:"url,omitempty"`
+	Published   *time.Time `json:"published,omitempty"`
+	// when the file was last changed, kept for the freshness of documents
+	Modified *time.Time `json:"modified,omitempty"`
+	// feed the document came from
+	Feed string `json:"feed,omitempty"`
+	// the document drops out of results after this and is purged by compact
+	Expires *time.Time `json:"expires,omitempty"`
+
+	// validators of a crawled page for conditional requests when recrawling
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// expired reports whether the document's time is up at now
+func (d *Document) expired(now time.Time) bool {
+	return d.Expires != nil && !now.Before(*d.Expires)
+}
+
+// parseDate accepts RFC 3339 timestamps and plain dates
+func parseDate(s string) (time.Time, bool) {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+var (
+	htmlLangRe        = regexp.MustCompile(`(?i)<html[^>]*\slang\s*=\s*["']?([A-Za-z-]+)`)
+	metaKeywordsRe    = regexp.MustCompile(`(?i)<meta[^>]*name\s*=\s*["']?keywords["']?[^>]*content\s*=\s*["']([^"']*)["']`)
+	htmlTitleRe       = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	metaDescriptionRe = regexp.MustCompile(`(?i)<meta[^>]*name\s*=\s*["']?description["']?[^>]*content\s*=\s*["']([^"']*)["']`)
+	markdownTitleRe   = regexp.MustCompile(`(?m)^#[ \t]+(.+?)[ \t#]*$`)
+)
+
+// extractMetadata looks for the language, tags, title and description of a
+// document in the html lang attribute, title tag and meta tags, or in
+// Markdown-style front matter and the first heading. Without a description
+// the first sentence of the text is used.
+func extractMetadata(content []byte) *Document {
+	doc := &Document{}
+
+	if m := htmlLangRe.FindSubmatch(content); m != nil {
+		doc.Lang = string(m[1])
+	}
+	if m := metaKeywordsRe.FindSubmatch(content); m != nil {
+		doc.Tags = splitTags(string(m[1]))
+	}
+	if m := htmlTitleRe.FindSubmatch(content); m != nil {
+		doc.Title = cleanText(html.UnescapeString(string(m[1])))
+	}
+	if m := metaDescriptionRe.FindSubmatch(content); m != nil {
+		doc.Description = cleanText(html.UnescapeString(string(m[1])))
+	}
+
+	if bytes.HasPrefix(content, []byte("---\n")) {
+		scanner := bufio.NewScanner(bytes.NewReader(content[4:]))
+		for scanner.Scan() {
+			line := scanner.Text()
+			if line == "---" {
+				break
+			}
+			key, value, ok := strings.Cut(line, ":")
+			if !ok {
+				continue
+			}
+			value = strings.TrimSpace(value)
+			switch strings.TrimSpace(key) {
+			case "lang", "language":
+				doc.Lang = strings.Trim(value, `"'`)
+			case "tags", "keywords":
+				doc.Tags = splitTags(strings.Trim(value, "[]"))
+			case "expires", "expiry_date":
+				if expires, ok := parseDate(strings.Trim(value, `"'`)); ok {
+					doc.Expires = &expires
+				}
+			}
+		}
+	}
+
+	isHTML := looksLikeHTML(content)
+	if doc.Title == "" && !isHTML {
+		if m := markdownTitleRe.FindSubmatch(content); m != nil {
+			doc.Title = cleanText(string(m[1]))
+		}
+	}
+	if doc.Description == "" {
+		doc.Description = firstSentence(content, isHTML)
+	}
+
+	doc.Lang = strings.ToLower(doc.Lang)
+	return doc
+}
+
+func looksLikeHTML(content []byte) bool {
+	if len(content) > 1024 {
+		content = content[:1024]
+	}
+	start := bytes.ToLower(content)
+	return bytes.Contains(start, []byte("<html")) || bytes.Contains(start, []byte("<body")) ||
+		bytes.Contains(start, []byte("<!doctype html"))
+}
+
+// cleanText collapses whitespace
+func cleanText(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// descriptions made up from the text are cut at this many runes
+const maxDescription = 200
+
+// firstSentence of the text of a document, leaving out front matter and
+// headings, or of the body of an HTML page
+func firstSentence(content []byte, isHTML bool) string {
+	var text strings.Builder
+	if isHTML {
+		tokenizer := xhtml.NewTokenizer(bytes.NewReader(content))
+		skip := 0
+	tokens:
+		for text.Len() < 4*maxDescription {
+			switch tokenizer.Next() {
+			case xhtml.ErrorToken:
+				break tokens
+			case xhtml.StartTagToken:
+				switch name, _ := tokenizer.TagName(); string(name) {
+				case "head", "script", "style", "title", "nav", "h1", "h2", "h3":
+					skip++
+				}
+			case xhtml.EndTagToken:
+				switch name, _ := tokenizer.TagName(); string(name) {
+				case "head", "script", "style", "title", "nav", "h1", "h2", "h3":
+					if skip > 0 {
+						skip--
+					}
+				}
+			case xhtml.TextToken:
+				if skip == 0 {
+					text.Write(tokenizer.Text())
+					text.WriteByte(' ')
+				}
+			}
+		}
+	} else {
+		scanner := bufio.NewScanner(bytes.NewReader(content))
+		inFrontMatter := bytes.HasPrefix(content, []byte("---\n"))
+		first := true
+		for scanner.Scan() && text.Len() < 4*maxDescription {
+			line := strings.TrimSpace(scanner.Text())
+			if inFrontMatter {
+				if line == "---" && !first {
+					inFrontMatter = false
+				}
+				first = false
+				continue
+			}
+			if strings.HasPrefix(line, "#") {
+				continue
+			}
+			// the first paragraph is enough
+			if line == "" {
+				if text.Len() > 0 {
+					break
+				}
+				continue
+			}
+			text.WriteString(line)
+			text.WriteByte(' ')
+		}
+	}
+
+	s := cleanText(text.String())
+	for i, r := range s {
+		if (r == '.' || r == '!' || r == '?') && (i+1 == len(s) || s[i+1] == ' ') {
+			s = s[:i+1]
+			break
+		}
+	}
+	if runes := []rune(s); len(runes) > maxDescription {
+		s = strings.TrimSpace(string(runes[:maxDescription])) + "…"
+	}
+	return s
+}
+
+func splitTags(s string) []string {
+	tags := make([]string, 0)
+	for _, tag := range strings.Split(s, ",") {
+		tag = strings.ToLower(strings.Trim(strings.TrimSpace(tag), `"'`))
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func (d *Document) hasTag(tag string) bool {
+	for _, t := range d.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// merge takes over the metadata other has
+func (d *Document) merge(other *Document) {
+	if other == nil {
+		return
+	}
+	if other.Lang != "" {
+		d.Lang = strings.ToLower(other.Lang)
+	}
+	for _, tag := range other.Tags {
+		if tag = strings.ToLower(tag); !d.hasTag(tag) {
+			d.Tags = append(d.Tags, tag)
+		}
+	}
+	if other.Title != "" {
+		d.Title = other.Title
+	}
+	if other.Description != "" {
+		d.Description = other.Description
+	}
+	if other.Author != "" {
+		d.Author = other.Author
+	}
+	if other.URL != "" {
+		d.URL = other.URL
+	}
+	if other.Published != nil {
+		d.Published = other.Published
+	}
+	if other.Expires != nil {
+		d.Expires = other.Expires
+	}
+}
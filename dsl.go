@@ -0,0 +1,202 @@
+package sego
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// searchRequest is a query posted to /search as JSON instead of a string:
+//
+//	{"must": [{"term": "shader"}], "should": [{"phrase": "uniform buffer"}],
+//	 "must_not": [{"prefix": "deprec"}], "filters": [{"field": "lang", "value": "en"}],
+//	 "boosts": {"docs/faq.html": 2}, "from": 10, "size": 10,
+//	 "highlight": {"fragment_size": 100}}
+type searchRequest struct {
+	Must    []queryClause `json:"must"`
+	Should  []queryClause `json:"should"`
+	MustNot []queryClause `json:"must_not"`
+	// filters on the same field are ORed, different fields are ANDed
+	Filters []filterClause `json:"filters"`
+	// rank multipliers by path for this search only
+	Boosts map[string]float64 `json:"boosts"`
+	// results to skip and to return, 10 if 0
+	From int `json:"from"`
+	Size int `json:"size"`
+	// adds a fragment of the text with the terms marked to each result
+	Highlight *HighlightOptions `json:"highlight"`
+	Timing    bool              `json:"timing"`
+	// result fields to return, all if empty
+	Fields []string `json:"fields"`
+}
+
+// queryClause is one of its fields
+type queryClause struct {
+	Term   string        `json:"term,omitempty"`
+	Prefix string        `json:"prefix,omitempty"`
+	Phrase string        `json:"phrase,omitempty"`
+	Filter *filterClause `json:"filter,omitempty"`
+	// nested clauses, only their must, should, must_not and filters count
+	Bool *searchRequest `json:"bool,omitempty"`
+}
+
+type filterClause struct {
+	Field string `json:"field"`
+	Value string `json:"value"`
+}
+
+func (c *queryClause) node() (Node, error) {
+	set := 0
+	var node Node
+	if c.Term != "" {
+		set++
+		node = &TermNode{Term: c.Term}
+	}
+	if c.Prefix != "" {
+		set++
+		node = &TermNode{Term: c.Prefix, Prefix: true}
+	}
+	if c.Phrase != "" {
+		set++
+		node = &PhraseNode{Terms: strings.Fields(c.Phrase)}
+	}
+	if c.Filter != nil {
+		set++
+		node = &FilterNode{Field: c.Filter.Field, Value: c.Filter.Value}
+	}
+	if c.Bool != nil {
+		set++
+		var err error
+		if node, err = c.Bool.node(); err != nil {
+			return nil, err
+		}
+	}
+	if set != 1 {
+		return nil, fmt.Errorf("a clause needs exactly one of term, prefix, phrase, filter or bool")
+	}
+	return node, nil
+}
+
+func clauseNodes(clauses []queryClause) ([]Node, error) {
+	nodes := make([]Node, 0, len(clauses))
+	for i := range clauses {
+		node, err := clauses[i].node()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// node is the query tree of a request
+func (req *searchRequest) node() (Node, error) {
+	root := &BoolNode{}
+	var err error
+	if root.Must, err = clauseNodes(req.Must); err != nil {
+		return nil, err
+	}
+	if root.Should, err = clauseNodes(req.Should); err != nil {
+		return nil, err
+	}
+	if root.MustNot, err = clauseNodes(req.MustNot); err != nil {
+		return nil, err
+	}
+
+	byField := make(map[string]*BoolNode)
+	fields := make([]string, 0)
+	for _, filter := range req.Filters {
+		field := strings.ToLower(filter.Field)
+		if _, ok := byField[field]; !ok {
+			byField[field] = &BoolNode{}
+			fields = append(fields, field)
+		}
+		byField[field].Should = append(byField[field].Should, &FilterNode{Field: field, Value: filter.Value})
+	}
+	for _, field := range fields {
+		root.Must = append(root.Must, byField[field])
+	}
+	return root, nil
+}
+
+// handleSearchJSON answers a searchRequest posted to /search
+func (s *server) handleSearchJSON(w http.ResponseWriter, r *http.Request) {
+	span := tracing.startRemote(r.Header.Get("traceparent"), "POST /search")
+	defer span.finish()
+	var req searchRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		httpError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.From < 0 || req.Size < 0 {
+		httpError(w, http.StatusBadRequest, fmt.Errorf("invalid from %d or size %d", req.From, req.Size))
+		return
+	}
+	size := req.Size
+	if size == 0 {
+		size = 10
+	}
+	if s.maxResults > 0 && req.From+size > s.maxResults {
+		size = s.maxResults - req.From
+		if size < 0 {
+			size = 0
+		}
+	}
+	query, err := req.node()
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err)
+		return
+	}
+	fields, err := checkFields(req.Fields)
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err)
+		return
+	}
+	if wantsSnippets(fields) && req.Highlight == nil {
+		req.Highlight = &HighlightOptions{}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	results, timing, err := s.model.searchASTTimed(query)
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err)
+		return
+	}
+	if len(req.Boosts) > 0 {
+		for i := range results {
+			if b, ok := req.Boosts[results[i].Path]; ok {
+				results[i].Rank *= b
+			}
+		}
+		sort.Sort(results)
+	}
+	results = s.model.collapseVersions(results)
+	results = s.blocklist.get().filter(results)
+
+	response := searchResponse{Total: len(results)}
+	if req.From < len(results) {
+		results = results[req.From:]
+	} else {
+		results = results[:0]
+	}
+	if len(results) > size {
+		results = results[:size]
+	}
+	if req.Highlight != nil {
+		terms := make(map[string]bool)
+		for _, term := range query.terms(s.model) {
+			terms[term] = true
+		}
+		for i := range results {
+			results[i].Highlight = s.model.highlight(s.model.documentText(results[i].Path, s.config), terms, req.Highlight)
+		}
+	}
+	response.Results = results
+	if req.Timing {
+		response.Timing = timing
+	}
+	writeJson(w, http.StatusOK, response.respond(fields))
+}
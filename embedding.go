@@ -0,0 +1,190 @@
+package sego
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+// EmbeddingOptions say how to turn text into a vector, either with an OpenAI
+// compatible embeddings API (which local servers like Ollama offer too) or a
+// command reading the text on stdin and writing a JSON array of numbers
+type EmbeddingOptions struct {
+	// e.g. http://localhost:11434/v1/embeddings
+	URL   string `json:"url,omitempty"`
+	Model string `json:"model,omitempty"`
+	// environment variable with the API key, the key itself is never stored
+	KeyEnv  string   `json:"key_env,omitempty"`
+	Command []string `json:"command,omitempty"`
+	// longer texts are cut, default 8000 bytes
+	MaxInput int `json:"max_input,omitempty"`
+
+	// vector index lists, 0 means the square root of the number of
+	// documents, and how many of them a query looks at, 0 means 8
+	Lists  int `json:"lists,omitempty"`
+	Probes int `json:"probes,omitempty"`
+}
+
+var embeddingClient = &http.Client{Timeout: time.Minute}
+
+func (o *EmbeddingOptions) embed(text string) ([]float32, error) {
+	limit := o.MaxInput
+	if limit <= 0 {
+		limit = 8000
+	}
+	if len(text) > limit {
+		text = strings.ToValidUTF8(text[:limit], "")
+	}
+
+	var vector []float32
+	switch {
+	case len(o.Command) > 0:
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, o.Command[0], o.Command[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", o.Command[0], err)
+		}
+		if err := json.Unmarshal(out, &vector); err != nil {
+			return nil, fmt.Errorf("%s: %w", o.Command[0], err)
+		}
+	case o.URL != "":
+		body, err := json.Marshal(map[string]any{"model": o.Model, "input": text})
+		if err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequest(http.MethodPost, o.URL, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if o.KeyEnv != "" {
+			req.Header.Set("Authorization", "Bearer "+os.Getenv(o.KeyEnv))
+		}
+		resp, err := embeddingClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("%s: %s", o.URL, resp.Status)
+		}
+		var response struct {
+			Data []struct {
+				Embedding []float32 `json:"embedding"`
+			} `json:"data"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+			return nil, err
+		}
+		if len(response.Data) == 0 {
+			return nil, fmt.Errorf("%s: no embedding in response", o.URL)
+		}
+		vector = response.Data[0].Embedding
+	default:
+		return nil, fmt.Errorf("embedding needs a url or a command")
+	}
+
+	normalizeVector(vector)
+	return vector, nil
+}
+
+// normalizeVector scales v to unit length so cosine similarity is a dot product
+func normalizeVector(v []float32) {
+	var norm float64
+	for _, x := range v {
+		norm += float64(x) * float64(x)
+	}
+	norm = math.Sqrt(norm)
+	if norm == 0 {
+		return
+	}
+	for i := range v {
+		v[i] = float32(float64(v[i]) / norm)
+	}
+}
+
+func dotProduct(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
+
+// embedDocument stores the embedding of a document's text, documents that
+// can't be embedded are only found by their terms
+func (m *Model) embedDocument(id, text string, opts *EmbeddingOptions) {
+	vector, err := opts.embed(text)
+	if err != nil {
+		log.Printf("Embedding %s: %s", m.docPath(id), err)
+		delete(m.Vectors, id)
+		return
+	}
+	if m.Vectors == nil {
+		m.Vectors = make(map[string][]float32)
+	}
+	m.Vectors[id] = vector
+	m.Embedding = opts
+	if m.VectorIndex != nil {
+		m.VectorIndex.add(id, vector)
+	}
+}
+
+// embedQuery embeds the text of query like the documents of an index embedded
+// with o, nil if it has no embeddings
+func (o *EmbeddingOptions) embedQuery(query string) ([]float32, error) {
+	if o == nil {
+		return nil, fmt.Errorf("the index has no embeddings, index with an embedding config")
+	}
+	return o.embed(parseQuery(query).Text)
+}
+
+// hybridRank blends the ranks of results, scaled to 0..1 by the best one,
+// with the cosine similarity of the documents to the query embedded as
+// queryVector. weight 1 ranks by similarity alone.
+func (m *Model) hybridRank(results SearchResults, queryVector []float32, weight float64) {
+	// with an index only the nearest documents are compared with the query,
+	// the others count as not similar at all
+	var similarities map[string]float64
+	if m.VectorIndex != nil {
+		probes := m.Embedding.Probes
+		if probes <= 0 {
+			probes = 8
+		}
+		similarities = m.VectorIndex.similarities(queryVector, probes, m.Vectors)
+	}
+
+	best := 0.0
+	for _, r := range results {
+		best = math.Max(best, r.Rank)
+	}
+	for i, r := range results {
+		lexical := 0.0
+		if best > 0 {
+			lexical = r.Rank / best
+		}
+		var semantic float64
+		if similarities != nil {
+			semantic = similarities[r.ID]
+		} else {
+			semantic = dotProduct(queryVector, m.Vectors[r.ID])
+		}
+		results[i].Rank = (1-weight)*lexical + weight*semantic
+	}
+	sort.Sort(results)
+}
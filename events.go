@@ -0,0 +1,76 @@
+package sego
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// lifecycle events webhooks can subscribe to
+const (
+	EventIndexComplete = "index-complete"
+	EventReload        = "reload"
+	EventError         = "error"
+)
+
+// Webhook gets the events it subscribed to POSTed as JSON
+type Webhook struct {
+	URL string `json:"url"`
+	// empty means all events
+	Events []string `json:"events,omitempty"`
+}
+
+func (w *Webhook) wants(event string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+type Event struct {
+	Event string      `json:"event"`
+	Index string      `json:"index"`
+	Time  time.Time   `json:"time"`
+	Stats *IndexStats `json:"stats,omitempty"`
+	Error string      `json:"error,omitempty"`
+}
+
+// emit sends an event to the webhooks that want it. It waits for them so
+// events aren't lost when sego exits right after.
+func (c *Config) emit(event *Event) {
+	event.Time = time.Now()
+	var data []byte
+	for _, hook := range c.Webhooks {
+		if !hook.wants(event.Event) {
+			continue
+		}
+		if data == nil {
+			var err error
+			if data, err = json.Marshal(event); err != nil {
+				log.Printf("Event %s: %s", event.Event, err)
+				return
+			}
+		}
+		resp, err := webhookClient.Post(hook.URL, "application/json", bytes.NewReader(data))
+		if err != nil {
+			log.Printf("Webhook %s: %s", hook.URL, err)
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			log.Printf("Webhook %s: %s", hook.URL, resp.Status)
+		}
+	}
+}
+
+// fatal reports an error event for the index before exiting
+func (c *Config) fatal(index string, err error) {
+	c.emit(&Event{Event: EventError, Index: index, Error: err.Error()})
+	log.Fatal(err)
+}
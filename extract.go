@@ -0,0 +1,91 @@
+package sego
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+)
+
+// extractor turns a file format into text the analyzers can work with
+type extractor func(content []byte, config *Config) ([]byte, error)
+
+var extractors = map[string]extractor{
+	".ipynb": extractNotebook,
+	".tex":   extractLatex,
+	".rst":   extractRST,
+}
+
+// ExternalExtractor is a command that gets a file on stdin and writes a JSON
+// object with metadata (title, author, lang, tags, url, published) on the
+// first line of stdout followed by the UTF-8 text of the file:
+//
+//	{"title": "Quarterly report", "tags": ["finance"]}
+//	Revenue grew ...
+//
+// The file's path and MIME type are passed in SEGO_PATH and SEGO_MIME_TYPE.
+type ExternalExtractor struct {
+	Command []string `json:"command"`
+	// default 1m
+	Timeout string `json:"timeout"`
+}
+
+func (e *ExternalExtractor) run(filePath, mediaType string, content []byte) ([]byte, *Document, error) {
+	if len(e.Command) == 0 {
+		return nil, nil, fmt.Errorf("extractor for %s has no command", mediaType)
+	}
+	timeout := time.Minute
+	if e.Timeout != "" {
+		var err error
+		if timeout, err = time.ParseDuration(e.Timeout); err != nil {
+			return nil, nil, err
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, e.Command[0], e.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(content)
+	cmd.Env = append(cmd.Environ(), "SEGO_PATH="+filePath, "SEGO_MIME_TYPE="+mediaType)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w: %s", e.Command[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	reader := bufio.NewReader(bytes.NewReader(out))
+	header, err := reader.ReadBytes('\n')
+	if err != nil && len(header) == 0 {
+		return nil, nil, fmt.Errorf("%s: no output", e.Command[0])
+	}
+	meta := &Document{}
+	if err := json.Unmarshal(header, meta); err != nil {
+		return nil, nil, fmt.Errorf("%s: first line isn't a JSON object: %w", e.Command[0], err)
+	}
+	return out[len(header):], meta, nil
+}
+
+// extractText runs the external extractor configured for the file's MIME
+// type or the built-in one for its extension, if any. Metadata is only
+// returned by external extractors.
+func extractText(filePath string, content []byte, config *Config) ([]byte, *Document, error) {
+	if len(config.Extractors) > 0 {
+		mediaType := mediaTypeOf(filePath, content)
+		if e, ok := config.Extractors[mediaType]; ok {
+			return e.run(filePath, mediaType, content)
+		}
+	}
+
+	extract, ok := extractors[strings.ToLower(path.Ext(filePath))]
+	if !ok {
+		return content, nil, nil
+	}
+	text, err := extract(content, config)
+	return text, nil, err
+}
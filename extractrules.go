@@ -0,0 +1,138 @@
+package sego
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	xhtml "golang.org/x/net/html"
+)
+
+// ExtractRule picks the parts of the HTML pages of a site, or some of its
+// paths, to index, like {"site": "docs.gl", "content": "main .entry",
+// "exclude": ".sidebar"}
+type ExtractRule struct {
+	// host of crawled pages, subdomains included, or a folder of local files
+	Site string `json:"site"`
+	// glob of the paths on the site, or of all paths without a site, like
+	// "gl4/**"
+	Path string `json:"path"`
+	// CSS selector of the elements to index, all of the page if empty
+	Content string `json:"content"`
+	// CSS selector of elements within those to leave out
+	Exclude string `json:"exclude"`
+
+	// Path compiled by check
+	pathRe *regexp.Regexp
+}
+
+// check validates the rule's selectors and path glob, so mistakes fail when
+// the config is loaded rather than on the first page the rule is for
+func (r *ExtractRule) check() error {
+	for _, s := range []string{r.Content, r.Exclude} {
+		if s == "" {
+			continue
+		}
+		if _, err := cachedSelector(s); err != nil {
+			return fmt.Errorf("extract rule: %w", err)
+		}
+	}
+	if r.Path == "" {
+		return nil
+	}
+	var err error
+	r.pathRe, err = r.compilePath()
+	return err
+}
+
+func (r *ExtractRule) compilePath() (*regexp.Regexp, error) {
+	glob := r.Path
+	if !strings.HasPrefix(glob, "/") {
+		glob = "**/" + glob
+	}
+	re, err := globToRegexp(glob)
+	if err != nil {
+		return nil, fmt.Errorf("extract rule path %q: %w", r.Path, err)
+	}
+	return re, nil
+}
+
+// matches reports whether the rule is for the document at docPath, a URL or
+// file path
+func (r *ExtractRule) matches(docPath string) (bool, error) {
+	rest := docPath
+	if r.Site != "" {
+		if u, err := url.Parse(docPath); err == nil && u.Host != "" {
+			host := u.Hostname()
+			if host != r.Site && !strings.HasSuffix(host, "."+r.Site) {
+				return false, nil
+			}
+			rest = u.Path
+		} else {
+			i := strings.Index("/"+docPath+"/", "/"+r.Site+"/")
+			if i < 0 {
+				return false, nil
+			}
+			rest = docPath[i+len(r.Site):]
+		}
+	}
+	if r.Path == "" {
+		return true, nil
+	}
+	re := r.pathRe
+	if re == nil {
+		var err error
+		if re, err = r.compilePath(); err != nil {
+			return false, err
+		}
+	}
+	return re.MatchString(rest), nil
+}
+
+// extractRule is the first rule for the document at docPath, nil if none is
+func (c *Config) extractRule(docPath string) (*ExtractRule, error) {
+	for i := range c.ExtractRules {
+		ok, err := c.ExtractRules[i].matches(docPath)
+		if err != nil || ok {
+			return &c.ExtractRules[i], err
+		}
+	}
+	return nil, nil
+}
+
+// keepOnly replaces the body of doc with the elements content matches, in
+// the order they appear, so only they are indexed. The head stays for the
+// title and metadata. Pages without such elements are left whole.
+func keepOnly(doc *xhtml.Node, content selector) {
+	var body *xhtml.Node
+	kept := make([]*xhtml.Node, 0)
+	var walk func(n *xhtml.Node)
+	walk = func(n *xhtml.Node) {
+		if n.Type == xhtml.ElementNode {
+			if n.Data == "body" && body == nil {
+				body = n
+			}
+			if content.matches(n) {
+				kept = append(kept, n)
+				return
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(doc)
+	if body == nil || len(kept) == 0 {
+		return
+	}
+	for _, n := range kept {
+		n.Parent.RemoveChild(n)
+	}
+	for body.FirstChild != nil {
+		body.RemoveChild(body.FirstChild)
+	}
+	for _, n := range kept {
+		body.AppendChild(n)
+	}
+}
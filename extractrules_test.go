@@ -0,0 +1,51 @@
+package sego
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckExtractRules(t *testing.T) {
+	configs := map[string]string{
+		"content selector":  `{"extract_rules": [{"site": "docs.gl", "content": "main >"}]}`,
+		"exclude selector":  `{"extract_rules": [{"site": "docs.gl", "exclude": "[class"}]}`,
+		"path glob":         `{"extract_rules": [{"path": "gl[z-a]/**"}]}`,
+		"exclude_selectors": `{"exclude_selectors": [".ad,"]}`,
+	}
+	dir := t.TempDir()
+	for name, content := range configs {
+		path := filepath.Join(dir, "config.json")
+		if err := os.WriteFile(path, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadConfig(path); err == nil {
+			t.Errorf("config with an invalid %s loaded", name)
+		}
+	}
+
+	path := filepath.Join(dir, "config.json")
+	valid := `{"extract_rules": [{"site": "docs.gl", "path": "gl4/**", "content": "main .entry", "exclude": ".sidebar"}]}`
+	if err := os.WriteFile(path, []byte(valid), 0666); err != nil {
+		t.Fatal(err)
+	}
+	config, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for docPath, want := range map[string]bool{
+		"https://docs.gl/gl4/glClear":     true,
+		"https://docs.gl/es3/glClear":     false,
+		"https://www.docs.gl/gl4/glClear": true,
+		"https://example.com/gl4/glClear": false,
+		"docs.gl/gl4/glClear.xhtml":       true,
+	} {
+		rule, err := config.extractRule(docPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := rule != nil; got != want {
+			t.Errorf("%s has a rule: %v, want %v", docPath, got, want)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package sego
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// feed covers both RSS 2.0 (channel/item) and Atom (feed/entry)
+type feed struct {
+	Items   []feedItem `xml:"channel>item"`
+	Entries []feedItem `xml:"entry"`
+}
+
+type feedLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Text string `xml:",chardata"`
+}
+
+type feedCategory struct {
+	Term string `xml:"term,attr"`
+	Text string `xml:",chardata"`
+}
+
+type feedItem struct {
+	Title       string         `xml:"title"`
+	Links       []feedLink     `xml:"link"`
+	ID          string         `xml:"id"`
+	GUID        string         `xml:"guid"`
+	Description string         `xml:"description"`
+	Summary     string         `xml:"summary"`
+	Content     string         `xml:"content"`
+	Encoded     string         `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	PubDate     string         `xml:"pubDate"`
+	Published   string         `xml:"published"`
+	Updated     string         `xml:"updated"`
+	Categories  []feedCategory `xml:"category"`
+}
+
+func (item *feedItem) link() string {
+	for _, l := range item.Links {
+		if l.Href != "" && (l.Rel == "" || l.Rel == "alternate") {
+			return l.Href
+		}
+		if text := strings.TrimSpace(l.Text); text != "" {
+			return text
+		}
+	}
+	return ""
+}
+
+// key identifies the entry in the index, its link or else its id
+func (item *feedItem) key(feedURL string) string {
+	for _, key := range []string{item.link(), item.GUID, item.ID} {
+		if key = strings.TrimSpace(key); key != "" {
+			return key
+		}
+	}
+	return fmt.Sprintf("%s#%s", feedURL, item.Title)
+}
+
+func (item *feedItem) text() string {
+	body := item.Encoded
+	for _, s := range []string{item.Content, item.Description, item.Summary} {
+		if len(s) > len(body) {
+			body = s
+		}
+	}
+	return item.Title + "\n" + body
+}
+
+var feedDateLayouts = []string{
+	time.RFC3339,
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC822Z,
+	time.RFC822,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+}
+
+func (item *feedItem) published() *time.Time {
+	for _, s := range []string{item.PubDate, item.Published, item.Updated} {
+		s = strings.TrimSpace(s)
+		for _, layout := range feedDateLayouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				return &t
+			}
+		}
+	}
+	return nil
+}
+
+// openFeed fetches an http(s) feed or opens a local feed file
+func openFeed(feedURL string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(feedURL, "http://") && !strings.HasPrefix(feedURL, "https://") {
+		return os.Open(feedURL)
+	}
+
+	resp, err := http.Get(feedURL)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: status %d", feedURL, resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+// indexFeed adds every entry of an RSS or Atom feed as a document
+func (m *Model) indexFeed(feedURL string, config *Config) error {
+	body, err := openFeed(feedURL)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	var f feed
+	if err := xml.NewDecoder(body).Decode(&f); err != nil {
+		return fmt.Errorf("%s: %w", feedURL, err)
+	}
+
+	m.prepareIndexing(config)
+
+	for _, item := range append(f.Items, f.Entries...) {
+		key := item.key(feedURL)
+		m.Stats.Files++
+		log.Printf("Indexing: %s", key)
+		if err := m.indexDocument(key, []byte(item.text()), "", config, m.Stats); err != nil {
+			return err
+		}
+
+		doc, ok := m.document(key)
+		if !ok {
+			continue
+		}
+		doc.Title = strings.TrimSpace(item.Title)
+		doc.URL = item.link()
+		doc.Published = item.published()
+		doc.Feed = feedURL
+		for _, c := range item.Categories {
+			tag := c.Term
+			if tag == "" {
+				tag = c.Text
+			}
+			if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" && !doc.hasTag(tag) {
+				doc.Tags = append(doc.Tags, tag)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package sego
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// names clients may use for result fields besides their JSON names
+var resultFieldAliases = map[string]string{
+	"score":   "rank",
+	"snippet": "highlight",
+}
+
+// resultFields maps the JSON names of the fields of SearchResult to their
+// index
+var resultFields = func() map[string]int {
+	fields := make(map[string]int)
+	t := reflect.TypeOf(SearchResult{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		fields[name] = i
+	}
+	return fields
+}()
+
+// parseFields checks a comma separated list of result fields, nil means all
+func parseFields(list string) ([]string, error) {
+	if list == "" {
+		return nil, nil
+	}
+	return checkFields(strings.Split(list, ","))
+}
+
+func checkFields(fields []string) ([]string, error) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	for _, field := range fields {
+		field = strings.TrimSpace(field)
+		if _, ok := resultFields[field]; !ok && resultFieldAliases[field] == "" {
+			return nil, fmt.Errorf("unknown result field %q", field)
+		}
+	}
+	return fields, nil
+}
+
+// wantsSnippets reports whether fields ask for highlights
+func wantsSnippets(fields []string) bool {
+	for _, field := range fields {
+		if field = strings.TrimSpace(field); field == "snippet" || field == "highlight" {
+			return true
+		}
+	}
+	return false
+}
+
+// project keeps only fields of each result, named as they were asked for
+func (results SearchResults) project(fields []string) []map[string]any {
+	projected := make([]map[string]any, len(results))
+	for i := range results {
+		v := reflect.ValueOf(results[i])
+		projected[i] = make(map[string]any, len(fields))
+		for _, field := range fields {
+			field = strings.TrimSpace(field)
+			name := field
+			if alias, ok := resultFieldAliases[field]; ok {
+				name = alias
+			}
+			projected[i][field] = v.Field(resultFields[name]).Interface()
+		}
+	}
+	return projected
+}
+
+// projectedResponse is a searchResponse with only some result fields
+type projectedResponse struct {
+	Query    string           `json:"query"`
+	Total    int              `json:"total"`
+	Results  []map[string]any `json:"results"`
+	Timing   *SearchTiming    `json:"timing,omitempty"`
+	Clusters []Cluster        `json:"clusters,omitempty"`
+	Cursor   string           `json:"cursor,omitempty"`
+}
+
+// respond is the response with only fields of the results, all if nil
+func (r *searchResponse) respond(fields []string) any {
+	if fields == nil {
+		return r
+	}
+	return projectedResponse{
+		Query:    r.Query,
+		Total:    r.Total,
+		Results:  r.Results.project(fields),
+		Timing:   r.Timing,
+		Clusters: r.Clusters,
+		Cursor:   r.Cursor,
+	}
+}
+
+// snippets adds highlights with default options to results, reading their
+// documents with config
+func (m *Model) snippets(results SearchResults, query string, config *Config) {
+	terms := make(map[string]bool)
+	for _, term := range tokenize(parseQuery(query).Text, m.Lexer) {
+		terms[term] = true
+	}
+	for i := range results {
+		results[i].Highlight = m.highlight(m.documentText(results[i].Path, config), terms, &HighlightOptions{})
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !unix
+
+package sego
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// fileKey identifies a file independently of the path it was reached by,
+// without inodes the best we can do is resolving all symlinks
+func fileKey(path string, info os.FileInfo) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		if abs, err := filepath.Abs(resolved); err == nil {
+			return abs
+		}
+	}
+	return path
+}
@@ -0,0 +1,190 @@
+package sego
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// builtinFilters make token filters from the arguments after their name, so
+// analyzers can list e.g. "lowercase", "ascii-fold", "stop", "stem", "length:3"
+var builtinFilters = map[string]func(args []string) (TokenFilter, error){
+	"lowercase":  noArgs(mapTokens(strings.ToLower)),
+	"uppercase":  noArgs(mapTokens(strings.ToUpper)),
+	"ascii-fold": noArgs(mapTokens(asciiFold)),
+	"translit":   noArgs(mapTokens(transliterate)),
+	"stem":       stemFilter,
+	"soundex":    noArgs(phoneticFilter(soundex)),
+	"metaphone":  noArgs(phoneticFilter(metaphone)),
+	"drop":       noArgs(dropTokens),
+	"stop":       stopFilter,
+	"length":     lengthFilter,
+}
+
+// the filters named so far by their full name including arguments
+var filterCache sync.Map
+
+func noArgs(filter TokenFilter) func([]string) (TokenFilter, error) {
+	return func(args []string) (TokenFilter, error) {
+		if len(args) > 0 {
+			return nil, fmt.Errorf("takes no arguments")
+		}
+		return filter, nil
+	}
+}
+
+func mapTokens(f func(string) string) TokenFilter {
+	return func(tokens []string) []string {
+		for i, token := range tokens {
+			tokens[i] = f(token)
+		}
+		return tokens
+	}
+}
+
+// lookupFilter finds a filter by name, "name:arg:arg" passes arguments to a
+// built-in one
+func lookupFilter(spec string) (TokenFilter, error) {
+	if filter, ok := filterCache.Load(spec); ok {
+		return filter.(TokenFilter), nil
+	}
+	if filter, ok := tokenFilters[spec]; ok {
+		return filter, nil
+	}
+	parts := strings.Split(spec, ":")
+	build, ok := builtinFilters[parts[0]]
+	if !ok {
+		return nil, fmt.Errorf("unknown filter %q, is its plugin configured?", spec)
+	}
+	filter, err := build(parts[1:])
+	if err != nil {
+		return nil, fmt.Errorf("filter %q: %w", spec, err)
+	}
+	filterCache.Store(spec, filter)
+	return filter, nil
+}
+
+// asciiFold drops accents and maps letters like "ß" and "ø" to ASCII
+func asciiFold(token string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(token) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		if s, ok := asciiLetters[r]; ok {
+			b.WriteString(s)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// letters that don't decompose into an ASCII letter and marks
+var asciiLetters = map[rune]string{
+	'ß': "ss", 'ẞ': "SS", 'æ': "ae", 'Æ': "AE", 'œ': "oe", 'Œ': "OE",
+	'ø': "o", 'Ø': "O", 'đ': "d", 'Đ': "D", 'ł': "l", 'Ł': "L",
+	'þ': "th", 'Þ': "TH", 'ð': "d", 'Ð': "D", 'ı': "i",
+}
+
+// stemToken stems English words whatever their case, keeping it
+func stemToken(token string) string {
+	lower := strings.ToLower(token)
+	stem := porterStem(lower)
+	if stem == lower {
+		return token
+	}
+	if token == strings.ToUpper(token) {
+		return strings.ToUpper(stem)
+	}
+	return stem
+}
+
+var englishStopwords = strings.Fields(`a an and are as at be but by for if in into is it
+	no not of on or such that the their then there these they this to was will with`)
+
+// stopFilter drops the built-in English stopwords, those of the language
+// given as argument, "stop:de", or those listed one per line in the file
+// given instead, ignoring case
+func stopFilter(args []string) (TokenFilter, error) {
+	words := englishStopwords
+	switch len(args) {
+	case 0:
+	case 1:
+		if bundle, ok := languages[args[0]]; ok {
+			words = bundle.stopwords
+			break
+		}
+		var err error
+		if words, err = readWordList(args[0]); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("takes a language or stopword file at most")
+	}
+
+	stop := make(map[string]bool)
+	for _, word := range words {
+		stop[strings.ToLower(word)] = true
+	}
+	return func(tokens []string) []string {
+		kept := tokens[:0]
+		for _, token := range tokens {
+			if !stop[strings.ToLower(token)] {
+				kept = append(kept, token)
+			}
+		}
+		return kept
+	}, nil
+}
+
+// readWordList reads a file of one word per line, # starts a comment
+func readWordList(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	words := make([]string, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		if word := strings.TrimSpace(line); word != "" {
+			words = append(words, word)
+		}
+	}
+	return words, scanner.Err()
+}
+
+// lengthFilter keeps tokens of min to max runes, "length:3" or "length:3:40"
+func lengthFilter(args []string) (TokenFilter, error) {
+	if len(args) == 0 || len(args) > 2 {
+		return nil, fmt.Errorf("takes a minimum and optionally a maximum length")
+	}
+	min, err := strconv.Atoi(args[0])
+	if err != nil {
+		return nil, err
+	}
+	max := 0
+	if len(args) == 2 {
+		if max, err = strconv.Atoi(args[1]); err != nil {
+			return nil, err
+		}
+	}
+	return func(tokens []string) []string {
+		kept := tokens[:0]
+		for _, token := range tokens {
+			n := len([]rune(token))
+			if n >= min && (max == 0 || n <= max) {
+				kept = append(kept, token)
+			}
+		}
+		return kept
+	}, nil
+}
@@ -0,0 +1,38 @@
+package sego
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilterPipeline(t *testing.T) {
+	tests := []struct {
+		filters []string
+		text    string
+		want    []string
+	}{
+		{nil, "The Café", []string{"THE", "CAFÉ"}},
+		{[]string{"lowercase"}, "The Café", []string{"the", "café"}},
+		{[]string{"lowercase", "ascii-fold"}, "The Café Straße", []string{"the", "cafe", "strasse"}},
+		{[]string{"lowercase", "ascii-fold", "stop", "stem", "length:4"}, "The running shoes of the café", []string{"shoe", "cafe"}},
+		{[]string{"stop:de", "stem:de"}, "die Katzen", []string{"KATZ"}},
+		{[]string{"length:2:3"}, "a be see four", []string{"BE", "SEE"}},
+	}
+	for _, test := range tests {
+		opts := LexerOptions{Filters: test.filters}
+		if err := opts.checkFilters(); err != nil {
+			t.Fatalf("%v: %s", test.filters, err)
+		}
+		if got := tokenize(test.text, opts); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: %q analyzed to %q, want %q", test.filters, test.text, got, test.want)
+		}
+	}
+}
+
+func TestUnknownFilters(t *testing.T) {
+	for _, spec := range []string{"nope", "length", "length:x", "length:1:2:3", "stem:xx", "lowercase:1", "stop:a:b"} {
+		if _, err := lookupFilter(spec); err == nil {
+			t.Errorf("%s was found", spec)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package sego
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+)
+
+// analysisVersion changes whenever built-in tokenizing or filters turn text
+// into other terms than before, so indexes know they were analyzed the old
+// way
+const analysisVersion = 3
+
+// unicodeCaseVersion is the analysis version tokens are upper cased with full
+// Unicode casing from, "straße" became "STRASSE" instead of "STRAßE"
+const unicodeCaseVersion = 3
+
+// keepAnalysis makes the lexer and analyzers of an index built with an older
+// analysis version tokenize text like they did then, so its terms still match
+func (m *Model) keepAnalysis() {
+	if m.Analysis >= unicodeCaseVersion {
+		return
+	}
+	m.Lexer.runeCase = true
+	for name, opts := range m.Analyzers {
+		opts.runeCase = true
+		m.Analyzers[name] = opts
+	}
+}
+
+// analyzerFingerprint hashes everything deciding which terms text is analyzed
+// to as it would be now: the lexer and analyzers with the contents of their
+// dictionaries, the plugins providing filters and the version of the built-in
+// analysis
+func (m *Model) analyzerFingerprint() (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "sego analysis %d\n", analysisVersion)
+	options, err := json.Marshal(struct {
+		Lexer     LexerOptions            `json:"lexer"`
+		Analyzers map[string]LexerOptions `json:"analyzers"`
+	}{m.Lexer, m.Analyzers})
+	if err != nil {
+		return "", err
+	}
+	h.Write(options)
+	names := make([]string, 0, len(m.Analyzers))
+	for name := range m.Analyzers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	hashDictionary(h, m.Lexer.Dictionary)
+	for _, name := range names {
+		hashDictionary(h, m.Analyzers[name].Dictionary)
+	}
+	for _, path := range m.Plugins {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		sum := sha256.Sum256(content)
+		fmt.Fprintf(h, "\n%s", sum[:])
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16]), nil
+}
+
+// hashDictionary adds the words of a dictionary to h, a dictionary that can't
+// be read breaks words like none
+func hashDictionary(h io.Writer, path string) {
+	if path == "" {
+		return
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(h, "\nno dictionary %s", path)
+		return
+	}
+	sum := sha256.Sum256(content)
+	fmt.Fprintf(h, "\ndictionary %s", sum[:])
+}
+
+// stampAnalyzer records the fingerprint of the analyzers documents are about
+// to be indexed with. An index that already has documents analyzed otherwise
+// keeps its fingerprint, so queries keep warning about them until the index
+// is built again from scratch.
+func (m *Model) stampAnalyzer() {
+	fingerprint, err := m.analyzerFingerprint()
+	if err != nil {
+		log.Printf("Fingerprinting analyzers: %s", err)
+		return
+	}
+	if len(m.TF) > 0 && m.AnalyzerHash != fingerprint {
+		if m.AnalyzerHash != "" {
+			log.Printf("Warning: the index has documents analyzed with other analyzers, queries may miss them until the index is built again without -update")
+		}
+		return
+	}
+	m.AnalyzerHash = fingerprint
+}
+
+// checkAnalyzer compares the analyzers queries will use with those the index
+// was built with. A mismatch is logged, or returned if strict.
+func (m *Model) checkAnalyzer(strict bool) error {
+	if m.AnalyzerHash == "" {
+		return nil
+	}
+	fingerprint, err := m.analyzerFingerprint()
+	if err != nil {
+		return err
+	}
+	if fingerprint == m.AnalyzerHash {
+		return nil
+	}
+	err = fmt.Errorf("analyzers differ from those the index was built with (%s, now %s), queries may not match its terms", m.AnalyzerHash, fingerprint)
+	if strict {
+		return err
+	}
+	log.Printf("Warning: %s", err)
+	return nil
+}
@@ -0,0 +1,81 @@
+package sego
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFingerprintDictionary(t *testing.T) {
+	dict := filepath.Join(t.TempDir(), "words.txt")
+	if err := os.WriteFile(dict, []byte("สวัสดี\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	m := newModel()
+	m.Lexer.Dictionary = dict
+	before, err := m.analyzerFingerprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dict, []byte("สวัสดี\nครับ\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	after, err := m.analyzerFingerprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before == after {
+		t.Error("changing the dictionary kept the fingerprint")
+	}
+}
+
+func TestStampPartialUpdate(t *testing.T) {
+	config := newConfig()
+	m := newModel()
+	m.setupAnalyzers(config)
+	built := m.AnalyzerHash
+	if built == "" {
+		t.Fatal("empty index wasn't stamped")
+	}
+	if err := m.apply(&IngestMessage{Path: "a.txt", Content: "shader"}, config); err != nil {
+		t.Fatal(err)
+	}
+
+	config.Analyzers = map[string]LexerOptions{"code": {Identifiers: true}}
+	m.setupAnalyzers(config)
+	if m.AnalyzerHash != built {
+		t.Error("update with other analyzers restamped the index")
+	}
+	if err := m.checkAnalyzer(true); err == nil {
+		t.Error("mixed index passed the strict check")
+	}
+}
+
+func TestKeepAnalysisCasing(t *testing.T) {
+	config := newConfig()
+	old := newModel()
+	old.Analysis = 0
+	old.keepAnalysis()
+	if err := old.apply(&IngestMessage{Path: "a.txt", Content: "Straße"}, config); err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := parseModel(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tokenize("straße", loaded.Lexer); !reflect.DeepEqual(got, []string{"STRAßE"}) {
+		t.Errorf("old index tokenizes %v", got)
+	}
+	if results, _ := loaded.searchTimed("straße"); len(results) != 1 {
+		t.Errorf("old index found %d documents", len(results))
+	}
+	if got := tokenize("straße", newModel().Lexer); !reflect.DeepEqual(got, []string{"STRASSE"}) {
+		t.Errorf("new index tokenizes %v", got)
+	}
+}
@@ -0,0 +1,172 @@
+package sego
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// gateway fans queries out to several sego servers and merges their results
+type gateway struct {
+	backends []string
+	client   *http.Client
+}
+
+type gatewayResult struct {
+	Path    string  `json:"path"`
+	Rank    float64 `json:"rank"`
+	Backend string  `json:"backend"`
+}
+
+type gatewayResponse struct {
+	Query   string          `json:"query"`
+	Total   int             `json:"total"`
+	Results []gatewayResult `json:"results"`
+	// backends that failed, the results are from the others
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+func (g *gateway) query(backend, query string, n int) (*searchResponse, error) {
+	u := strings.TrimSuffix(backend, "/") + "/search?" + url.Values{
+		"q": {query},
+		"n": {strconv.Itoa(n)},
+	}.Encode()
+	resp, err := g.client.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s", resp.Status)
+	}
+	var response searchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// normalize maps a backend's ranks onto 0..1 so scores computed over
+// different corpora can be compared
+func normalize(results SearchResults) []float64 {
+	ranks := make([]float64, len(results))
+	if len(results) == 0 {
+		return ranks
+	}
+	lo, hi := results[0].Rank, results[0].Rank
+	for _, r := range results {
+		if r.Rank < lo {
+			lo = r.Rank
+		}
+		if r.Rank > hi {
+			hi = r.Rank
+		}
+	}
+	for i, r := range results {
+		if hi == lo {
+			// nothing to tell them apart, but a backend without a positive
+			// score didn't really match
+			if hi > 0 {
+				ranks[i] = 1
+			}
+		} else {
+			ranks[i] = (r.Rank - lo) / (hi - lo)
+		}
+	}
+	return ranks
+}
+
+func (g *gateway) search(query string, n int) *gatewayResponse {
+	responses := make([]*searchResponse, len(g.backends))
+	errs := make([]error, len(g.backends))
+	var wg sync.WaitGroup
+	for i, backend := range g.backends {
+		wg.Add(1)
+		go func(i int, backend string) {
+			defer wg.Done()
+			responses[i], errs[i] = g.query(backend, query, n)
+		}(i, backend)
+	}
+	wg.Wait()
+
+	merged := &gatewayResponse{Query: query, Results: make([]gatewayResult, 0)}
+	for i, response := range responses {
+		if errs[i] != nil {
+			if merged.Errors == nil {
+				merged.Errors = make(map[string]string)
+			}
+			merged.Errors[g.backends[i]] = errs[i].Error()
+			continue
+		}
+		merged.Total += response.Total
+		for j, rank := range normalize(response.Results) {
+			merged.Results = append(merged.Results, gatewayResult{
+				Path:    response.Results[j].Path,
+				Rank:    rank,
+				Backend: g.backends[i],
+			})
+		}
+	}
+	sort.Slice(merged.Results, func(i, j int) bool {
+		a, b := merged.Results[i], merged.Results[j]
+		if a.Rank != b.Rank {
+			return a.Rank > b.Rank
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Backend < b.Backend
+	})
+	if len(merged.Results) > n {
+		merged.Results = merged.Results[:n]
+	}
+	return merged
+}
+
+func (g *gateway) handleSearch(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	limit := 10
+	if n := params.Get("n"); n != "" {
+		var err error
+		if limit, err = strconv.Atoi(n); err != nil || limit < 0 {
+			httpError(w, http.StatusBadRequest, fmt.Errorf("invalid n %q", n))
+			return
+		}
+	}
+
+	response := g.search(params.Get("q"), limit)
+	if len(response.Errors) == len(g.backends) {
+		writeJson(w, http.StatusBadGateway, response)
+		return
+	}
+	writeJson(w, http.StatusOK, response)
+}
+
+func runGateway(args []string) {
+	fs := flag.NewFlagSet("gateway", flag.ExitOnError)
+	addr := fs.String("addr", ":8080", "address to listen on")
+	timeout := fs.Duration("timeout", 5*time.Second, "how long to wait for a backend")
+	g := &gateway{}
+	fs.Func("backend", "sego server to query (repeatable)", func(s string) error {
+		g.backends = append(g.backends, s)
+		return nil
+	})
+	fs.Parse(args)
+	if len(g.backends) == 0 {
+		log.Fatal("usage: sego gateway -backend http://host:8080 [-backend ...]")
+	}
+	g.client = &http.Client{Timeout: *timeout}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/search", g.handleSearch)
+	log.Printf("Federating %d backends on %s", len(g.backends), *addr)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
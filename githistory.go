@@ -0,0 +1,86 @@
+package sego
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// indexGitLog adds every commit of a repository as a document keyed by
+// repo#hash, with the commit message and optionally the diff as its text
+func (m *Model) indexGitLog(repo string, diffs bool, config *Config) error {
+	m.prepareIndexing(config)
+
+	// commits start with a record separator, fields are split by unit separators
+	args := []string{"-C", repo, "log", "--no-color", "--format=%x1e%H%x1f%an <%ae>%x1f%aI%x1f%B"}
+	if diffs {
+		args = append(args, "-p")
+	}
+	cmd := exec.Command("git", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(out)
+	for {
+		record, err := reader.ReadBytes('\x1e')
+		record = bytes.TrimSuffix(record, []byte{'\x1e'})
+		if len(bytes.TrimSpace(record)) > 0 {
+			if err := m.indexCommit(repo, string(record), config); err != nil {
+				cmd.Process.Kill()
+				cmd.Wait()
+				return err
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			cmd.Process.Kill()
+			cmd.Wait()
+			return err
+		}
+	}
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("git log in %s: %w: %s", repo, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+func (m *Model) indexCommit(repo, record string, config *Config) error {
+	fields := strings.SplitN(record, "\x1f", 4)
+	if len(fields) != 4 {
+		return fmt.Errorf("unexpected git log output in %s", repo)
+	}
+	hash, author, date, text := fields[0], fields[1], fields[2], fields[3]
+	key := repo + "#" + hash
+	subject, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+
+	// "<alice@example.com>" would be taken for a tag
+	name, _, _ := strings.Cut(author, " <")
+	m.Stats.Files++
+	log.Printf("Indexing: %s", key)
+	if err := m.indexDocument(key, []byte(name+"\n"+text), "", config, m.Stats); err != nil {
+		return err
+	}
+
+	if doc, ok := m.document(key); ok {
+		doc.Title = subject
+		doc.Author = author
+		if published, err := time.Parse(time.RFC3339, date); err == nil {
+			doc.Published = &published
+		}
+	}
+	return nil
+}
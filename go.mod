@@ -2,4 +2,9 @@ module github.com/ecrax/sego
 
 go 1.20
 
-require golang.org/x/exp v0.0.0-20230224173230-c95f2b4c22f2 // indirect
+require (
+	github.com/go-sql-driver/mysql v1.7.1
+	github.com/lib/pq v1.10.9
+	golang.org/x/net v0.17.0
+	golang.org/x/text v0.13.0
+)
@@ -0,0 +1,107 @@
+package sego
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+const (
+	GroupNone = ""
+	// the directory of a file or URL
+	GroupDir = "dir"
+	// the host of a URL or the top directory of a file
+	GroupSite = "site"
+	// the page a section or chunk is part of
+	GroupPage = "page"
+)
+
+// groupOf is what r is grouped under
+func groupOf(r SearchResult, by string) string {
+	if by != GroupPage {
+		return sectionOf(r.Path, by)
+	}
+	if r.Parent != "" {
+		return r.Parent
+	}
+	return r.Path
+}
+
+// sectionOf is what p is grouped under
+func sectionOf(p, by string) string {
+	if u, err := url.Parse(p); err == nil && u.Host != "" {
+		if by == GroupSite {
+			return u.Host
+		}
+		u.RawQuery = ""
+		u.Fragment = ""
+		u.Path = path.Dir(u.Path)
+		return u.String()
+	}
+	if by == GroupSite {
+		top, _, _ := strings.Cut(strings.TrimPrefix(p, "/"), "/")
+		return top
+	}
+	return path.Dir(p)
+}
+
+func checkSection(by string) error {
+	if by != GroupDir && by != GroupSite && by != GroupPage {
+		return fmt.Errorf("can't group by %q", by)
+	}
+	return nil
+}
+
+// group keeps only the best result of every section, counting the ones left
+// out in its More
+func (a SearchResults) group(by string) (SearchResults, error) {
+	if by == GroupNone {
+		return a, nil
+	}
+	if err := checkSection(by); err != nil {
+		return nil, err
+	}
+
+	result := make(SearchResults, 0)
+	// section => index in result
+	best := make(map[string]int)
+	for _, r := range a {
+		section := groupOf(r, by)
+		if i, ok := best[section]; ok {
+			// documents not matching at all aren't more from the section
+			if r.Rank > 0 {
+				result[i].More++
+			}
+			continue
+		}
+		best[section] = len(result)
+		result = append(result, r)
+	}
+	return result, nil
+}
+
+// diversify moves results beyond the first max of every section behind the
+// others, so a broad query doesn't show one directory or site only
+func (a SearchResults) diversify(by string, max int) (SearchResults, error) {
+	if by == GroupNone || max <= 0 {
+		return a, nil
+	}
+	if err := checkSection(by); err != nil {
+		return nil, err
+	}
+
+	result := make(SearchResults, 0, len(a))
+	demoted := make(SearchResults, 0)
+	count := make(map[string]int)
+	for _, r := range a {
+		section := groupOf(r, by)
+		count[section]++
+		if count[section] > max {
+			demoted = append(demoted, r)
+		} else {
+			result = append(result, r)
+		}
+	}
+	return append(result, demoted...), nil
+}
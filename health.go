@@ -0,0 +1,79 @@
+package sego
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// handleLivez answers as long as the process is serving requests at all
+func (s *server) handleLivez(w http.ResponseWriter, r *http.Request) {
+	writeJson(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleReadyz answers 200 once the index is loaded and until shutdown begins
+func (s *server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	loaded := s.model != nil
+	s.mu.RUnlock()
+	switch {
+	case s.draining.Load():
+		writeJson(w, http.StatusServiceUnavailable, map[string]string{"status": "draining"})
+	case !loaded:
+		writeJson(w, http.StatusServiceUnavailable, map[string]string{"status": "loading"})
+	default:
+		writeJson(w, http.StatusOK, map[string]string{"status": "ready"})
+	}
+}
+
+// loaded answers 503 instead of calling h while there is no index yet
+func (s *server) loaded(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.RLock()
+		loaded := s.model != nil
+		s.mu.RUnlock()
+		if !loaded {
+			w.Header().Set("Retry-After", "1")
+			httpError(w, http.StatusServiceUnavailable, errors.New("the index is still loading"))
+			return
+		}
+		h(w, r)
+	}
+}
+
+// listen serves handler on addr until SIGINT or SIGTERM. Then /readyz fails
+// for lameDuck while requests are still served, so load balancers stop
+// sending new ones, before waiting for those running to finish.
+func (s *server) listen(addr string, handler http.Handler, lameDuck time.Duration) error {
+	srv := &http.Server{Addr: addr, Handler: handler}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.ListenAndServe()
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stop)
+	select {
+	case err := <-errs:
+		return err
+	case sig := <-stop:
+		log.Printf("Got %s, draining for %s", sig, lameDuck)
+	}
+	s.draining.Store(true)
+	time.Sleep(lameDuck)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		return err
+	}
+	tracing.flush()
+	log.Printf("Shut down")
+	return nil
+}
@@ -0,0 +1,181 @@
+package sego
+
+import (
+	"bytes"
+	"html"
+	"os"
+	"regexp"
+	"strings"
+
+	xhtml "golang.org/x/net/html"
+)
+
+// largest document file read again to highlight it
+const maxHighlightSize = 10 << 20
+
+var highlightWordRe = regexp.MustCompile(`[\p{L}\p{N}_]+`)
+
+// HighlightOptions asks for a fragment of each result's text with the query
+// terms marked
+type HighlightOptions struct {
+	// runes of text around the first match, 150 if 0
+	FragmentSize int    `json:"fragment_size"`
+	PreTag       string `json:"pre_tag"`
+	PostTag      string `json:"post_tag"`
+}
+
+// documentText reads the text of a document again from its file, "" if it
+// isn't one, like a crawled page. The text of a section is that under its
+// heading, of a chunk that of its page. It is extracted with config like it
+// was when indexed.
+func (m *Model) documentText(path string, config *Config) string {
+	content, ok := m.readDocument(path, config)
+	if ok {
+		return contentText(content)
+	}
+	page, anchor, found := strings.Cut(path, "#")
+	if !found {
+		return ""
+	}
+	if content, ok = m.readDocument(page, config); !ok {
+		return ""
+	}
+	if m.Sections > 0 {
+		isHTML := looksLikeHTML(content)
+		for _, s := range splitSections(content, isHTML, m.Sections) {
+			if s.id != anchor {
+				continue
+			}
+			if isHTML {
+				return html.UnescapeString(s.text())
+			}
+			return s.text()
+		}
+	}
+	return contentText(content)
+}
+
+// readDocument reads the file at path and extracts its text and cleans HTML
+// like indexing does, reporting whether it is a file that could be
+func (m *Model) readDocument(path string, config *Config) ([]byte, bool) {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() || info.Size() > maxHighlightSize {
+		return nil, false
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	if content, _, err = extractText(path, content, config); err != nil {
+		return nil, false
+	}
+	opts := m.Lexer
+	if name := config.analyzerName(path, content); name != "" {
+		if analyzer, ok := m.Analyzers[name]; ok {
+			opts = analyzer
+		}
+	}
+	if config.cleansHTML() && (opts.Markup == MarkupHTML || looksLikeHTML(content)) {
+		if content, err = config.cleanHTML(path, content); err != nil {
+			return nil, false
+		}
+	}
+	return content, true
+}
+
+// contentText is the text of extracted content, without the markup of HTML
+func contentText(content []byte) string {
+	if !looksLikeHTML(content) {
+		return string(content)
+	}
+	var text strings.Builder
+	tokenizer := xhtml.NewTokenizer(bytes.NewReader(content))
+	skip := 0
+	for {
+		switch tokenizer.Next() {
+		case xhtml.ErrorToken:
+			return text.String()
+		case xhtml.StartTagToken:
+			switch name, _ := tokenizer.TagName(); string(name) {
+			case "head", "script", "style":
+				skip++
+			}
+		case xhtml.EndTagToken:
+			switch name, _ := tokenizer.TagName(); string(name) {
+			case "head", "script", "style":
+				if skip > 0 {
+					skip--
+				}
+			}
+		case xhtml.TextToken:
+			if skip == 0 {
+				text.Write(tokenizer.Text())
+				text.WriteByte(' ')
+			}
+		}
+	}
+}
+
+// highlight cuts a fragment around the first of terms, analyzed query terms,
+// out of text and marks the words analyzed to one of them, the text is HTML
+// escaped so only the marks are markup
+func (m *Model) highlight(text string, terms map[string]bool, opts *HighlightOptions) string {
+	size := opts.FragmentSize
+	if size <= 0 {
+		size = 150
+	}
+	pre, post := opts.PreTag, opts.PostTag
+	if pre == "" && post == "" {
+		pre, post = "<em>", "</em>"
+	}
+
+	matches := func(word string) bool {
+		for _, token := range m.analyzeQuery(word, nil) {
+			if terms[token] {
+				return true
+			}
+		}
+		return false
+	}
+	words := highlightWordRe.FindAllStringIndex(text, -1)
+	first := -1
+	for i, span := range words {
+		if matches(text[span[0]:span[1]]) {
+			first = i
+			break
+		}
+	}
+	if first < 0 {
+		return ""
+	}
+
+	// start a few words before the match, end at the last word that fits
+	start := first
+	for start > 0 && first-start < 5 && words[first][0]-words[start-1][0] < size/3 {
+		start--
+	}
+	end := first
+	for end+1 < len(words) && len([]rune(text[words[start][0]:words[end+1][1]])) <= size {
+		end++
+	}
+
+	var fragment strings.Builder
+	if start > 0 {
+		fragment.WriteString("…")
+	}
+	last := words[start][0]
+	for _, span := range words[start : end+1] {
+		fragment.WriteString(html.EscapeString(text[last:span[0]]))
+		word := text[span[0]:span[1]]
+		if matches(word) {
+			fragment.WriteString(pre + html.EscapeString(word) + post)
+		} else {
+			fragment.WriteString(html.EscapeString(word))
+		}
+		last = span[1]
+	}
+	if end+1 < len(words) {
+		fragment.WriteString("…")
+	}
+	return cleanText(fragment.String())
+}
@@ -0,0 +1,33 @@
+package sego
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHighlightEscapes(t *testing.T) {
+	m := newModel()
+	terms := map[string]bool{"SHADER": true}
+	got := m.highlight(`a <b>shader</b> & "co"`, terms, &HighlightOptions{})
+	want := `a &lt;b&gt;<em>shader</em>&lt;/b&gt; &amp; &#34;co`
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestDocumentTextConfig(t *testing.T) {
+	page := filepath.Join(t.TempDir(), "page.html")
+	content := `<html><body><div class="sidebar">sidebar links</div><p>shader docs</p></body></html>`
+	if err := os.WriteFile(page, []byte(content), 0666); err != nil {
+		t.Fatal(err)
+	}
+	config := newConfig()
+	config.ExcludeSelectors = []string{".sidebar"}
+	m := newModel()
+	text := m.documentText(page, config)
+	if strings.Contains(text, "sidebar") || !strings.Contains(text, "shader docs") {
+		t.Errorf("text %q", text)
+	}
+}
@@ -0,0 +1,267 @@
+package sego
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+)
+
+// IngestMessage is what producers publish to add or delete a document
+type IngestMessage struct {
+	// "add" (the default) or "delete"
+	Op      string `json:"op,omitempty"`
+	Path    string `json:"path"`
+	Content string `json:"content,omitempty"`
+	// title, author, lang, tags, url, published
+	Meta *Document `json:"meta,omitempty"`
+}
+
+// apply adds or deletes the document of a message
+func (m *Model) apply(msg *IngestMessage, config *Config) error {
+	if msg.Path == "" {
+		return fmt.Errorf("message without path")
+	}
+	switch msg.Op {
+	case "", "add":
+		m.prepareIndexing(config)
+		if err := m.indexDocument(msg.Path, []byte(msg.Content), "", config, m.Stats); err != nil {
+			return err
+		}
+		if doc, ok := m.document(msg.Path); ok {
+			doc.merge(msg.Meta)
+		}
+		return nil
+	case "delete":
+		m.removeDocument(msg.Path)
+		return nil
+	}
+	return fmt.Errorf("unknown op %q", msg.Op)
+}
+
+// ingester applies the messages of a NATS subject to a live index
+type ingester struct {
+	mu     *sync.RWMutex
+	model  *Model
+	config *Config
+	// changes since the last snapshot, nil if disabled
+	log *wal
+	// changed since the last snapshot
+	dirty bool
+	// set when the index is segmented, changes then go to its buffer
+	segments *segmentWriter
+}
+
+// applyMessage applies msg to the model or the segment being written. The
+// caller holds mu for writing.
+func (in *ingester) applyMessage(msg *IngestMessage) error {
+	if in.segments != nil {
+		return in.segments.apply(msg)
+	}
+	return in.model.apply(msg, in.config)
+}
+
+// current is the model changes go to
+func (in *ingester) current() *Model {
+	if in.segments != nil {
+		return in.segments.buffer.model
+	}
+	return in.model
+}
+
+func (in *ingester) consume(natsURL, subject, queue string) {
+	backoff := time.Second
+	for {
+		err := in.consumeOnce(natsURL, subject, queue)
+		log.Printf("Consuming %s: %s, reconnecting in %s", subject, err, backoff)
+		time.Sleep(backoff)
+		if backoff < time.Minute {
+			backoff *= 2
+		}
+	}
+}
+
+func (in *ingester) consumeOnce(natsURL, subject, queue string) error {
+	conn, err := dialNATS(natsURL)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.subscribe(subject, queue); err != nil {
+		return err
+	}
+	log.Printf("Consuming %s from %s", subject, natsURL)
+
+	for {
+		payload, err := conn.next()
+		if err != nil {
+			return err
+		}
+		var msg IngestMessage
+		if err := json.Unmarshal(payload, &msg); err != nil {
+			log.Printf("Dropping message: %s", err)
+			continue
+		}
+		in.mu.Lock()
+		if in.log != nil {
+			if err := in.log.append(&msg); err != nil {
+				in.mu.Unlock()
+				return err
+			}
+		}
+		model := in.current()
+		oldHash := ""
+		if doc, ok := model.document(msg.Path); ok {
+			oldHash = doc.Hash
+		}
+		err = in.applyMessage(&msg)
+		var alerts []pendingAlert
+		if err == nil {
+			in.dirty = true
+			if doc, ok := model.document(msg.Path); ok && msg.Op != "delete" && doc.Hash != oldHash {
+				id, _ := model.docID(msg.Path)
+				alerts = model.matchAlerts(in.config.Alerts, map[string]bool{id: true})
+			}
+		}
+		in.mu.Unlock()
+		if err != nil {
+			log.Printf("Dropping message for %s: %s", msg.Path, err)
+		}
+		// webhooks may be slow, ingesting and searching go on meanwhile
+		for _, a := range alerts {
+			notify(a.query, a.alert)
+		}
+	}
+}
+
+// snapshot saves the index if it changed, searches can go on meanwhile and
+// dirty is only ever read by the consumer holding the write lock
+func (in *ingester) snapshot(indexPath string) {
+	if in.segments != nil {
+		in.flush()
+		return
+	}
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+	if !in.dirty {
+		return
+	}
+	if err := in.model.saveAsJson(indexPath); err != nil {
+		log.Printf("Saving %s: %s", indexPath, err)
+		return
+	}
+	in.dirty = false
+	if in.log != nil {
+		if err := in.log.reset(); err != nil {
+			log.Printf("Resetting write-ahead log: %s", err)
+		}
+	}
+	log.Printf("Saved %s", indexPath)
+}
+
+// flush writes the changes since the last flush as a new segment
+func (in *ingester) flush() {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	name, err := in.segments.flush()
+	if err != nil {
+		log.Printf("Flushing segment: %s", err)
+		return
+	}
+	if name == "" {
+		return
+	}
+	in.dirty = false
+	if in.log != nil {
+		if err := in.log.reset(); err != nil {
+			log.Printf("Resetting write-ahead log: %s", err)
+		}
+	}
+	log.Printf("Flushed segment %s", name)
+}
+
+func runIngest(args []string) {
+	fs := flag.NewFlagSet("ingest", flag.ExitOnError)
+	indexPath := fs.String("index", "index-new.json", "index to update, created if missing")
+	natsURL := fs.String("nats", "nats://127.0.0.1:4222", "NATS server to consume from")
+	subject := fs.String("subject", "sego.docs", "subject documents are published to")
+	queue := fs.String("queue", "", "queue group to share the messages with other consumers")
+	every := fs.Duration("snapshot", time.Minute, "how often to save the index when it changed")
+	addr := fs.String("addr", "", "also serve searches on this address")
+	useWAL := fs.Bool("wal", true, "log changes to <index>.wal so they survive a crash before the next snapshot")
+	segmented := fs.Bool("segments", false, "keep the index as a directory of segments, changes are flushed as a new one every -snapshot instead of saving the whole index (default if -index is a directory)")
+	maxSegments := fs.Int("max-segments", 10, "merge segments in the background when there are more than this")
+	threads := fs.Int("query-threads", 0, "goroutines to score a query with (default GOMAXPROCS)")
+	config := newConfig()
+	config.registerFlags(fs)
+	fs.Parse(args)
+
+	in := &ingester{mu: &sync.RWMutex{}, config: config}
+	if *segmented || isSegmented(*indexPath) {
+		w, err := openSegmentWriter(*indexPath, config, *maxSegments)
+		if err != nil {
+			log.Fatal(err)
+		}
+		w.threads = *threads
+		in.segments = w
+		in.mu = w.mu
+	} else {
+		in.model = newModel()
+		if _, err := os.Stat(*indexPath); err == nil {
+			if in.model, err = newModelFromJson(*indexPath); err != nil {
+				log.Fatal(err)
+			}
+		}
+		in.model.threads = *threads
+	}
+	if *useWAL {
+		walPath := *indexPath + ".wal"
+		n, err := replayWAL(walPath, in.applyMessage)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if n > 0 {
+			log.Printf("Replayed %d changes from %s", n, walPath)
+			in.dirty = true
+		}
+		if in.log, err = openWAL(walPath); err != nil {
+			log.Fatal(err)
+		}
+		defer in.log.Close()
+	}
+	go in.consume(*natsURL, *subject, *queue)
+
+	if *addr != "" {
+		mux := http.NewServeMux()
+		if in.segments != nil {
+			mux.HandleFunc("/search", in.segments.handleSearch)
+		} else {
+			s := &server{model: in.model, config: in.config, mu: in.mu, live: true}
+			mux.HandleFunc("/search", s.handleSearch)
+			mux.HandleFunc("/snapshot", s.handleSnapshot)
+		}
+		mux.HandleFunc("/delete", in.handleDelete)
+		go func() {
+			log.Printf("Serving %s on %s", *indexPath, *addr)
+			log.Fatal(http.ListenAndServe(*addr, mux))
+		}()
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	ticker := time.NewTicker(*every)
+	for {
+		select {
+		case <-ticker.C:
+			in.snapshot(*indexPath)
+		case <-interrupt:
+			in.snapshot(*indexPath)
+			return
+		}
+	}
+}
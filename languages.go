@@ -0,0 +1,217 @@
+package sego
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// LangAuto as the lexer language detects the language of each document
+const LangAuto = "auto"
+
+// languageBundle is the stopwords and stemmer of a language
+type languageBundle struct {
+	stopwords []string
+	stem      func(string) string
+}
+
+// languages analyzers can use with -lang or the stop and stem filters, by
+// ISO 639-1 code
+var languages = map[string]*languageBundle{
+	"en": {stopwords: englishStopwords, stem: porterStem},
+	"de": {stopwords: germanStopwords, stem: germanStem},
+	"fr": {stopwords: frenchStopwords, stem: frenchStem},
+	"es": {stopwords: spanishStopwords, stem: spanishStem},
+	"ru": {stopwords: russianStopwords, stem: russianStem},
+}
+
+// in the order languages are detected in when documents score the same
+var languageCodes = []string{"en", "de", "fr", "es", "ru"}
+
+var germanStopwords = strings.Fields(`aber alle allem allen aller alles als also am an ander andere
+	anderem anderen anderer anderes anderm andern anderr anders auch auf aus bei bin bis bist da
+	damit dann der den des dem die das dass daß derselbe derselben denselben desselben demselben
+	dieselbe dieselben dasselbe dazu dein deine deinem deinen deiner deines denn derer dessen dich
+	dir du dies diese diesem diesen dieser dieses doch dort durch ein eine einem einen einer eines
+	einig einige einigem einigen einiger einiges einmal er ihn ihm es etwas euer eure eurem euren
+	eurer eures für gegen gewesen hab habe haben hat hatte hatten hier hin hinter ich mich mir ihr
+	ihre ihrem ihren ihrer ihres euch im in indem ins ist jede jedem jeden jeder jedes jene jenem
+	jenen jener jenes jetzt kann kein keine keinem keinen keiner keines können könnte machen man
+	manche manchem manchen mancher manches mein meine meinem meinen meiner meines mit muss musste
+	nach nicht nichts noch nun nur ob oder ohne sehr sein seine seinem seinen seiner seines selbst
+	sich sie ihnen sind so solche solchem solchen solcher solches soll sollte sondern sonst über um
+	und uns unsere unserem unseren unser unseres unter viel vom von vor während war waren warst was
+	weg weil weiter welche welchem welchen welcher welches wenn werde werden wie wieder will wir
+	wird wirst wo wollen wollte würde würden zu zum zur zwar zwischen`)
+
+var frenchStopwords = strings.Fields(`au aux avec ce ces dans de des du elle en et eux il ils je la
+	le les leur lui ma mais me même mes moi mon ne nos notre nous on ou par pas pour qu que qui sa
+	se ses son sur ta te tes toi ton tu un une vos votre vous c d j l à m n s t y été étée étées
+	étés étant étante étants étantes suis es est sommes êtes sont serai seras sera serons serez
+	seront serais serait serions seriez seraient étais était étions étiez étaient fus fut fûmes
+	fûtes furent sois soit soyons soyez soient fusse fusses fût fussions fussiez fussent ayant
+	ayante ayantes ayants eu eue eues eus ai as avons avez ont aurai auras aura aurons aurez
+	auront aurais aurait aurions auriez auraient avais avait avions aviez avaient eut eûmes eûtes
+	eurent aie aies ait ayons ayez aient eusse eusses eût eussions eussiez eussent`)
+
+var spanishStopwords = strings.Fields(`de la que el en y a los del se las por un para con no una su
+	al lo como más pero sus le ya o este sí porque esta entre cuando muy sin sobre también me hasta
+	hay donde quien desde todo nos durante todos uno les ni contra otros ese eso ante ellos e esto
+	mí antes algunos qué unos yo otro otras otra él tanto esa estos mucho quienes nada muchos cual
+	poco ella estar estas algunas algo nosotros mi mis tú te ti tu tus ellas nosotras vosotros
+	vosotras os mío mía míos mías tuyo tuya tuyos tuyas suyo suya suyos suyas nuestro nuestra
+	nuestros nuestras vuestro vuestra vuestros vuestras esos esas estoy estás está estamos estáis
+	están esté estés estemos estéis estén estaré estarás estará estaremos estaréis estarán estaría
+	estarías estaríamos estaríais estarían estaba estabas estábamos estabais estaban estuve
+	estuviste estuvo estuvimos estuvisteis estuvieron he has ha hemos habéis han haya hayas hayamos
+	hayáis hayan habré habrás habrá habremos habréis habrán habría habrías habríamos habríais
+	habrían había habías habíamos habíais habían hube hubiste hubo hubimos hubisteis hubieron soy
+	eres es somos sois son sea seas seamos seáis sean seré serás será seremos seréis serán sería
+	serías seríamos seríais serían era eras éramos erais eran fui fuiste fue fuimos fuisteis
+	fueron tengo tienes tiene tenemos tenéis tienen tenga tengas tengamos tengáis tengan tuve
+	tuviste tuvo tuvimos tuvisteis tuvieron tenía tenías teníamos teníais tenían`)
+
+var russianStopwords = strings.Fields(`и в во не что он на я с со как а то все она так его но да ты
+	к у же вы за бы по только ее мне было вот от меня еще нет о из ему теперь когда даже ну вдруг
+	ли если уже или ни быть был него до вас нибудь опять уж вам ведь там потом себя ничего ей
+	может они тут где есть надо ней для мы тебя их чем была сам чтоб без будто чего раз тоже себе
+	под будет ж тогда кто этот того потому этого какой совсем ним здесь этом один почти мой тем
+	чтобы нее сейчас были куда зачем всех никогда можно при наконец два об другой хоть после над
+	больше тот через эти нас про всего них какая много разве три эту моя впрочем хорошо свою этой
+	перед иногда лучше чуть том нельзя такой им более всегда конечно всю между`)
+
+// stemFilter stems with the Porter stemmer for English, or that of the
+// language given as argument, "stem:de"
+func stemFilter(args []string) (TokenFilter, error) {
+	switch len(args) {
+	case 0:
+		return mapTokens(stemToken), nil
+	case 1:
+		bundle, ok := languages[args[0]]
+		if !ok {
+			return nil, fmt.Errorf("no stemmer for language %q", args[0])
+		}
+		return mapTokens(func(token string) string { return stemCased(token, bundle.stem) }), nil
+	}
+	return nil, fmt.Errorf("takes a language at most")
+}
+
+// langFilters are the filters of the language bundle, after any others
+func (o LexerOptions) langFilters() []string {
+	if o.Lang == "" || o.Lang == LangAuto {
+		return o.Filters
+	}
+	filters := make([]string, 0, len(o.Filters)+2)
+	filters = append(filters, o.Filters...)
+	return append(filters, "stop:"+o.Lang, "stem:"+o.Lang)
+}
+
+func checkLang(lang string) error {
+	if _, ok := languages[lang]; !ok && lang != "" && lang != LangAuto {
+		return fmt.Errorf("unknown language %q, use one of %s or %s", lang, strings.Join(languageCodes, ", "), LangAuto)
+	}
+	return nil
+}
+
+// words of a document looked at to detect its language
+const detectWords = 1000
+
+// detectLanguage guesses the language of text by which language's stopwords
+// make up most of its words, "" if none is common enough
+func detectLanguage(text string) string {
+	stopwords := make(map[string]map[string]bool, len(languages))
+	for code, bundle := range languages {
+		stopwords[code] = make(map[string]bool, len(bundle.stopwords))
+		for _, word := range bundle.stopwords {
+			stopwords[code][word] = true
+		}
+	}
+	hits := make(map[string]int)
+	words := 0
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) }) {
+		if words++; words > detectWords {
+			break
+		}
+		for code := range languages {
+			if stopwords[code][word] {
+				hits[code]++
+			}
+		}
+	}
+	best := ""
+	for _, code := range languageCodes {
+		if hits[code] > hits[best] {
+			best = code
+		}
+	}
+	// a few percent of the words of any text in a language are stopwords
+	if hits[best] < 3 || hits[best]*20 < words {
+		return ""
+	}
+	return best
+}
+
+// noteLanguage remembers that a document was analyzed as lang, so queries
+// are analyzed that way too
+func (m *Model) noteLanguage(lang string) {
+	for _, known := range m.Languages {
+		if known == lang {
+			return
+		}
+	}
+	m.Languages = append(m.Languages, lang)
+}
+
+// analyzeQuery tokenizes query text. With languages detected per document it
+// is analyzed as each of them, or as those of lang filters, and each term is
+// kept as often as it occurs in any of those.
+func (m *Model) analyzeQuery(text string, filters []Filter) []string {
+	if m.Lexer.Lang != LangAuto {
+		return tokenize(text, m.Lexer)
+	}
+	counts := make(map[string]int)
+	order := make([]string, 0)
+	for _, variant := range m.queryVariants(text, filters) {
+		seen := make(map[string]int)
+		for _, token := range variant {
+			if seen[token]++; seen[token] > counts[token] {
+				if counts[token] == 0 {
+					order = append(order, token)
+				}
+				counts[token] = seen[token]
+			}
+		}
+	}
+	tokens := make([]string, 0, len(order))
+	for _, token := range order {
+		for i := 0; i < counts[token]; i++ {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+// queryVariants are the tokens of query text analyzed as each language it may
+// be in, those of lang filters or else those detected, and as none
+func (m *Model) queryVariants(text string, filters []Filter) [][]string {
+	if m.Lexer.Lang != LangAuto {
+		return [][]string{tokenize(text, m.Lexer)}
+	}
+	langs := make([]string, 0)
+	for _, filter := range filters {
+		if _, ok := languages[filter.Value]; ok && filter.Field == "lang" {
+			langs = append(langs, filter.Value)
+		}
+	}
+	if len(langs) == 0 {
+		langs = append(langs, m.Languages...)
+	}
+
+	variants := make([][]string, 0, len(langs)+1)
+	for _, lang := range append(langs, "") {
+		opts := m.Lexer
+		opts.Lang = lang
+		variants = append(variants, tokenize(text, opts))
+	}
+	return variants
+}
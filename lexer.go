@@ -0,0 +1,593 @@
+package sego
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+)
+
+type HyphenPolicy string
+
+const (
+	// "anti-aliasing" => "ANTI", "-", "ALIASING"
+	HyphenSplit HyphenPolicy = "split"
+	// "anti-aliasing" => "ANTI-ALIASING", "ANTI", "ALIASING"
+	HyphenKeep HyphenPolicy = "keep"
+)
+
+type ApostrophePolicy string
+
+const (
+	// "don't" => "DON", "'", "T"
+	ApostropheSplit ApostrophePolicy = "split"
+	// "don't" => "DON'T", "OpenGL's" => "OPENGL'S"
+	ApostropheKeep ApostrophePolicy = "keep"
+	// "don't" => "DONT", "OpenGL's" => "OPENGL"
+	ApostropheStrip ApostrophePolicy = "strip"
+)
+
+type MarkupMode string
+
+const (
+	// skip everything between < and >
+	MarkupHTML MarkupMode = "html"
+	// "<" and ">" are ordinary characters
+	MarkupNone MarkupMode = "none"
+)
+
+type CaseMode string
+
+const (
+	// full Unicode uppercasing, "straße" => "STRASSE"
+	CaseUpper CaseMode = "upper"
+	// Unicode case folding, "Straße" => "strasse", "ΣΊΣΥΦΟΣ" => "σίσυφοσ"
+	CaseFold CaseMode = "fold"
+)
+
+type LexerOptions struct {
+	Markup MarkupMode `json:"markup,omitempty"`
+	// how tokens are normalized, "" means upper
+	Case CaseMode `json:"case,omitempty"`
+	// BCP 47 language with its own case rules, e.g. "tr" for the dotted and
+	// dotless i
+	Locale string `json:"locale,omitempty"`
+	// upper case rune by rune like indexes built before unicodeCaseVersion,
+	// where "ß" stays "ß"
+	runeCase    bool
+	Hyphens     HyphenPolicy     `json:"hyphens,omitempty"`
+	Apostrophes ApostrophePolicy `json:"apostrophes,omitempty"`
+	// keep URLs and email addresses as single tokens
+	URLs bool `json:"urls,omitempty"`
+	// additionally emit host and words of URLs and email addresses
+	URLParts bool `json:"url_parts,omitempty"`
+	// keep snake_case and camelCase identifiers whole and also emit their parts
+	Identifiers bool `json:"identifiers,omitempty"`
+	// drop zero-width characters and soft hyphens within words and treat
+	// control characters as spaces
+	StripInvisible bool `json:"strip_invisible,omitempty"`
+	// what to do with runs of punctuation and symbols, "" means split
+	Punctuation PunctPolicy `json:"punctuation,omitempty"`
+	// punctuation kept as one token whatever the policy, like "#" or "->"
+	KeepPunct []string `json:"keep_punct,omitempty"`
+	// what to do with emoji, "" means keep
+	Emoji EmojiPolicy `json:"emoji,omitempty"`
+	// token filters applied in order, built-in ones like "lowercase",
+	// "ascii-fold", "translit", "stop", "stem" and "length:3" or ones from
+	// plugins
+	Filters []string `json:"filters,omitempty"`
+	// token filters applied first to the tokens of a type only, like
+	// {"PUNCT": ["drop"], "NUMBER": ["length:2"]}
+	TypeFilters map[TokenType][]string `json:"type_filters,omitempty"`
+	// language whose stopwords and stemmer apply after the filters, like
+	// "de", or "auto" to detect it for each document
+	Lang string `json:"lang,omitempty"`
+	// absolute path of a word list, one per line, to break Thai, Lao, Khmer
+	// and Myanmar text into words with, without one it is indexed as
+	// overlapping pairs of characters
+	Dictionary string `json:"dictionary,omitempty"`
+	// terms the filters leave alone, like API names that must not be stemmed
+	Keywords []string `json:"keywords,omitempty"`
+}
+
+func (o *LexerOptions) registerFlags(fs *flag.FlagSet) {
+	fs.Func("markup", "treat <...> as markup to skip (html) or as text (none)", func(s string) error {
+		switch MarkupMode(s) {
+		case MarkupHTML, MarkupNone:
+			o.Markup = MarkupMode(s)
+			return nil
+		}
+		return fmt.Errorf("unknown markup mode %q", s)
+	})
+	fs.Func("case", "normalize the case of tokens by upper casing (upper) or case folding (fold)", func(s string) error {
+		switch CaseMode(s) {
+		case CaseUpper, CaseFold:
+			o.Case = CaseMode(s)
+			return nil
+		}
+		return fmt.Errorf("unknown case mode %q", s)
+	})
+	fs.Func("locale", "language whose case rules to use, e.g. tr", func(s string) error {
+		if _, err := language.Parse(s); err != nil {
+			return err
+		}
+		o.Locale = s
+		return nil
+	})
+	fs.Func("hyphens", "hyphenated words: split or keep (compound plus parts)", func(s string) error {
+		switch HyphenPolicy(s) {
+		case HyphenSplit, HyphenKeep:
+			o.Hyphens = HyphenPolicy(s)
+			return nil
+		}
+		return fmt.Errorf("unknown hyphen policy %q", s)
+	})
+	fs.Func("apostrophes", "apostrophes in words: split, keep or strip", func(s string) error {
+		switch ApostrophePolicy(s) {
+		case ApostropheSplit, ApostropheKeep, ApostropheStrip:
+			o.Apostrophes = ApostrophePolicy(s)
+			return nil
+		}
+		return fmt.Errorf("unknown apostrophe policy %q", s)
+	})
+	fs.BoolVar(&o.URLs, "urls", o.URLs, "keep URLs and email addresses as single tokens")
+	fs.BoolVar(&o.URLParts, "url-parts", o.URLParts, "also emit the host and words of URLs and email addresses")
+	fs.BoolVar(&o.Identifiers, "identifiers", o.Identifiers, "also emit the parts of snake_case and camelCase identifiers")
+	fs.BoolVar(&o.StripInvisible, "strip-invisible", o.StripInvisible, "drop zero-width characters and soft hyphens and treat control characters as spaces")
+	fs.Func("punctuation", "runs of punctuation: split (a token per character), drop or merge (a token per run)", func(s string) error {
+		switch PunctPolicy(s) {
+		case PunctSplit, PunctDrop, PunctMerge:
+			o.Punctuation = PunctPolicy(s)
+			return nil
+		}
+		return fmt.Errorf("unknown punctuation policy %q", s)
+	})
+	fs.Func("keep-punct", "punctuation to keep as one token whatever the policy, like # or -> (repeatable)", func(s string) error {
+		o.KeepPunct = append(o.KeepPunct, s)
+		return nil
+	})
+	fs.Func("emoji", "emoji: keep, drop or names (index their Unicode names)", func(s string) error {
+		switch EmojiPolicy(s) {
+		case EmojiKeep, EmojiDrop, EmojiNames:
+			o.Emoji = EmojiPolicy(s)
+			return nil
+		}
+		return fmt.Errorf("unknown emoji policy %q", s)
+	})
+	fs.Func("filter", "token filter to apply: lowercase, uppercase, ascii-fold, translit, stop[:lang|file], stem[:lang], soundex, metaphone, drop, length:min[:max] or one from a plugin (repeatable)", func(s string) error {
+		o.Filters = append(o.Filters, s)
+		return nil
+	})
+	fs.Func("lang", "language to drop the stopwords of and stem: "+strings.Join(languageCodes, ", ")+" or "+LangAuto+" to detect it per document", func(s string) error {
+		if err := checkLang(s); err != nil {
+			return err
+		}
+		o.Lang = s
+		return nil
+	})
+	fs.Func("type-filter", "token filter to apply to a type of tokens only, TYPE=filter with WORD, NUMBER, PUNCT, CODE or URL, like PUNCT=drop (repeatable)", func(s string) error {
+		name, filter, ok := strings.Cut(s, "=")
+		if !ok {
+			return fmt.Errorf("want TYPE=filter, like PUNCT=drop")
+		}
+		typ, err := parseTokenType(name)
+		if err != nil {
+			return err
+		}
+		if o.TypeFilters == nil {
+			o.TypeFilters = make(map[TokenType][]string)
+		}
+		o.TypeFilters[typ] = append(o.TypeFilters[typ], filter)
+		return nil
+	})
+	fs.Func("dictionary", "word list, one per line, to break Thai, Lao, Khmer and Myanmar text into words with", func(s string) error {
+		var err error
+		o.Dictionary, err = filepath.Abs(s)
+		return err
+	})
+	fs.Func("keywords", "file of terms, one per line, the filters must not change or drop", func(s string) error {
+		keywords, err := readWordList(s)
+		o.Keywords = append(o.Keywords, keywords...)
+		return err
+	})
+}
+
+type lexer struct {
+	content []rune
+	opts    LexerOptions
+	// tokens produced by a previous call but not returned yet
+	pending [][]rune
+}
+
+func NewLexer(content []rune, opts LexerOptions) *lexer {
+	return &lexer{content: content, opts: opts}
+}
+
+func (l *lexer) trimLeft() {
+	for len(l.content) > 0 && unicode.IsSpace(l.content[0]) {
+		l.content = l.content[1:]
+	}
+}
+
+func (l *lexer) chop(n int) []rune {
+	token := l.content[0:n]
+	l.content = l.content[n:]
+	return token
+}
+
+func (l *lexer) chopWhile(predicate func(rune) bool) []rune {
+	n := 0
+	for n < len(l.content) && predicate(l.content[n]) {
+		n++
+	}
+	return l.chop(n)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r)
+}
+
+func isApostrophe(r rune) bool {
+	return r == '\'' || r == '’'
+}
+
+// joins reports whether r glues the word runes around it into one token
+func (l *lexer) joins(r rune) bool {
+	if r == '-' {
+		return l.opts.Hyphens == HyphenKeep
+	}
+	if isApostrophe(r) {
+		return l.opts.Apostrophes == ApostropheKeep || l.opts.Apostrophes == ApostropheStrip
+	}
+	return false
+}
+
+func (l *lexer) isWordRune(r rune) bool {
+	return (isWordRune(r) && !isSpaceless(r)) || (l.opts.Identifiers && r == '_')
+}
+
+func (l *lexer) chopWord() []rune {
+	n := 0
+	for {
+		for n < len(l.content) && l.isWordRune(l.content[n]) {
+			n++
+		}
+		if n+1 < len(l.content) && l.joins(l.content[n]) && l.isWordRune(l.content[n+1]) {
+			n++
+			continue
+		}
+		break
+	}
+	word := l.fixApostrophes(l.chop(n))
+
+	if l.opts.Hyphens == HyphenKeep {
+		start := 0
+		for i := 0; i <= len(word); i++ {
+			if i < len(word) && word[i] != '-' {
+				continue
+			}
+			if start > 0 || i < len(word) {
+				l.pending = append(l.pending, word[start:i])
+			}
+			start = i + 1
+		}
+	}
+
+	if l.opts.Identifiers {
+		if parts := splitIdentifier(word); len(parts) > 1 {
+			l.pending = append(l.pending, parts...)
+		}
+	}
+
+	return word
+}
+
+// splitIdentifier splits "GL_ARRAY_BUFFER" into "GL", "ARRAY", "BUFFER" and
+// "newHTTPServer" into "new", "HTTP", "Server"
+func splitIdentifier(word []rune) [][]rune {
+	parts := make([][]rune, 0)
+	start := 0
+	for i := 0; i < len(word); i++ {
+		r := word[i]
+		if r == '_' {
+			if i > start {
+				parts = append(parts, word[start:i])
+			}
+			start = i + 1
+			continue
+		}
+		if i > start && unicode.IsUpper(r) {
+			prev := word[i-1]
+			if unicode.IsLower(prev) || unicode.IsNumber(prev) ||
+				(unicode.IsUpper(prev) && i+1 < len(word) && unicode.IsLower(word[i+1])) {
+				parts = append(parts, word[start:i])
+				start = i
+			}
+		}
+	}
+	if start < len(word) {
+		parts = append(parts, word[start:])
+	}
+	return parts
+}
+
+func (l *lexer) fixApostrophes(word []rune) []rune {
+	switch l.opts.Apostrophes {
+	case ApostropheKeep:
+		for i := range word {
+			if isApostrophe(word[i]) {
+				word[i] = '\''
+			}
+		}
+	case ApostropheStrip:
+		// drop the possessive "'s" entirely, other apostrophes just vanish
+		n := len(word)
+		if n > 2 && isApostrophe(word[n-2]) && unicode.ToLower(word[n-1]) == 's' {
+			word = word[:n-2]
+		}
+		result := word[:0]
+		for _, r := range word {
+			if !isApostrophe(r) {
+				result = append(result, r)
+			}
+		}
+		word = result
+	}
+	return word
+}
+
+var urlPrefixes = []string{"http://", "https://", "ftp://", "file://", "mailto:", "www."}
+
+func (l *lexer) hasPrefixFold(prefix string) bool {
+	i := 0
+	for _, r := range prefix {
+		if i >= len(l.content) || unicode.ToLower(l.content[i]) != r {
+			return false
+		}
+		i++
+	}
+	return true
+}
+
+func isURLRune(r rune) bool {
+	return !unicode.IsSpace(r) && !strings.ContainsRune("<>\"'`", r)
+}
+
+// urlLength returns the length of the URL at the start of the content, or 0
+func (l *lexer) urlLength() int {
+	for _, prefix := range urlPrefixes {
+		if !l.hasPrefixFold(prefix) {
+			continue
+		}
+		n := len([]rune(prefix))
+		for n < len(l.content) && isURLRune(l.content[n]) {
+			n++
+		}
+		// trailing punctuation most likely belongs to the sentence
+		for n > 0 && strings.ContainsRune(".,;:!?)]}", l.content[n-1]) {
+			if l.content[n-1] == ')' && strings.ContainsRune(string(l.content[:n-1]), '(') {
+				break
+			}
+			n--
+		}
+		if n > len([]rune(prefix)) {
+			return n
+		}
+	}
+	return 0
+}
+
+func isEmailLocalRune(r rune) bool {
+	return isWordRune(r) || strings.ContainsRune("._%+-", r)
+}
+
+func isDomainRune(r rune) bool {
+	return isWordRune(r) || r == '.' || r == '-'
+}
+
+// emailLength returns the length of the email address at the start of the content, or 0
+func (l *lexer) emailLength() int {
+	n := 0
+	for n < len(l.content) && isEmailLocalRune(l.content[n]) {
+		n++
+	}
+	if n == 0 || n >= len(l.content) || l.content[n] != '@' {
+		return 0
+	}
+	at := n
+	n++
+	for n < len(l.content) && isDomainRune(l.content[n]) {
+		n++
+	}
+	for n > at && (l.content[n-1] == '.' || l.content[n-1] == '-') {
+		n--
+	}
+	domain := l.content[at+1 : n]
+	if len(domain) == 0 || !strings.ContainsRune(string(domain[1:]), '.') {
+		return 0
+	}
+	return n
+}
+
+// urlParts splits a URL or email address into its host and words
+func urlParts(token []rune) [][]rune {
+	s := string(token)
+	host := ""
+	if at := strings.LastIndexByte(s, '@'); at >= 0 && !strings.Contains(s, "/") {
+		host = s[at+1:]
+	} else {
+		for _, prefix := range urlPrefixes {
+			if strings.HasPrefix(strings.ToLower(s), prefix) {
+				s = s[len(prefix):]
+				break
+			}
+		}
+		host = s
+		if i := strings.IndexAny(host, "/?#"); i >= 0 {
+			host = host[:i]
+		}
+		if i := strings.LastIndexByte(host, '@'); i >= 0 {
+			host = host[i+1:]
+		}
+		host = strings.TrimPrefix(host, "www.")
+	}
+
+	parts := make([][]rune, 0)
+	if strings.ContainsRune(host, '.') {
+		parts = append(parts, []rune(host))
+	}
+	words := []rune(s)
+	for len(words) > 0 {
+		n := 0
+		for n < len(words) && isWordRune(words[n]) {
+			n++
+		}
+		if n > 0 {
+			parts = append(parts, words[:n])
+			words = words[n:]
+		} else {
+			words = words[1:]
+		}
+	}
+	return parts
+}
+
+func (l *lexer) Next() (value []rune, hasNext bool) {
+	if len(l.pending) > 0 {
+		token := l.pending[0]
+		l.pending = l.pending[1:]
+		return token, true
+	}
+
+	l.trimLeft()
+	if len(l.content) == 0 {
+		return nil, false
+	}
+
+	// HTML Tags, tokenize but don't return them as tokens
+	if l.content[0] == '<' && l.opts.Markup != MarkupNone {
+		n := 0
+		for n < len(l.content) && l.content[n] != '>' {
+			n++
+		}
+		if n == len(l.content) {
+			n--
+		}
+		l.content = l.content[n+1:]
+		return nil, true
+	}
+
+	if l.opts.URLs {
+		n := l.urlLength()
+		if n == 0 && isWordRune(l.content[0]) {
+			n = l.emailLength()
+		}
+		if n > 0 {
+			token := l.chop(n)
+			if l.opts.URLParts {
+				l.pending = append(l.pending, urlParts(token)...)
+			}
+			return token, true
+		}
+	}
+
+	if isSpaceless(l.content[0]) && !unicode.IsMark(l.content[0]) {
+		return l.chopSpaceless(), true
+	}
+
+	if unicode.IsNumber(l.content[0]) {
+		return l.chopWhile(func(r rune) bool {
+			return unicode.IsNumber(r)
+		}), true
+	}
+
+	if unicode.IsLetter(l.content[0]) || (l.opts.Identifiers && l.content[0] == '_') {
+		return l.chopWord(), true
+	}
+
+	if isPunctuation(l.content[0]) {
+		return l.chopPunctuation(), true
+	}
+
+	return l.chop(1), true
+}
+
+// caser maps the case of tokens, unlike mapping rune by rune it handles
+// characters becoming several like "ß" and languages like Turkish
+func (o LexerOptions) caser() func(string) string {
+	tag := language.Und
+	if o.Locale != "" {
+		tag = language.Make(o.Locale)
+	}
+	if o.Case != CaseFold {
+		if o.runeCase {
+			return strings.ToUpper
+		}
+		return cases.Upper(tag).String
+	}
+	fold := cases.Fold()
+	// folding has no language options, lower casing first gets the Turkic i right
+	if base, _ := tag.Base(); base.String() == "tr" || base.String() == "az" {
+		lower := cases.Lower(tag)
+		return func(s string) string { return fold.String(lower.String(s)) }
+	}
+	return fold.String
+}
+
+func tokenize(term string, opts LexerOptions) []string {
+	result, _ := tokenizeLimit(term, opts, 0)
+	return result
+}
+
+// tokenizeLimit stops after limit tokens (0 means no limit) and reports
+// whether there was more to tokenize
+func tokenizeLimit(term string, opts LexerOptions, limit int) ([]string, bool) {
+	lexer := NewLexer([]rune(opts.filterChars(term)), opts)
+	caser := opts.caser()
+	result := make([]string, 0)
+	var keywords map[string]bool
+
+	for {
+		if limit > 0 && len(result) >= limit {
+			// what was cut short is filtered like whole documents and queries
+			for {
+				token, hasNext := lexer.Next()
+				if !hasNext {
+					return opts.filterTokens(result, caser), false
+				}
+				if token != nil {
+					return opts.filterTokens(result, caser), true
+				}
+			}
+		}
+
+		token, hasNext := lexer.Next()
+		if !hasNext {
+			break
+		}
+
+		if token == nil {
+			continue
+		}
+
+		// omit everything less or equal than 2 chars to make table smaller
+		// if len(token) <= 2 {
+		// 	continue
+		// }
+
+		if len(opts.TypeFilters) == 0 {
+			result = append(result, caser(string(token)))
+			continue
+		}
+		if keywords == nil {
+			keywords = opts.keywordSet(caser)
+		}
+		result = append(result, opts.filterTyped(caser(string(token)), tokenType(token), keywords)...)
+	}
+
+	return opts.filterTokens(result, caser), false
+}
@@ -0,0 +1,27 @@
+package sego
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenizeLimitFilters(t *testing.T) {
+	opts := LexerOptions{Filters: []string{"stop:en", "stem:en"}}
+	text := "the running dragons and the sleeping knights"
+	whole := tokenize(text, opts)
+	tests := []struct {
+		limit int
+		want  []string
+		more  bool
+	}{
+		{0, whole, false},
+		{100, whole, false},
+		{3, []string{"RUN", "DRAGON"}, true},
+	}
+	for _, test := range tests {
+		got, more := tokenizeLimit(text, opts, test.limit)
+		if !reflect.DeepEqual(got, test.want) || more != test.more {
+			t.Errorf("limit %d: got %v, %t, want %v, %t", test.limit, got, more, test.want, test.more)
+		}
+	}
+}
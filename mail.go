@@ -0,0 +1,226 @@
+package sego
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+var headerDecoder = &mime.WordDecoder{
+	CharsetReader: func(charset string, input io.Reader) (io.Reader, error) {
+		return decodeCharset(charset, input), nil
+	},
+}
+
+// decodeCharset turns latin-1 into UTF-8 and passes everything else through
+func decodeCharset(charset string, input io.Reader) io.Reader {
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "latin1", "windows-1252":
+		data, _ := io.ReadAll(input)
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return strings.NewReader(string(runes))
+	}
+	return input
+}
+
+func decodeHeader(s string) string {
+	if decoded, err := headerDecoder.DecodeHeader(s); err == nil {
+		return decoded
+	}
+	return s
+}
+
+func decodeTransfer(encoding string, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, body)
+	}
+	return body
+}
+
+// mailText extracts the text of a message body, preferring text/plain over
+// text/html alternatives and skipping attachments
+func mailText(contentType, encoding string, body io.Reader) (string, error) {
+	if contentType == "" {
+		contentType = "text/plain"
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		texts := make([]string, 0)
+		html := ""
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return strings.Join(texts, "\n"), err
+			}
+			if strings.HasPrefix(part.Header.Get("Content-Disposition"), "attachment") {
+				continue
+			}
+			partType := part.Header.Get("Content-Type")
+			text, err := mailText(partType, part.Header.Get("Content-Transfer-Encoding"), part)
+			if err != nil {
+				return strings.Join(texts, "\n"), err
+			}
+			if strings.HasPrefix(partType, "text/html") {
+				html = text
+			} else if text != "" {
+				texts = append(texts, text)
+			}
+		}
+		if len(texts) == 0 || (mediaType != "multipart/alternative" && html != "") {
+			texts = append(texts, html)
+		}
+		return strings.Join(texts, "\n"), nil
+	}
+
+	if !strings.HasPrefix(mediaType, "text/") {
+		return "", nil
+	}
+	data, err := io.ReadAll(decodeCharset(params["charset"], decodeTransfer(encoding, body)))
+	return string(data), err
+}
+
+// indexMessage adds one RFC 5322 message as a document
+func (m *Model) indexMessage(key string, raw []byte, config *Config) error {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		m.Stats.skip(key, err.Error())
+		return nil
+	}
+
+	subject := decodeHeader(msg.Header.Get("Subject"))
+	from := decodeHeader(msg.Header.Get("From"))
+	text, err := mailText(msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), msg.Body)
+	if err != nil {
+		log.Printf("Reading %s: %s", key, err)
+	}
+
+	// "<alice@example.com>" would be taken for a tag
+	sender := from
+	if addr, err := mail.ParseAddress(from); err == nil {
+		sender = addr.Name + " " + addr.Address
+	}
+
+	log.Printf("Indexing: %s", key)
+	content := []byte(subject + "\n" + sender + "\n" + text)
+	if err := m.indexDocument(key, content, "", config, m.Stats); err != nil {
+		return err
+	}
+
+	if doc, ok := m.document(key); ok {
+		doc.Title = subject
+		doc.Author = from
+		if date, err := msg.Header.Date(); err == nil {
+			doc.Published = &date
+		}
+	}
+	return nil
+}
+
+func (m *Model) prepareIndexing(config *Config) {
+	if m.Analyzers == nil {
+		m.setupAnalyzers(config)
+	}
+	if m.Stats == nil {
+		m.Stats = newIndexStats()
+	}
+}
+
+// indexMbox adds every message of an mbox file, keyed by file#number
+func (m *Model) indexMbox(mboxPath string, config *Config) error {
+	file, err := os.Open(mboxPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	m.prepareIndexing(config)
+
+	var message bytes.Buffer
+	n := 0
+	flush := func() error {
+		if message.Len() == 0 {
+			return nil
+		}
+		n++
+		m.Stats.Files++
+		err := m.indexMessage(mboxPath+"#"+strconv.Itoa(n), message.Bytes(), config)
+		message.Reset()
+		return err
+	}
+
+	reader := bufio.NewReader(file)
+	previousBlank := true
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			if previousBlank && bytes.HasPrefix(line, []byte("From ")) {
+				if err := flush(); err != nil {
+					return err
+				}
+			} else {
+				// mboxrd quotes "From " at the start of body lines with ">"
+				if bytes.HasPrefix(bytes.TrimLeft(line, ">"), []byte("From ")) && line[0] == '>' {
+					line = line[1:]
+				}
+				message.Write(line)
+			}
+			previousBlank = len(bytes.TrimSpace(line)) == 0
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return flush()
+}
+
+// indexMaildir adds every message in the cur and new folders of a Maildir
+func (m *Model) indexMaildir(dir string, config *Config) error {
+	m.prepareIndexing(config)
+	for _, sub := range []string{"cur", "new"} {
+		entries, err := os.ReadDir(path.Join(dir, sub))
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			filePath := path.Join(dir, sub, entry.Name())
+			raw, err := os.ReadFile(filePath)
+			if err != nil {
+				return err
+			}
+			m.Stats.Files++
+			if err := m.indexMessage(filePath, raw, config); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
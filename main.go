@@ -1,138 +1,273 @@
-package main
+// Package sego indexes documents and searches them, cmd/sego is its command
+// line
+package sego
 
 import (
 	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
 	"log"
 	"math"
 	"os"
-	"path"
+	"regexp"
 	"sort"
-	"unicode"
+	"strings"
+	"time"
 )
 
-type lexer struct {
-	content []rune
-}
-
-func NewLexer(content []rune) *lexer {
-	return &lexer{content: content}
-}
-
-func (l *lexer) trimLeft() {
-	for len(l.content) > 0 && unicode.IsSpace(l.content[0]) {
-		l.content = l.content[1:]
+func readFile(filePath string) ([]byte, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		log.Fatal(err)
 	}
+	return content, err
 }
 
-func (l *lexer) chop(n int) []rune {
-	token := l.content[0:n]
-	l.content = l.content[n:]
-	return token
-}
-
-func (l *lexer) chopWhile(predicate func(rune) bool) []rune {
-	n := 0
-	for n < len(l.content) && predicate(l.content[n]) {
-		n++
+// readLimit reads at most limit bytes, 0 means no limit
+func readLimit(r io.Reader, limit int64) ([]byte, error) {
+	if limit <= 0 {
+		return io.ReadAll(r)
 	}
-	return l.chop(n)
+	return io.ReadAll(io.LimitReader(r, limit))
 }
 
-func (l *lexer) Next() (value []rune, hasNext bool) {
-	l.trimLeft()
-	if len(l.content) == 0 {
-		return nil, false
-	}
-
-	// HTML Tags, tokenize but don't return them as tokens
-	if l.content[0] == '<' {
-		n := 0
-		for n < len(l.content) && l.content[n] != '>' {
-			n++
-		}
-		l.content = l.content[n+1:]
-		return nil, true
+// readDir lists the files below dirPath, symlinked directories are only
+// descended into with config.FollowSymlinks. Files that were skipped are returned with
+// the reason why.
+func readDir(dirPath string, config *Config) ([]string, map[string]string, error) {
+	w := newWalker(dirPath, config)
+	if info, err := os.Stat(dirPath); err == nil {
+		w.visit(dirPath, info)
 	}
-
-	if unicode.IsNumber(l.content[0]) {
-		return l.chopWhile(func(r rune) bool {
-			return unicode.IsNumber(r)
-		}), true
+	if err := w.walk(dirPath, nil); err != nil {
+		return nil, nil, err
 	}
 
-	if unicode.IsLetter(l.content[0]) {
-		return l.chopWhile(func(r rune) bool {
-			return (unicode.IsLetter(r) || unicode.IsNumber(r))
-		}), true
+	if !config.GitTracked {
+		return w.paths, w.skipped, nil
 	}
-
-	return l.chop(1), true
-}
-
-func readFile(filePath string) ([]byte, error) {
-	content, err := os.ReadFile(filePath)
+	tracked, err := gitTracked(dirPath)
 	if err != nil {
-		log.Fatal(err)
+		return nil, nil, err
 	}
-	return content, err
-}
-
-func readDir(dirPath string) ([]string, error) {
-	dirContent, err := os.ReadDir(dirPath)
-	paths := make([]string, 0)
-	for _, file := range dirContent {
-		if !file.IsDir() {
-			paths = append(paths, path.Join(dirPath, file.Name()))
+	paths := make([]string, 0, len(tracked))
+	for _, p := range w.paths {
+		if tracked[p] {
+			paths = append(paths, p)
+		} else {
+			w.skipped[p] = "not tracked by git"
 		}
 	}
-
-	return paths, err
+	return paths, w.skipped, nil
 }
 
 type TermFreq = map[string]int
-type TermFreqTable = map[string]TermFreq
-type DocFreq = map[string]int
 
-func calculateTF(term string, tfTable TermFreq) float32 {
-	// TODO: cache this
+// TermFreqTable is saved as posting lists, see postings.go
+type TermFreqTable map[string]TermFreq
+
+// DocFreq is saved as a term dictionary, see termdict.go
+type DocFreq map[string]int
+
+func sumTF(tfTable TermFreq) int {
 	var sumOfTerms int = 0
 	for _, v := range tfTable {
 		sumOfTerms += v
 	}
-	return float32(tfTable[term]) / float32(sumOfTerms)
+	return sumOfTerms
 }
 
-func calculateIDF(df int, n int) float32 {
-	return float32(math.Log(float64(n) / math.Max(float64(df), 1)))
+func calculateTF(term string, tfTable TermFreq, length int) float64 {
+	// empty documents contain no term
+	if length == 0 {
+		return 0
+	}
+	return float64(tfTable[term]) / float64(length)
+}
+
+type IDFVariant string
+
+const (
+	// log(N/df), zero or negative for terms in every document
+	IDFPlain IDFVariant = "plain"
+	// log(1+N/df), always positive
+	IDFSmooth IDFVariant = "smooth"
+	// log(1+(N-df+0.5)/(df+0.5)) as in BM25, never negative
+	IDFProbabilistic IDFVariant = "probabilistic"
+)
+
+func parseIDFVariant(s string, v *IDFVariant) error {
+	switch IDFVariant(s) {
+	case IDFPlain, IDFSmooth, IDFProbabilistic:
+		*v = IDFVariant(s)
+		return nil
+	}
+	return fmt.Errorf("unknown IDF variant %q, use plain, smooth or probabilistic", s)
+}
+
+func calculateIDF(df int, n int, variant IDFVariant) float64 {
+	switch variant {
+	case IDFSmooth:
+		return math.Log(1 + float64(n)/math.Max(float64(df), 1))
+	case IDFProbabilistic:
+		return math.Log(1 + (float64(n)-float64(df)+0.5)/(float64(df)+0.5))
+	}
+	return math.Log(float64(n) / math.Max(float64(df), 1))
 }
 
 type Model struct {
-	TF TermFreqTable `json:"tf"`
-	DF DocFreq       `json:"df"`
+	TF    TermFreqTable `json:"tf"`
+	DF    DocFreq       `json:"df"`
+	Lexer LexerOptions  `json:"lexer"`
+	// analyzers documents were indexed with, queries always use Lexer
+	Analyzers map[string]LexerOptions `json:"analyzers,omitempty"`
+	// version of the built-in analysis the documents were indexed with, 0 for
+	// indexes from before it was recorded
+	Analysis int `json:"analysis,omitempty"`
+	// fingerprint of Lexer, Analyzers, their dictionaries and Plugins the
+	// documents were indexed with
+	AnalyzerHash string `json:"analyzer_hash,omitempty"`
+	// languages detected among the documents when Lexer.Lang is auto
+	Languages []string             `json:"languages,omitempty"`
+	Docs      map[string]*Document `json:"docs,omitempty"`
+	Stats     *IndexStats          `json:"stats,omitempty"`
+	// documents deleted since the index was last compacted
+	Deleted map[string]bool `json:"deleted,omitempty"`
+	// set once terms were pruned, documents keep their lengths and so may be
+	// longer than their terms add up to
+	Pruned bool `json:"pruned,omitempty"`
+	// set when indexed as a source code repository
+	Code *CodeOptions `json:"code,omitempty"`
+	// terms in the anchor text of links to crawled pages by URL, and how much
+	// they count compared to the content
+	Anchors     map[string]TermFreq `json:"anchors,omitempty"`
+	AnchorBoost float32             `json:"anchor_boost,omitempty"`
+	// terms in the alt text, titles and ARIA labels of HTML documents by ID,
+	// and how much they count compared to the content
+	AttrText  map[string]TermFreq `json:"attr_text,omitempty"`
+	AttrBoost float32             `json:"attr_boost,omitempty"`
+	// heading level pages were split into sections at, 0 if they weren't,
+	// and words per chunk long documents were split into
+	Sections  int `json:"sections,omitempty"`
+	ChunkSize int `json:"chunk_size,omitempty"`
+	// section and chunk IDs by the path of their page, built when first needed
+	sections map[string][]string
+	// links between crawled pages by URL, the PageRank computed from them
+	// scaled to 0..1, and how much it adds to the rank of a page
+	Links          map[string][]link  `json:"links,omitempty"`
+	PageRank       map[string]float64 `json:"pagerank,omitempty"`
+	PageRankWeight float64            `json:"pagerank_weight,omitempty"`
+	// signals of document quality whatever the query and their weights
+	Quality *QualityOptions `json:"quality,omitempty"`
+	// rank multipliers curators set for documents by path
+	Boosts map[string]float64 `json:"boosts,omitempty"`
+	// plugins providing the token filters of Lexer and Analyzers
+	Plugins []string `json:"plugins,omitempty"`
+	// last document ID handed out
+	NextID uint64 `json:"next_id,omitempty"`
+	// how terms are weighted by rarity, plain if empty
+	IDF IDFVariant `json:"idf,omitempty"`
+	// query terms in more than this share of the documents are ignored, 0 keeps all
+	StopwordFraction float64 `json:"stopword_fraction,omitempty"`
+	// how documents were embedded, queries have to be embedded the same way
+	Embedding *EmbeddingOptions `json:"embedding,omitempty"`
+	// unit length document embeddings by ID
+	Vectors     map[string][]float32 `json:"vectors,omitempty"`
+	VectorIndex *VectorIndex         `json:"vector_index,omitempty"`
+	// how terms are split into n-grams, the n-grams of each document by ID and
+	// how many documents have each
+	NGrams *NGramOptions       `json:"ngrams,omitempty"`
+	Grams  map[string]TermFreq `json:"grams,omitempty"`
+	GramDF DocFreq             `json:"gram_df,omitempty"`
+	// regexp whose first group is the version of a document in its path,
+	// searches default to the newest version
+	VersionPattern string `json:"version_pattern,omitempty"`
+	versionRe      *regexp.Regexp
+	// path => document ID
+	paths map[string]string
+	// span of what is being indexed, for tracing its phases
+	trace *span
+	// approximate memory of the term tables, and where those of documents
+	// indexed over the limit went
+	memory int64
+	spill  *spill
+	// statistics of the whole index when this is one of its segments
+	corpus *corpusStats
+	// the vocabulary as loaded, for expanding prefixes
+	terms *termList
+	// the term tables as loaded inverted, for queries requiring terms
+	postings *postingCache
+	// goroutines a search scores with, 0 means GOMAXPROCS
+	threads int
+
+	// bumped on every change, for caching what is derived from the model
+	version uint64
 }
 
 func newModel() *Model {
 	return &Model{
-		TF: make(map[string]map[string]int),
-		DF: make(map[string]int),
+		TF:    make(map[string]map[string]int),
+		DF:    make(map[string]int),
+		Docs:  make(map[string]*Document),
+		paths: make(map[string]string),
+		// documents are indexed with the built-in analysis as it is now
+		Analysis: analysisVersion,
 	}
 }
 
 func newModelFromJson(path string) (*Model, error) {
+	if isSegmented(path) {
+		return loadSegmented(path)
+	}
 	data, err := readFile(path)
 	if err != nil {
 		return nil, err
 	}
+	return parseModel(data)
+}
+
+// Open loads the index at path, a JSON file or a segmented index directory,
+// to search it with SearchAST
+func Open(path string) (*Model, error) {
+	if isSegmented(path) {
+		return loadSegmented(path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseModel(data)
+}
 
+func parseModel(data []byte) (*Model, error) {
 	var model Model
 	if err := json.Unmarshal(data, &model); err != nil {
 		return nil, err
 	}
+	model.migrateIDs()
+	model.keepAnalysis()
+	if err := model.setVersionPattern(model.VersionPattern); err != nil {
+		return nil, err
+	}
+	model.terms = &termList{}
+	if model.postings == nil {
+		model.postings = &postingCache{}
+	}
+	if err := model.loadPlugins(); err != nil {
+		return nil, err
+	}
 
 	return &model, nil
 }
 
 func (m *Model) saveAsJson(path string) error {
+	if isSegmented(path) {
+		return m.saveSegmented(path)
+	}
+	if m.spill != nil && len(m.spill.segments) > 0 {
+		return m.saveMerged(path)
+	}
 	json, err := json.MarshalIndent(m, "", "  ")
 	if err != nil {
 		log.Fatal(err)
@@ -140,131 +275,845 @@ func (m *Model) saveAsJson(path string) error {
 	return os.WriteFile(path, json, 0666)
 }
 
-func (m *Model) indexFolder(path string) error {
-	paths, err := readDir(path)
+func (m *Model) setupAnalyzers(config *Config) {
+	m.Analyzers = builtinAnalyzers(m.Lexer)
+	for name, opts := range config.Analyzers {
+		m.Analyzers[name] = opts
+	}
+	m.keepAnalysis()
+	m.stampAnalyzer()
+}
+
+// index adds every document of source, replacing what was indexed under the
+// same names before. Documents of the source that are gone are removed, or
+// moved if their content shows up under a new name.
+func (m *Model) index(source Source, config *Config) error {
+	if config.Code {
+		config.applyCodeMode()
+		m.Code = &CodeOptions{NameBoost: config.NameBoost, ReadmeBoost: config.ReadmeBoost}
+	}
+
+	names, skipped, err := source.List()
 	if err != nil {
 		return err
 	}
 
-	for _, filePath := range paths {
-		log.Printf("Indexing: %s", filePath)
-		content, err := readFile(filePath)
-		if err != nil {
-			return err
-		}
-
-		tf := make(TermFreq)
+	m.setupAnalyzers(config)
+	renames := m.goneDocuments(source, names)
+	if config.MaxMemory > 0 && m.spill == nil {
+		m.spill = &spill{}
+	}
 
-		lexer := NewLexer([]rune(string(content)))
+	stats := newIndexStats()
+	m.Stats = stats
+	for name, reason := range skipped {
+		stats.Files++
+		stats.skip(name, reason)
+	}
 
-		for {
-			token, hasNext := lexer.Next()
-			if !hasNext {
-				break
-			}
+	for _, name := range names {
+		stats.Files++
 
-			if token == nil {
+		// one document that can't be read, like a URL answering 404, doesn't
+		// stop the others from being indexed
+		info, err := source.Stat(name)
+		if err != nil {
+			stats.skip(name, err.Error())
+			continue
+		}
+		sizeLimited := ""
+		if config.MaxFileSize > 0 && info.Size() > config.MaxFileSize {
+			sizeLimited = fmt.Sprintf("%d bytes, max file size is %d", info.Size(), config.MaxFileSize)
+			if config.OnLimit == "skip" {
+				stats.skip(name, sizeLimited)
 				continue
 			}
+		}
 
-			for i := range token {
-				token[i] = unicode.ToUpper(token[i])
+		log.Printf("Indexing: %s", name)
+		span := m.trace.child("document")
+		span.set("sego.path", name)
+		read := span.child("read")
+		r, err := source.Open(name)
+		if err != nil {
+			read.finish()
+			span.fail(err)
+			span.finish()
+			stats.skip(name, err.Error())
+			continue
+		}
+		content, err := readLimit(r, config.MaxFileSize)
+		r.Close()
+		read.set("sego.bytes", len(content))
+		read.finish()
+		if err != nil {
+			return err
+		}
+		if m.rename(renames, name, content) {
+			stats.Indexed++
+			if doc, ok := m.document(name); ok {
+				stats.Tokens += doc.Length
+			}
+			if m.Quality != nil {
+				m.setModified(name, info.ModTime())
+			}
+			span.set("sego.renamed", true)
+			span.finish()
+			continue
+		}
+		parent := m.trace
+		m.trace = span
+		err = m.indexDocument(name, content, sizeLimited, config, stats)
+		m.trace = parent
+		span.fail(err)
+		span.finish()
+		if err != nil {
+			return err
+		}
+		if m.Quality != nil {
+			m.setModified(name, info.ModTime())
+		}
+		if config.MaxMemory > 0 && m.memory > config.MaxMemory {
+			if err := m.spillSegment(); err != nil {
+				return err
 			}
+		}
+	}
+	m.removeGone(renames)
 
-			// omit everything less or equal than 2 chars to make table smaller
-			// if len(token) <= 2 {
-			// 	continue
-			// }
+	log.Printf("Indexed %s", stats)
+	return nil
+}
 
-			tf[string(token)]++
-		}
+// indexDocument analyzes content and adds it to the index under path,
+// replacing what was indexed there before. sizeLimited is the reason content
+// was cut short by the caller, if it was.
+func (m *Model) indexDocument(path string, content []byte, sizeLimited string, config *Config, stats *IndexStats) error {
+	hash := contentHash(content)
+	span := m.trace.child("extract")
+	content, meta, err := extractText(path, content, config)
+	span.fail(err)
+	span.finish()
+	if err != nil {
+		stats.skip(path, err.Error())
+		return nil
+	}
+	if isBinary(content) {
+		stats.skip(path, "binary")
+		return nil
+	}
+	if sizeLimited != "" {
+		stats.truncate(path, sizeLimited)
+	}
 
-		for t := range tf {
-			m.DF[t] += 1
+	opts := m.Lexer
+	if name := config.analyzerName(path, content); name != "" {
+		analyzer, ok := m.Analyzers[name]
+		if !ok {
+			return fmt.Errorf("%s: unknown analyzer %q", path, name)
 		}
+		opts = analyzer
+	}
+	if config.cleansHTML() && (opts.Markup == MarkupHTML || looksLikeHTML(content)) {
+		if content, err = config.cleanHTML(path, content); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	detected := ""
+	if opts.Lang == LangAuto {
+		detected = detectLanguage(string(content))
+		opts.Lang = detected
+		if detected != "" {
+			m.noteLanguage(detected)
+		}
+	}
 
-		m.TF[filePath] = tf
-
+	d := &extracted{path: path, hash: hash, content: content, meta: meta, opts: opts, lang: detected}
+	if config.Sections > 0 {
+		m.Sections = config.Sections
+		isHTML := opts.Markup == MarkupHTML || looksLikeHTML(content)
+		if sections := splitSections(content, isHTML, config.Sections); len(sections) > 1 {
+			return m.indexSections(d, sections, sizeLimited, config, stats)
+		}
+	} else if config.ChunkSize > 0 {
+		m.ChunkSize = config.ChunkSize
+		isHTML := opts.Markup == MarkupHTML || looksLikeHTML(content)
+		if chunks := splitChunks(pageText(content, isHTML), config.ChunkSize, config.ChunkOverlap); len(chunks) > 1 {
+			return m.indexChunks(d, chunks, sizeLimited, config, stats)
+		}
 	}
-	return nil
+	// the page may have been split before
+	m.removeSections(path, map[string]bool{path: true})
+	return m.indexExtracted(d, sizeLimited, config, stats)
 }
 
-func tokenize(term string) []string {
-	lexer := NewLexer([]rune(string(term)))
-	result := make([]string, 0)
+// extracted is the text of a document ready to be analyzed
+type extracted struct {
+	path    string
+	hash    string
+	content []byte
+	meta    *Document
+	opts    LexerOptions
+	// language detected, if it was
+	lang string
+	// page the document is a section or chunk of, and the number of the chunk
+	parent string
+	chunk  int
+}
 
-	for {
-		token, hasNext := lexer.Next()
-		if !hasNext {
-			break
+// indexExtracted analyzes the text of a document and adds it to the index,
+// replacing what was indexed under its path before
+func (m *Model) indexExtracted(d *extracted, sizeLimited string, config *Config, stats *IndexStats) error {
+	path, content, opts := d.path, d.content, d.opts
+	span := m.trace.child("tokenize")
+	tokens, more := tokenizeLimit(string(content), opts, config.MaxTokensPerDoc)
+	span.set("sego.tokens", len(tokens))
+	span.finish()
+	if more {
+		reason := fmt.Sprintf("more than %d tokens", config.MaxTokensPerDoc)
+		if config.OnLimit == "skip" {
+			stats.skip(path, reason)
+			return nil
 		}
-
-		if token == nil {
-			continue
+		if sizeLimited == "" {
+			stats.truncate(path, reason)
 		}
+	}
 
-		for i := range token {
-			token[i] = unicode.ToUpper(token[i])
+	span = m.trace.child("merge")
+	defer span.finish()
+	tf := make(TermFreq)
+	for _, token := range tokens {
+		tf[token]++
+	}
+
+	id := m.assignID(path)
+	for t := range m.TF[id] {
+		m.DF[t] -= 1
+	}
+	for t := range tf {
+		m.DF[t] += 1
+	}
+
+	m.trackMemory(id, m.TF[id], tf)
+	m.TF[id] = tf
+	m.indexAttributes(id, content, opts, config)
+	if config.NGrams != nil {
+		m.NGrams = config.NGrams
+	}
+	if m.NGrams != nil {
+		m.indexGrams(id, tf)
+	}
+	m.version++
+	delete(m.Deleted, id)
+	doc := extractMetadata(content)
+	doc.Path = path
+	doc.Hash = d.hash
+	doc.Parent = d.parent
+	doc.Chunk = d.chunk
+	if config.VersionPattern != "" && config.VersionPattern != m.VersionPattern {
+		if err := m.setVersionPattern(config.VersionPattern); err != nil {
+			return err
 		}
+	}
+	doc.Version = m.versionOf(path)
+	if doc.Version != "" {
+		doc.SimHash = simHash(tf)
+	}
+	if ttl := config.ttl(); ttl > 0 && doc.Expires == nil {
+		expires := time.Now().Add(ttl)
+		doc.Expires = &expires
+	}
+	doc.merge(d.meta)
+	if doc.Lang == "" {
+		doc.Lang = d.lang
+	}
+	doc.Length = len(tokens)
+	if m.Code != nil && isReadme(path) {
+		doc.Boost = m.Code.ReadmeBoost
+	}
+	m.Docs[id] = doc
+	if d.parent != "" {
+		m.addSection(d.parent, id)
+	}
+	if config.Embedding != nil {
+		m.embedDocument(id, string(content), config.Embedding)
+	}
+	stats.Indexed++
+	stats.Tokens += len(tokens)
+	return nil
+}
+
+// docLength is the number of tokens in a document, computed from its terms for
+// indexes written before lengths were stored
+func (m *Model) docLength(id string) int {
+	if doc, ok := m.Docs[id]; ok && doc.Length > 0 {
+		return doc.Length
+	}
+	return sumTF(m.TF[id])
+}
 
-		// omit everything less or equal than 2 chars to make table smaller
-		// if len(token) <= 2 {
-		// 	continue
-		// }
+type termWeight struct {
+	Term   string
+	Weight float64
+}
 
-		result = append(result, string(token))
+// queryWeights weighs each distinct query term by how often it occurs in the
+// query times its IDF, so "shader shader texture" favors shader. Terms are in
+// query order so ranks add up the same every time.
+func (m *Model) queryWeights(tokens []string, n int) []termWeight {
+	qtf := make(map[string]int)
+	weights := make([]termWeight, 0)
+	for _, token := range tokens {
+		if qtf[token] == 0 {
+			weights = append(weights, termWeight{Term: token})
+		}
+		qtf[token]++
 	}
+	for i, w := range weights {
+		weights[i].Weight = float64(qtf[w.Term]) * calculateIDF(m.docFreq(w.Term), n, m.IDF)
+	}
+	return weights
+}
 
+func (m *Model) search(query string) SearchResults {
+	result, _ := m.searchTimed(query)
 	return result
 }
 
-func (m *Model) search(query string) SearchResults {
-	result := make(SearchResults, 0)
-	tokens := tokenize(query)
+// SearchTiming is how long each phase of a search took
+type SearchTiming struct {
+	Tokenize   time.Duration `json:"tokenize"`
+	Candidates time.Duration `json:"candidates"`
+	Score      time.Duration `json:"score"`
+	Sort       time.Duration `json:"sort"`
+}
 
-	for path, tfTable := range m.TF {
-		var rank float32 = 0
-		for _, token := range tokens {
-			rank += calculateTF(token, tfTable) * calculateIDF(m.DF[token], len(m.TF))
-		}
+func (t *SearchTiming) Total() time.Duration {
+	return t.Tokenize + t.Candidates + t.Score + t.Sort
+}
 
-		result = append(result, SearchResult{
-			Path: path,
-			Rank: rank,
-		})
-	}
+func (t *SearchTiming) String() string {
+	return fmt.Sprintf("tokenize %s, candidates %s, score %s, sort %s, total %s",
+		t.Tokenize, t.Candidates, t.Score, t.Sort, t.Total())
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func (m *Model) searchTimed(query string) (SearchResults, *SearchTiming) {
+	return m.searchDocs(query, nil)
+}
+
+// searchDocs searches docs, the IDs of the documents as listed by docIDs, nil
+// to list them
+func (m *Model) searchDocs(query string, docs []string) (SearchResults, *SearchTiming) {
+	timing := &SearchTiming{}
+	plan := m.plan(query, docs, timing)
+
+	start := time.Now()
+	result := m.scoreShards(plan)
+	timing.Score = time.Since(start)
+	start = time.Now()
 
 	// result = sortMap(result)
 
-	sort.Sort(sort.Reverse(result))
+	sort.Sort(result)
+	result = m.foldChunks(result)
+	timing.Sort = time.Since(start)
+
+	return result, timing
+}
+
+// searchPlan is what scoring a query needs
+type searchPlan struct {
+	docs []string
+	// documents that may match, nil means all
+	allowed     bitmap
+	tokens      []string
+	weights     []termWeight
+	gramWeights []termWeight
+	// what freshness is measured from, the same for every document
+	now time.Time
+}
+
+// plan analyzes query and finds the candidates among docs, nil means all
+// documents, timing the tokenize and candidates phases
+func (m *Model) plan(query string, docs []string, timing *SearchTiming) *searchPlan {
+	start := time.Now()
+	q := parseQuery(query)
+	tokens := m.analyzeQuery(q.Text, q.Filters)
+	tokens = append(tokens, m.expandPrefixes(q.Prefixes)...)
+	timing.Tokenize = time.Since(start)
+	start = time.Now()
+
+	if docs == nil {
+		docs = m.docIDs()
+	}
+	allowed := m.filterBitmap(docs, q.Filters)
+	allowed = m.latestVersions(docs, q.Filters, allowed)
+	if len(q.Required) > 0 {
+		allowed = m.requireTerms(docs, q.Required, q.Filters, allowed)
+	}
+	n := m.corpus.size(len(docs))
+	tokens = m.dropStopwords(tokens, n)
+	plan := &searchPlan{
+		docs:        docs,
+		allowed:     allowed,
+		tokens:      tokens,
+		weights:     m.queryWeights(tokens, n),
+		gramWeights: m.gramWeights(tokens, n),
+		now:         time.Now(),
+	}
+	timing.Candidates = time.Since(start)
+	return plan
+}
+
+// score ranks the documents plan.docs[lo:hi] that plan allows
+func (m *Model) score(plan *searchPlan, lo, hi int) SearchResults {
+	weights, gramWeights := plan.weights, plan.gramWeights
+	result := make(SearchResults, 0)
+	for i := lo; i < hi; i++ {
+		if plan.allowed != nil && !plan.allowed.has(i) {
+			continue
+		}
+		id := plan.docs[i]
+
+		tfTable := m.TF[id]
+		length := m.docLength(id)
+		var rank float64 = 0
+		for _, w := range weights {
+			rank += calculateTF(w.Term, tfTable, length) * w.Weight
+		}
+		if m.AnchorBoost > 0 {
+			rank += m.anchorRank(m.docPath(id), weights)
+		}
+		if m.AttrBoost > 0 {
+			rank += m.attributeRank(id, weights)
+		}
+		if gramWeights != nil {
+			rank += m.gramRank(id, gramWeights)
+		}
+		rank *= m.boost(id, plan.tokens, plan.now)
 
+		r := SearchResult{
+			ID:   id,
+			Path: m.docPath(id),
+			Rank: rank,
+		}
+		if doc, ok := m.Docs[id]; ok {
+			r.Title = doc.Title
+			r.Description = doc.Description
+			r.Version = doc.Version
+			r.Parent = doc.Parent
+		}
+		result = append(result, r)
+	}
 	return result
 }
 
 type SearchResult struct {
-	Path string
-	Rank float32
+	ID          string  `json:"id"`
+	Path        string  `json:"path"`
+	Rank        float64 `json:"rank"`
+	Title       string  `json:"title,omitempty"`
+	Description string  `json:"description,omitempty"`
+	Version     string  `json:"version,omitempty"`
+	// page the result is a section of, its path links to the section
+	Parent string `json:"parent,omitempty"`
+	// other versions of the page with the same content, newest first
+	Versions []string `json:"versions,omitempty"`
+	// results from the same section left out when grouping
+	More int `json:"more,omitempty"`
+	// shown first because curators pinned it for the query
+	Pinned bool `json:"pinned,omitempty"`
+	// fragment of the text with the query terms marked, when asked for
+	Highlight string `json:"highlight,omitempty"`
 }
 type SearchResults []SearchResult
 
-func (a SearchResults) Len() int           { return len(a) }
-func (a SearchResults) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
-func (a SearchResults) Less(i, j int) bool { return a[i].Rank < a[j].Rank }
+func (a SearchResults) Len() int      { return len(a) }
+func (a SearchResults) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+
+// Less orders by descending rank, ties by path so results are deterministic
+func (a SearchResults) Less(i, j int) bool {
+	if a[i].Rank != a[j].Rank {
+		return a[i].Rank > a[j].Rank
+	}
+	return a[i].Path < a[j].Path
+}
+
+func runIndex(args []string) {
+	fs := flag.NewFlagSet("index", flag.ExitOnError)
+	indexPath := fs.String("o", "index-new.json", "where to write the index")
+	model := newModel()
+	model.Lexer.registerFlags(fs)
+	fs.Func("idf", "IDF variant searches use: plain, smooth or probabilistic", func(s string) error {
+		return parseIDFVariant(s, &model.IDF)
+	})
+	config := newConfig()
+	config.registerFlags(fs)
+	dryRun := fs.Bool("dry-run", false, "only report what would be indexed")
+	update := fs.Bool("update", false, "update the index at -o, keeping documents that moved and dropping removed ones")
+	feeds := make([]string, 0)
+	fs.Func("feed", "RSS or Atom feed URL to index the entries of (repeatable)", func(s string) error {
+		feeds = append(feeds, s)
+		return nil
+	})
+	refresh := fs.Duration("refresh", 0, "keep running and fetch the feeds again this often")
+	mboxes := make([]string, 0)
+	fs.Func("mbox", "mbox file to index the messages of (repeatable)", func(s string) error {
+		mboxes = append(mboxes, s)
+		return nil
+	})
+	maildirs := make([]string, 0)
+	fs.Func("maildir", "Maildir to index the messages of (repeatable)", func(s string) error {
+		maildirs = append(maildirs, s)
+		return nil
+	})
+	repos := make([]string, 0)
+	fs.Func("git", "git repository to index the commits of (repeatable)", func(s string) error {
+		repos = append(repos, s)
+		return nil
+	})
+	gitDiffs := fs.Bool("git-diffs", false, "index the diffs of commits along with their messages")
+	fs.Parse(args)
+	if config.MaxMemory > 0 && (*dryRun || *refresh > 0) {
+		log.Fatal("-max-memory can't be combined with -dry-run or -refresh")
+	}
+	if fs.NArg() > 1 || (fs.NArg() == 0 && len(feeds)+len(mboxes)+len(maildirs)+len(repos) == 0 && config.SQL == nil) {
+		log.Fatal("usage: sego index [flags] <dir|archive|url>")
+	}
+	model.Plugins = config.Plugins
+	if err := model.loadPlugins(); err != nil {
+		log.Fatal(err)
+	}
+	for name, opts := range config.Analyzers {
+		if err := opts.checkFilters(); err != nil {
+			log.Fatalf("analyzer %s: %s", name, err)
+		}
+	}
+	if _, err := os.Stat(*indexPath); err == nil && *update {
+		// the index keeps the lexer it was built with
+		existing, err := newModelFromJson(*indexPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		known := make(map[string]bool)
+		for _, p := range existing.Plugins {
+			known[p] = true
+		}
+		for _, p := range config.Plugins {
+			if !known[p] {
+				existing.Plugins = append(existing.Plugins, p)
+			}
+		}
+		model = existing
+	}
+	if config.Boosts != "" {
+		boosts, err := loadBoosts(config.Boosts)
+		if err != nil {
+			log.Fatal(err)
+		}
+		model.Boosts = boosts
+	}
+	if config.Quality != nil {
+		if err := config.Quality.check(); err != nil {
+			log.Fatal(err)
+		}
+		model.Quality = config.Quality
+	}
+
+	model.trace = tracing.start(nil, "index")
+	model.trace.set("sego.index", *indexPath)
+	defer tracing.flush()
+	defer model.trace.finish()
+
+	if fs.NArg() == 1 {
+		source, err := openSource(fs.Arg(0), config)
+		if err != nil {
+			config.fatal(*indexPath, err)
+		}
+		defer closeSource(source)
+		if err := model.index(source, config); err != nil {
+			config.fatal(*indexPath, err)
+		}
+	}
+	for _, feedURL := range feeds {
+		if err := model.indexFeed(feedURL, config); err != nil {
+			config.fatal(*indexPath, err)
+		}
+	}
+	for _, mbox := range mboxes {
+		if err := model.indexMbox(mbox, config); err != nil {
+			config.fatal(*indexPath, err)
+		}
+	}
+	for _, maildir := range maildirs {
+		if err := model.indexMaildir(maildir, config); err != nil {
+			config.fatal(*indexPath, err)
+		}
+	}
+	for _, repo := range repos {
+		if err := model.indexGitLog(repo, *gitDiffs, config); err != nil {
+			config.fatal(*indexPath, err)
+		}
+	}
+	if config.SQL != nil {
+		if err := model.indexSQL(config.SQL, config); err != nil {
+			config.fatal(*indexPath, err)
+		}
+	}
+	model.indexVectors()
+	if *dryRun {
+		if err := model.dryRunReport(os.Stdout); err != nil {
+			config.fatal(*indexPath, err)
+		}
+		return
+	}
+	if err := model.saveAsJson(*indexPath); err != nil {
+		config.fatal(*indexPath, err)
+	}
+	config.emit(&Event{Event: EventIndexComplete, Index: *indexPath, Stats: model.Stats})
+
+	for *refresh > 0 && len(feeds) > 0 {
+		time.Sleep(*refresh)
+		before := model.docHashes()
+		for _, feedURL := range feeds {
+			// a feed being down shouldn't stop the others from refreshing
+			if err := model.indexFeed(feedURL, config); err != nil {
+				log.Printf("Refreshing %s: %s", feedURL, err)
+			}
+		}
+		model.alert(config.Alerts, model.changedSince(before))
+		if err := model.saveAsJson(*indexPath); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+func runSearch(args []string) {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	indexPath := fs.String("index", "index-new.json", "index to search")
+	limit := fs.Int("n", 10, "number of results to show")
+	timing := fs.Bool("timing", false, "print how long each phase of the search took")
+	normalize := fs.String("normalize", NormalizeNone, "scale ranks to 0..1: none, max or query")
+	minRank := fs.Float64("min-rank", 0, "leave out results ranked lower than this")
+	semantic := fs.Float64("semantic", 0, "blend in similarity of document and query embeddings with this weight, 0..1")
+	prf := fs.Int("prf", 0, "expand the query with terms of this many top results and search again")
+	prfTerms := fs.Int("prf-terms", 10, "how many terms to expand the query with")
+	boosts := fs.String("boosts", "", "JSON file of paths and rank multipliers to use instead of the index's")
+	pinsPath := fs.String("pins", "", "JSON file of query patterns and the paths to show first for them")
+	blockPath := fs.String("blocklist", "", "file of path or URL patterns to hide from results, one per line")
+	expandVersions := fs.Bool("expand-versions", false, "show every version of a page instead of one result listing them")
+	open := fs.Int("open", 0, "open this result (from 1) in $BROWSER or $EDITOR")
+	phonetic := fs.Bool("phonetic", false, "also find terms that sound like those of the query, for names spelled differently")
+	threads := fs.Int("query-threads", 0, "goroutines to score a query with (default GOMAXPROCS)")
+	var within []string
+	fs.Func("within", "only show results of this earlier query too (repeatable)", func(s string) error {
+		within = append(within, s)
+		return nil
+	})
+	groupBy := fs.String("group-by", GroupNone, "show only the best result per dir, site or page of sections")
+	diversify := fs.String("diversify", GroupNone, "show at most -max-per results per dir, site or page before the rest")
+	maxPer := fs.Int("max-per", 2, "how many results per section -diversify shows first")
+	clusters := fs.Int("clusters", 0, "group the top results into up to this many clusters")
+	clusterTop := fs.Int("cluster-top", 30, "how many of the top results to cluster")
+	stopwords := fs.Float64("auto-stopwords", -1, "ignore query terms in more than this share of documents, overriding the index's setting")
+	var idf IDFVariant
+	fs.Func("idf", "IDF variant to use instead of the index's: plain, smooth or probabilistic", func(s string) error {
+		return parseIDFVariant(s, &idf)
+	})
+	strictAnalyzer := fs.Bool("strict-analyzer", false, "fail instead of warning when the analyzers differ from those the index was built with")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		log.Fatal("usage: sego search [flags] <query>")
+	}
 
-func main() {
-	model, err := newModelFromJson("index-new.json")
+	start := time.Now()
+	model, err := newModelFromJson(*indexPath)
 	if err != nil {
 		log.Fatal(err)
 	}
-	// model := newModel()
-	// model.indexFolder("docs.gl/gl4")
+	if err := model.checkAnalyzer(*strictAnalyzer); err != nil {
+		log.Fatal(err)
+	}
+	loaded := time.Since(start)
+	model.threads = *threads
+	if idf != "" {
+		model.IDF = idf
+	}
+	if *stopwords >= 0 {
+		model.StopwordFraction = *stopwords
+	}
+	if *boosts != "" {
+		if model.Boosts, err = loadBoosts(*boosts); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	query := fs.Arg(0)
+	if *phonetic {
+		query = model.phoneticQuery(query)
+		log.Printf("Expanded query: %s", query)
+	}
+	span := tracing.start(nil, "search")
+	defer tracing.flush()
+	defer span.finish()
+	searchStart := time.Now()
+	searchResult, searchTiming := model.searchTimed(query)
+	if *prf > 0 {
+		query = model.expandQuery(query, searchResult, *prf, *prfTerms)
+		log.Printf("Expanded query: %s", query)
+		searchStart = time.Now()
+		searchResult, searchTiming = model.searchTimed(query)
+	}
+	traceSearch(span, query, searchStart, searchTiming, len(searchResult))
+	searchResult = model.within(searchResult, within)
+	if err := model.normalizeRanks(searchResult, query, *normalize); err != nil {
+		log.Fatal(err)
+	}
+	if *semantic > 0 {
+		queryVector, err := model.Embedding.embedQuery(query)
+		if err != nil {
+			log.Fatal(err)
+		}
+		model.hybridRank(searchResult, queryVector, *semantic)
+	}
+	if *minRank != 0 {
+		searchResult = searchResult.cutoff(*minRank)
+	}
+	if !*expandVersions {
+		searchResult = model.collapseVersions(searchResult)
+	}
+	if searchResult, err = searchResult.group(*groupBy); err != nil {
+		log.Fatal(err)
+	}
+	if searchResult, err = searchResult.diversify(*diversify, *maxPer); err != nil {
+		log.Fatal(err)
+	}
+	if *pinsPath != "" {
+		pins, err := loadPins(*pinsPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		searchResult = model.pin(searchResult, pins.match(fs.Arg(0)))
+	}
+	if *blockPath != "" {
+		blocklist, err := loadBlocklist(*blockPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		searchResult = blocklist.filter(searchResult)
+	}
+	if *clusters > 0 {
+		if len(searchResult) > *clusterTop {
+			searchResult = searchResult[:*clusterTop]
+		}
+		for _, c := range model.cluster(searchResult, *clusters) {
+			log.Printf("[%s] %d results", c.Label, len(c.Results))
+			for _, v := range c.Results {
+				log.Printf("  %s => %f", v.Path, v.Rank)
+			}
+		}
+	} else {
+		if len(searchResult) > *limit {
+			searchResult = searchResult[:*limit]
+		}
+		for _, v := range searchResult {
+			if len(v.Versions) > 0 {
+				log.Printf("%s => %f (%s)", v.Path, v.Rank, strings.Join(v.Versions, ", "))
+			} else if v.Pinned {
+				log.Printf("%s => %f (pinned)", v.Path, v.Rank)
+			} else if v.More > 0 {
+				log.Printf("%s => %f (%d more from this section)", v.Path, v.Rank, v.More)
+			} else {
+				log.Printf("%s => %f", v.Path, v.Rank)
+			}
+		}
+		if err := model.saveLastResults(*indexPath, searchResult); err != nil {
+			log.Printf("Remembering results for sego open: %s", err)
+		}
+		if *open > 0 {
+			locations := make([]string, 0, len(searchResult))
+			for _, r := range searchResult {
+				locations = append(locations, model.location(r))
+			}
+			if err := openResult(locations, *open); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
 
-	searchResult := model.search(os.Args[1])
-	// log.Println(searchResult[:10])
-	for _, v := range searchResult[:10] {
-		log.Printf("%s => %f", v.Path, v.Rank)
+	if *timing {
+		log.Printf("Timing: load %s, %s", loaded, searchTiming)
 	}
+}
 
-	// model.saveAsJson("index-new.json")
+// Main runs the sego command with the arguments of the process
+func Main() {
+	if exe, err := os.Executable(); err == nil {
+		if index, _, ok, err := bundledIndex(exe); err != nil {
+			log.Fatal(err)
+		} else if ok {
+			runBundled(index, os.Args[1:])
+			return
+		}
+	}
+	if len(os.Args) < 2 {
+		log.Fatal("usage: sego [index|crawl|ingest|search|open|repl|serve|daemon|gateway|bench|check|delete|reindex|mv|compact|prune|diff|terms|stopwords|phrases|snapshot|restore|bundle] ...")
+	}
 
+	switch os.Args[1] {
+	case "index":
+		runIndex(os.Args[2:])
+	case "search":
+		runSearch(os.Args[2:])
+	case "daemon":
+		runDaemon(os.Args[2:])
+	case "open":
+		runOpen(os.Args[2:])
+	case "repl":
+		runRepl(os.Args[2:])
+	case "bundle":
+		runBundle(os.Args[2:])
+	case "check":
+		runCheck(os.Args[2:])
+	case "delete":
+		runDelete(os.Args[2:])
+	case "reindex":
+		runReindex(os.Args[2:])
+	case "mv":
+		runMove(os.Args[2:])
+	case "compact":
+		runCompact(os.Args[2:])
+	case "prune":
+		runPrune(os.Args[2:])
+	case "diff":
+		runDiff(os.Args[2:])
+	case "terms":
+		runTerms(os.Args[2:])
+	case "stopwords":
+		runStopwords(os.Args[2:])
+	case "phrases":
+		runPhrases(os.Args[2:])
+	case "serve":
+		runServe(os.Args[2:])
+	case "gateway":
+		runGateway(os.Args[2:])
+	case "bench":
+		runBench(os.Args[2:])
+	case "crawl":
+		runCrawl(os.Args[2:])
+	case "ingest":
+		runIngest(os.Args[2:])
+	case "snapshot":
+		runSnapshot(os.Args[2:])
+	case "restore":
+		runRestore(os.Args[2:])
+	default:
+		// plain `sego <query>` keeps working
+		runSearch(os.Args[1:])
+	}
 }
@@ -0,0 +1,20 @@
+package sego
+
+import "testing"
+
+func TestCalculateTF(t *testing.T) {
+	tests := []struct {
+		tf     TermFreq
+		length int
+		want   float64
+	}{
+		{TermFreq{"A": 2, "B": 2}, 4, 0.5},
+		{TermFreq{"B": 1}, 1, 0},
+		{TermFreq{}, 0, 0},
+	}
+	for _, test := range tests {
+		if got := calculateTF("A", test.tf, test.length); got != test.want {
+			t.Errorf("calculateTF(A, %v, %d) = %g, want %g", test.tf, test.length, got, test.want)
+		}
+	}
+}
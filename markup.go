@@ -0,0 +1,103 @@
+package sego
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	latexComment     = regexp.MustCompile(`(?m)(^|[^\\])%.*$`)
+	latexDisplayMath = regexp.MustCompile(`(?s)\$\$.*?\$\$|\\\[.*?\\\]|\\\(.*?\\\)`)
+	latexInlineMath  = regexp.MustCompile(`\$[^$]*\$`)
+	latexMathEnv     = regexp.MustCompile(`(?s)\\begin\{(equation|align|alignat|gather|multline|math|displaymath|eqnarray)\*?\}.*?\\end\{(equation|align|alignat|gather|multline|math|displaymath|eqnarray)\*?\}`)
+	// commands whose arguments are references, paths or setup rather than text
+	latexDropArgs = regexp.MustCompile(`\\(cite[a-z]*|ref|eqref|autoref|pageref|label|includegraphics|usepackage|documentclass|bibliography|bibliographystyle|input|include|url|href|newcommand|renewcommand|setlength|vspace|hspace)\*?(\[[^\]]*\])*(\{[^{}]*\})?`)
+	latexEnv      = regexp.MustCompile(`\\(begin|end)\{[^}]*\}(\[[^\]]*\])?`)
+	latexCommand  = regexp.MustCompile(`\\[A-Za-z]+\*?(\[[^\]]*\])?`)
+	latexEscape   = regexp.MustCompile(`\\([%&$#_{}])`)
+)
+
+// extractLatex strips commands, math and comments from LaTeX, keeping the
+// text of arguments like \section{...} and \emph{...}
+func extractLatex(content []byte, config *Config) ([]byte, error) {
+	text := string(content)
+	text = latexComment.ReplaceAllString(text, "$1")
+	// escaped dollars aren't math
+	text = strings.ReplaceAll(text, `\$`, "\x00")
+	text = latexMathEnv.ReplaceAllString(text, " ")
+	text = latexDisplayMath.ReplaceAllString(text, " ")
+	text = latexInlineMath.ReplaceAllString(text, " ")
+	text = strings.ReplaceAll(text, "\x00", `\$`)
+	// \href{url}{text} keeps its text
+	text = strings.ReplaceAll(text, `\href`, `\url`)
+	text = latexDropArgs.ReplaceAllString(text, " ")
+	text = latexEnv.ReplaceAllString(text, " ")
+	text = latexEscape.ReplaceAllString(text, "\x00$1")
+	text = latexCommand.ReplaceAllString(text, " ")
+	text = strings.NewReplacer("{", "", "}", "", "~", " ", `\\`, " ", "\x00", "").Replace(text)
+	return []byte(text), nil
+}
+
+var (
+	rstDirective  = regexp.MustCompile(`^(\s*)\.\.\s+(\|[^|]+\|\s+)?([\w:-]+)::\s*(.*)$`)
+	rstComment    = regexp.MustCompile(`^\s*\.\.(\s|$)`)
+	rstOption     = regexp.MustCompile(`^\s+:[\w -]+:.*$`)
+	rstRole       = regexp.MustCompile(`:[\w:+-]+:` + "`")
+	rstReference  = regexp.MustCompile("`([^`<]*?)\\s*(<[^>]*>)?`_{1,2}")
+	rstLiteral    = regexp.MustCompile("``([^`]*)``")
+	rstInterpret  = regexp.MustCompile("`([^`<]*?)\\s*(<[^>]*>)?`")
+	rstEmphasis   = regexp.MustCompile(`\*\*?([^*\s][^*]*)\*\*?`)
+	rstSimpleLink = regexp.MustCompile(`\b(\w+)__?\b`)
+)
+
+// isAdornment reports whether line is a section over- or underline like "====="
+func isAdornment(line string) bool {
+	line = strings.TrimRight(line, " \t\r")
+	if len(line) < 3 || !strings.ContainsRune("=-~^\"'`#*+:._", rune(line[0])) {
+		return false
+	}
+	return strings.Count(line, line[:1]) == len(line)
+}
+
+// directives whose argument is prose worth indexing, the others carry paths
+// or names (image, toctree, code-block, ...)
+var rstTextDirectives = map[string]bool{
+	"note": true, "warning": true, "tip": true, "important": true, "caution": true,
+	"danger": true, "hint": true, "attention": true, "error": true, "admonition": true,
+	"seealso": true, "topic": true, "sidebar": true, "rubric": true, "deprecated": true,
+	"versionadded": true, "versionchanged": true, "title": true, "replace": true,
+}
+
+// extractRST strips directives, comments, section adornments and inline
+// markup from reStructuredText
+func extractRST(content []byte, config *Config) ([]byte, error) {
+	lines := strings.Split(string(content), "\n")
+	result := make([]string, 0, len(lines))
+	inOptions := false
+	for _, line := range lines {
+		if m := rstDirective.FindStringSubmatch(line); m != nil {
+			inOptions = true
+			if rstTextDirectives[m[3]] {
+				result = append(result, m[1]+m[4])
+			}
+			continue
+		}
+		if inOptions && rstOption.MatchString(line) {
+			continue
+		}
+		inOptions = false
+
+		if rstComment.MatchString(line) || isAdornment(line) {
+			continue
+		}
+
+		line = rstRole.ReplaceAllString(line, "`")
+		line = rstLiteral.ReplaceAllString(line, "$1")
+		line = rstReference.ReplaceAllString(line, "$1")
+		line = rstInterpret.ReplaceAllString(line, "$1")
+		line = rstEmphasis.ReplaceAllString(line, "$1")
+		line = rstSimpleLink.ReplaceAllString(line, "$1")
+		result = append(result, line)
+	}
+	return []byte(strings.Join(result, "\n")), nil
+}
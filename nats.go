@@ -0,0 +1,123 @@
+package sego
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// natsConn is just enough of the NATS client protocol to consume a subject
+type natsConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func dialNATS(rawURL string) (*natsConn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	conn, err := net.DialTimeout("tcp", host, 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	c := &natsConn{conn: conn, reader: bufio.NewReader(conn)}
+
+	// the server greets with INFO
+	line, err := c.readLine()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		conn.Close()
+		return nil, fmt.Errorf("%s: unexpected greeting %q", host, line)
+	}
+
+	options := map[string]any{"verbose": false, "pedantic": false, "name": "sego", "lang": "go"}
+	if u.User != nil {
+		options["user"] = u.User.Username()
+		if password, ok := u.User.Password(); ok {
+			options["pass"] = password
+		} else {
+			options["auth_token"] = u.User.Username()
+			delete(options, "user")
+		}
+	}
+	connect, err := json.Marshal(options)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := c.send("CONNECT " + string(connect)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *natsConn) send(line string) error {
+	_, err := io.WriteString(c.conn, line+"\r\n")
+	return err
+}
+
+func (c *natsConn) readLine() (string, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// subscribe to subject, members of the same queue group share its messages
+func (c *natsConn) subscribe(subject, queue string) error {
+	if queue != "" {
+		return c.send(fmt.Sprintf("SUB %s %s 1", subject, queue))
+	}
+	return c.send(fmt.Sprintf("SUB %s 1", subject))
+}
+
+// next waits for the next message, answering the server's pings meanwhile
+func (c *natsConn) next() ([]byte, error) {
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case line == "PING":
+			if err := c.send("PONG"); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return nil, fmt.Errorf("nats: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		case strings.HasPrefix(line, "MSG "):
+			// MSG <subject> <sid> [reply-to] <#bytes>
+			fields := strings.Fields(line)
+			size, err := strconv.Atoi(fields[len(fields)-1])
+			if err != nil {
+				return nil, fmt.Errorf("nats: bad message header %q", line)
+			}
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(c.reader, payload); err != nil {
+				return nil, err
+			}
+			return payload[:size], nil
+		}
+		// +OK, PONG and INFO updates need no answer
+	}
+}
+
+func (c *natsConn) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,148 @@
+package sego
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// NGramOptions index the substrings of terms in a field of their own, so
+// "shad" finds "shader" without adding every substring to the vocabulary
+type NGramOptions struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+	// only the prefixes of terms, for completing what is being typed
+	Edge bool `json:"edge,omitempty"`
+	// how much matching n-grams count compared to matching terms, 0 means 0.5
+	Weight float64 `json:"weight,omitempty"`
+}
+
+func (o *NGramOptions) weight() float64 {
+	if o.Weight == 0 {
+		return 0.5
+	}
+	return o.Weight
+}
+
+// parse reads "min:max" or "n" into o
+func (o *NGramOptions) parse(s string) error {
+	lo, hi, ok := strings.Cut(s, ":")
+	if !ok {
+		hi = lo
+	}
+	var err error
+	if o.Min, err = strconv.Atoi(lo); err != nil {
+		return err
+	}
+	if o.Max, err = strconv.Atoi(hi); err != nil {
+		return err
+	}
+	if o.Min < 1 || o.Max < o.Min {
+		return fmt.Errorf("invalid n-gram sizes %q", s)
+	}
+	return nil
+}
+
+// grams are the n-grams of term from Min to Max runes, or its prefixes of
+// that length for edge n-grams
+func (o *NGramOptions) grams(term string) []string {
+	runes := []rune(term)
+	grams := make([]string, 0)
+	for n := o.Min; n <= o.Max && n <= len(runes); n++ {
+		if o.Edge {
+			grams = append(grams, string(runes[:n]))
+			continue
+		}
+		for i := 0; i+n <= len(runes); i++ {
+			grams = append(grams, string(runes[i:i+n]))
+		}
+	}
+	return grams
+}
+
+// queryGrams are the n-grams a query term is looked up by: the term itself up
+// to Max runes for edge n-grams, its longest n-grams otherwise
+func (o *NGramOptions) queryGrams(term string) []string {
+	runes := []rune(term)
+	if len(runes) < o.Min {
+		return nil
+	}
+	if len(runes) <= o.Max {
+		return []string{term}
+	}
+	if o.Edge {
+		return []string{string(runes[:o.Max])}
+	}
+	grams := make([]string, 0, len(runes)-o.Max+1)
+	for i := 0; i+o.Max <= len(runes); i++ {
+		grams = append(grams, string(runes[i:i+o.Max]))
+	}
+	return grams
+}
+
+// indexGrams replaces the n-grams indexed for a document with those of its
+// terms
+func (m *Model) indexGrams(id string, tf TermFreq) {
+	if m.Grams == nil {
+		m.Grams = make(map[string]TermFreq)
+		m.GramDF = make(DocFreq)
+	}
+	m.removeGrams(id)
+	grams := make(TermFreq)
+	for term, n := range tf {
+		for _, gram := range m.NGrams.grams(term) {
+			grams[gram] += n
+		}
+	}
+	for gram := range grams {
+		m.GramDF[gram]++
+	}
+	m.Grams[id] = grams
+}
+
+func (m *Model) removeGrams(id string) {
+	for gram := range m.Grams[id] {
+		if m.GramDF[gram]--; m.GramDF[gram] <= 0 {
+			delete(m.GramDF, gram)
+		}
+	}
+	delete(m.Grams, id)
+}
+
+// gramWeights weighs the n-grams of the query terms by their IDF in the n-gram
+// field
+func (m *Model) gramWeights(tokens []string, n int) []termWeight {
+	if m.NGrams == nil || len(m.Grams) == 0 {
+		return nil
+	}
+	grams := make([]string, 0)
+	for _, token := range tokens {
+		grams = append(grams, m.NGrams.queryGrams(token)...)
+	}
+	weights := make([]termWeight, 0, len(grams))
+	qtf := make(map[string]int)
+	for _, gram := range grams {
+		if qtf[gram] == 0 {
+			weights = append(weights, termWeight{Term: gram})
+		}
+		qtf[gram]++
+	}
+	for i, w := range weights {
+		weights[i].Weight = m.NGrams.weight() * float64(qtf[w.Term]) * calculateIDF(m.gramDocFreq(w.Term), n, m.IDF)
+	}
+	return weights
+}
+
+// gramRank scores the n-grams of a document like its terms
+func (m *Model) gramRank(id string, weights []termWeight) float64 {
+	grams := m.Grams[id]
+	if len(grams) == 0 {
+		return 0
+	}
+	length := sumTF(grams)
+	var rank float64
+	for _, w := range weights {
+		rank += calculateTF(w.Term, grams, length) * w.Weight
+	}
+	return rank
+}
@@ -0,0 +1,75 @@
+package sego
+
+import "fmt"
+
+// ways to map ranks onto 0..1
+const (
+	// ranks as computed
+	NormalizeNone = "none"
+	// relative to the best result of the query
+	NormalizeMax = "max"
+	// relative to the best rank the query could possibly get, a document
+	// consisting of nothing but the query terms
+	NormalizeQuery = "query"
+)
+
+// queryNorm is the rank of an unboosted document made of exactly the terms of query
+func (m *Model) queryNorm(query string) float64 {
+	tokens := tokenize(parseQuery(query).Text, m.Lexer)
+	if len(tokens) == 0 {
+		return 0
+	}
+	doc := make(TermFreq)
+	for _, token := range tokens {
+		doc[token]++
+	}
+	var norm float64
+	for _, w := range m.queryWeights(tokens, len(m.docIDs())) {
+		norm += calculateTF(w.Term, doc, len(tokens)) * w.Weight
+	}
+	return norm
+}
+
+// normalizeRanks scales the ranks of the results of query in place
+func (m *Model) normalizeRanks(results SearchResults, query, method string) error {
+	var by float64
+	switch method {
+	case "", NormalizeNone:
+		return nil
+	case NormalizeMax:
+		if len(results) > 0 {
+			by = results[0].Rank
+		}
+	case NormalizeQuery:
+		by = m.queryNorm(query)
+	default:
+		return fmt.Errorf("unknown normalization %q, use none, max or query", method)
+	}
+
+	for i := range results {
+		if by <= 0 {
+			results[i].Rank = 0
+			continue
+		}
+		rank := results[i].Rank / by
+		// boosts can push documents past the norm
+		if rank > 1 {
+			rank = 1
+		}
+		if rank < 0 {
+			rank = 0
+		}
+		results[i].Rank = rank
+	}
+	return nil
+}
+
+// cutoff drops the results ranked below min, results must be sorted
+func (a SearchResults) cutoff(min float64) SearchResults {
+	for i, r := range a {
+		if r.Rank < min {
+			return a[:i]
+		}
+	}
+	return a
+}
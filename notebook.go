@@ -0,0 +1,82 @@
+package sego
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// notebookText is either a string or a list of lines in .ipynb files
+type notebookText []string
+
+func (t *notebookText) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*t = notebookText{s}
+		return nil
+	}
+	var lines []string
+	if err := json.Unmarshal(data, &lines); err != nil {
+		return err
+	}
+	*t = lines
+	return nil
+}
+
+func (t notebookText) String() string {
+	return strings.Join(t, "")
+}
+
+type notebookCell struct {
+	CellType string       `json:"cell_type"`
+	Source   notebookText `json:"source"`
+	// nbformat 3 calls the source of code cells input
+	Input   notebookText `json:"input"`
+	Outputs []struct {
+		Text notebookText `json:"text"`
+		Data struct {
+			Plain notebookText `json:"text/plain"`
+		} `json:"data"`
+	} `json:"outputs"`
+}
+
+type notebook struct {
+	Cells      []notebookCell `json:"cells"`
+	Worksheets []struct {
+		Cells []notebookCell `json:"cells"`
+	} `json:"worksheets"`
+}
+
+// extractNotebook keeps the markdown and code cells of a Jupyter notebook,
+// outputs only with config.NotebookOutputs
+func extractNotebook(content []byte, config *Config) ([]byte, error) {
+	var nb notebook
+	if err := json.Unmarshal(content, &nb); err != nil {
+		return nil, err
+	}
+
+	cells := nb.Cells
+	for _, ws := range nb.Worksheets {
+		cells = append(cells, ws.Cells...)
+	}
+
+	var text strings.Builder
+	for _, cell := range cells {
+		switch cell.CellType {
+		case "markdown", "code", "heading":
+		default:
+			continue
+		}
+		text.WriteString(cell.Source.String())
+		text.WriteString(cell.Input.String())
+		text.WriteString("\n\n")
+
+		if config.NotebookOutputs {
+			for _, output := range cell.Outputs {
+				text.WriteString(output.Text.String())
+				text.WriteString(output.Data.Plain.String())
+				text.WriteString("\n")
+			}
+		}
+	}
+	return []byte(text.String()), nil
+}
@@ -0,0 +1,132 @@
+package sego
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var imageExtensions = []string{".png", ".jpg", ".jpeg", ".tif", ".tiff", ".bmp", ".gif", ".webp"}
+
+func init() {
+	for _, ext := range imageExtensions {
+		extractors[ext] = extractImage
+	}
+	extractors[".pdf"] = extractPDF
+}
+
+func runTool(name string, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w: %s", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// cachedOCR returns the text of content from the cache or computes it with
+// ocr, so re-indexing never recognizes the same file twice
+func cachedOCR(content []byte, config *Config, ocr func(dir string) ([]byte, error)) ([]byte, error) {
+	hash := sha256.New()
+	hash.Write(content)
+	hash.Write([]byte(config.OCRLanguages))
+	cachePath := ""
+	if config.OCRCache != "" {
+		cachePath = filepath.Join(config.OCRCache, hex.EncodeToString(hash.Sum(nil))+".txt")
+		if text, err := os.ReadFile(cachePath); err == nil {
+			return text, nil
+		}
+	}
+
+	dir, err := os.MkdirTemp("", "sego-ocr")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	text, err := ocr(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if cachePath != "" {
+		if err := os.MkdirAll(config.OCRCache, 0777); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(cachePath, text, 0666); err != nil {
+			return nil, err
+		}
+	}
+	return text, nil
+}
+
+func tesseract(imagePath string, config *Config) ([]byte, error) {
+	return runTool(config.Tesseract, imagePath, "stdout", "-l", config.OCRLanguages)
+}
+
+// extractImage recognizes the text in an image with tesseract
+func extractImage(content []byte, config *Config) ([]byte, error) {
+	if !config.OCR {
+		return content, nil
+	}
+	return cachedOCR(content, config, func(dir string) ([]byte, error) {
+		imagePath := filepath.Join(dir, "image")
+		if err := os.WriteFile(imagePath, content, 0666); err != nil {
+			return nil, err
+		}
+		return tesseract(imagePath, config)
+	})
+}
+
+// extractPDF uses the text layer of a PDF and only falls back to OCR of the
+// rendered pages when there is none, as in scanned documents
+func extractPDF(content []byte, config *Config) ([]byte, error) {
+	if !config.OCR {
+		return content, nil
+	}
+	return cachedOCR(content, config, func(dir string) ([]byte, error) {
+		pdfPath := filepath.Join(dir, "doc.pdf")
+		if err := os.WriteFile(pdfPath, content, 0666); err != nil {
+			return nil, err
+		}
+
+		text, err := runTool(config.Pdftotext, "-layout", pdfPath, "-")
+		var execErr *exec.Error
+		if err != nil && !errors.As(err, &execErr) {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(text)) > 0 {
+			return text, nil
+		}
+
+		if _, err := runTool(config.Pdftoppm, "-r", "300", "-png", pdfPath, filepath.Join(dir, "page")); err != nil {
+			return nil, err
+		}
+		pages, err := filepath.Glob(filepath.Join(dir, "page*.png"))
+		if err != nil {
+			return nil, err
+		}
+		// page-1.png ... page-10.png, pdftoppm pads the numbers to the same width
+		sort.Strings(pages)
+
+		var result bytes.Buffer
+		for _, page := range pages {
+			text, err := tesseract(page, config)
+			if err != nil {
+				return nil, err
+			}
+			result.Write(text)
+			result.WriteString("\n")
+		}
+		return result.Bytes(), nil
+	})
+}
@@ -0,0 +1,170 @@
+package sego
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// lastResults are where the results of the last search can be found, so
+// `sego open 2` opens the second one
+type lastResults struct {
+	Index     string   `json:"index"`
+	Locations []string `json:"locations"`
+}
+
+func lastResultsPath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "sego", "last-results.json"), nil
+}
+
+// location is the URL of a result if it has one, else its absolute path if it
+// is a file here, else its path
+func (m *Model) location(r SearchResult) string {
+	if doc, ok := m.Docs[r.ID]; ok && doc.URL != "" {
+		return doc.URL
+	}
+	if u, err := url.Parse(r.Path); err == nil && u.Scheme != "" && len(u.Scheme) > 1 {
+		return r.Path
+	}
+	if abs, err := filepath.Abs(r.Path); err == nil {
+		if _, err := os.Stat(abs); err == nil {
+			return abs
+		}
+	}
+	// sections of local pages link to their anchor in the browser
+	if doc, ok := m.Docs[r.ID]; ok && doc.Parent != "" {
+		if abs, err := filepath.Abs(doc.Parent); err == nil {
+			if _, err := os.Stat(abs); err == nil {
+				if _, anchor, ok := strings.Cut(r.Path, "#"); ok && isWebPage(abs) {
+					return (&url.URL{Scheme: "file", Path: abs, Fragment: anchor}).String()
+				}
+				return abs
+			}
+		}
+	}
+	return r.Path
+}
+
+func (m *Model) saveLastResults(indexPath string, results SearchResults) error {
+	last := lastResults{Index: indexPath, Locations: make([]string, 0, len(results))}
+	for _, r := range results {
+		last.Locations = append(last.Locations, m.location(r))
+	}
+	file, err := lastResultsPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(last)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0644)
+}
+
+// command splits a command from an environment variable like $EDITOR, of
+// $BROWSER's colon separated list the first one is used
+func command(env string) []string {
+	value := os.Getenv(env)
+	if env == "BROWSER" {
+		value, _, _ = strings.Cut(value, ":")
+	}
+	return strings.Fields(value)
+}
+
+func systemOpener() []string {
+	switch runtime.GOOS {
+	case "darwin":
+		return []string{"open"}
+	case "windows":
+		return []string{"rundll32", "url.dll,FileProtocolHandler"}
+	}
+	return []string{"xdg-open"}
+}
+
+func isWebPage(file string) bool {
+	switch strings.ToLower(path.Ext(file)) {
+	case ".html", ".htm", ".xhtml":
+		return true
+	}
+	return false
+}
+
+// openLocation opens URLs and web pages in $BROWSER and other files in
+// $VISUAL or $EDITOR, falling back to the system's opener
+func openLocation(location string) error {
+	var cmd []string
+	if u, err := url.Parse(location); err == nil && (u.Scheme == "http" || u.Scheme == "https" || u.Scheme == "file") {
+		cmd = command("BROWSER")
+	} else if _, err := os.Stat(location); err != nil {
+		return fmt.Errorf("can't open %s, it is neither a URL nor a file here", location)
+	} else {
+		switch {
+		case isWebPage(location) || strings.EqualFold(path.Ext(location), ".pdf"):
+			cmd = command("BROWSER")
+		default:
+			if cmd = command("VISUAL"); len(cmd) == 0 {
+				cmd = command("EDITOR")
+			}
+		}
+	}
+	if len(cmd) == 0 {
+		cmd = systemOpener()
+	}
+
+	c := exec.Command(cmd[0], append(cmd[1:], location)...)
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return c.Run()
+}
+
+// openResult opens the nth (from 1) of locations
+func openResult(locations []string, n int) error {
+	if n < 1 || n > len(locations) {
+		return fmt.Errorf("no result %d, the last search had %d", n, len(locations))
+	}
+	log.Printf("Opening %s", locations[n-1])
+	return openLocation(locations[n-1])
+}
+
+func runOpen(args []string) {
+	fs := flag.NewFlagSet("open", flag.ExitOnError)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		log.Fatal("usage: sego open <result number>")
+	}
+	n, err := strconv.Atoi(fs.Arg(0))
+	if err != nil {
+		log.Fatalf("not a result number: %s", fs.Arg(0))
+	}
+
+	file, err := lastResultsPath()
+	if err != nil {
+		log.Fatal(err)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		log.Fatalf("no search to open a result of: %s", err)
+	}
+	var last lastResults
+	if err := json.Unmarshal(data, &last); err != nil {
+		log.Fatal(err)
+	}
+	if err := openResult(last.Locations, n); err != nil {
+		log.Fatal(err)
+	}
+}
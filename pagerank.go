@@ -0,0 +1,97 @@
+package sego
+
+import (
+	"math"
+	"sort"
+)
+
+const pageRankDamping = 0.85
+
+// setLinks records the links of page p, a link repeated with the same text
+// counts once
+func (m *Model) setLinks(p string, links []link) {
+	if m.Links == nil {
+		m.Links = make(map[string][]link)
+	}
+	seen := make(map[link]bool)
+	distinct := make([]link, 0, len(links))
+	for _, l := range links {
+		if !seen[l] {
+			seen[l] = true
+			distinct = append(distinct, l)
+		}
+	}
+	m.Links[p] = distinct
+	m.version++
+}
+
+// computePageRank scores the crawled pages by the links between them. Links
+// to pages that weren't crawled are ignored and pages without links spread
+// their rank over all pages. Scores are scaled so the best page has 1.
+func (m *Model) computePageRank() {
+	pages := make([]string, 0, len(m.Links))
+	for p := range m.Links {
+		pages = append(pages, p)
+	}
+	sort.Strings(pages)
+	if len(pages) == 0 {
+		m.PageRank = nil
+		return
+	}
+	index := make(map[string]int, len(pages))
+	for i, p := range pages {
+		index[p] = i
+	}
+	out := make([][]int, len(pages))
+	for i, p := range pages {
+		seen := make(map[int]bool)
+		for _, l := range m.Links[p] {
+			if j, ok := index[l.URL]; ok && j != i && !seen[j] {
+				seen[j] = true
+				out[i] = append(out[i], j)
+			}
+		}
+	}
+
+	n := float64(len(pages))
+	rank := make([]float64, len(pages))
+	for i := range rank {
+		rank[i] = 1 / n
+	}
+	next := make([]float64, len(pages))
+	for iteration := 0; iteration < 100; iteration++ {
+		dangling := 0.0
+		for i := range pages {
+			if len(out[i]) == 0 {
+				dangling += rank[i]
+			}
+		}
+		for i := range next {
+			next[i] = (1-pageRankDamping)/n + pageRankDamping*dangling/n
+		}
+		for i, targets := range out {
+			share := pageRankDamping * rank[i] / float64(len(targets))
+			for _, j := range targets {
+				next[j] += share
+			}
+		}
+
+		change := 0.0
+		for i := range rank {
+			change += math.Abs(next[i] - rank[i])
+		}
+		rank, next = next, rank
+		if change < 1e-9 {
+			break
+		}
+	}
+
+	best := 0.0
+	for _, r := range rank {
+		best = math.Max(best, r)
+	}
+	m.PageRank = make(map[string]float64, len(pages))
+	for i, p := range pages {
+		m.PageRank[p] = rank[i] / best
+	}
+}
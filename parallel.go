@@ -0,0 +1,186 @@
+package sego
+
+import (
+	"container/heap"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// fewer documents than this per goroutine aren't worth scoring concurrently
+const minShardDocs = 2048
+
+// queryThreads is how many goroutines to score with when asked for threads,
+// 0 meaning one per processor Go uses
+func queryThreads(threads int) int {
+	if threads <= 0 {
+		return runtime.GOMAXPROCS(0)
+	}
+	return threads
+}
+
+// scoreShards scores the documents of plan split into contiguous shards
+// concurrently, the results are in their order as if scored one by one
+func (m *Model) scoreShards(plan *searchPlan) SearchResults {
+	docs := plan.docs
+	shards := m.shardCount(len(docs))
+	if shards <= 1 {
+		return m.score(plan, 0, len(docs))
+	}
+
+	parts := make([]SearchResults, shards)
+	var wg sync.WaitGroup
+	for i := range parts {
+		lo, hi := i*len(docs)/shards, (i+1)*len(docs)/shards
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			parts[i] = m.score(plan, lo, hi)
+		}(i)
+	}
+	wg.Wait()
+
+	n := 0
+	for _, part := range parts {
+		n += len(part)
+	}
+	result := make(SearchResults, 0, n)
+	for _, part := range parts {
+		result = append(result, part...)
+	}
+	return result
+}
+
+// shardCount is how many shards to score n documents in
+func (m *Model) shardCount(n int) int {
+	shards := queryThreads(m.threads)
+	if most := n / minShardDocs; shards > most {
+		shards = most
+	}
+	if shards < 1 {
+		return 1
+	}
+	return shards
+}
+
+// resultCursor is the next result of a sorted list being merged
+type resultCursor struct {
+	results SearchResults
+	next    int
+}
+
+// resultHeap has the cursor with the best next result on top
+type resultHeap []*resultCursor
+
+func (h resultHeap) Len() int { return len(h) }
+func (h resultHeap) Less(i, j int) bool {
+	a, b := h[i].results[h[i].next], h[j].results[h[j].next]
+	return SearchResults{a, b}.Less(0, 1)
+}
+func (h resultHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *resultHeap) Push(x any)   { *h = append(*h, x.(*resultCursor)) }
+func (h *resultHeap) Pop() any {
+	old := *h
+	cursor := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return cursor
+}
+
+// mergeTop merges sorted lists into the k best of all of them, all if k is 0
+func mergeTop(lists []SearchResults, k int) SearchResults {
+	n := 0
+	for _, list := range lists {
+		n += len(list)
+	}
+	if k <= 0 || k > n {
+		k = n
+	}
+	merged := make(SearchResults, 0, k)
+	mergeEach(lists, func(r SearchResult) bool {
+		merged = append(merged, r)
+		return len(merged) < k
+	})
+	return merged
+}
+
+// mergeEach calls emit with the results of sorted lists best first until it
+// returns false
+func mergeEach(lists []SearchResults, emit func(SearchResult) bool) {
+	h := make(resultHeap, 0, len(lists))
+	for _, list := range lists {
+		if len(list) > 0 {
+			h = append(h, &resultCursor{results: list})
+		}
+	}
+	heap.Init(&h)
+	for len(h) > 0 {
+		cursor := h[0]
+		if !emit(cursor.results[cursor.next]) {
+			return
+		}
+		if cursor.next++; cursor.next < len(cursor.results) {
+			heap.Fix(&h, 0)
+		} else {
+			heap.Pop(&h)
+		}
+	}
+}
+
+// searchSegments searches segments on up to threads goroutines at once and
+// merges the k best results of each, all if k is 0. Results hidden in a
+// segment are left out before keeping its best. Returns how many matched in
+// all.
+func searchSegments(segments []*Model, query string, hidden func(i int, path string) bool, threads, k int) (SearchResults, int, *SearchTiming) {
+	lists := make([]SearchResults, len(segments))
+	timings := make([]*SearchTiming, len(segments))
+	totals := make([]int, len(segments))
+	sem := make(chan struct{}, queryThreads(threads))
+	var wg sync.WaitGroup
+	for i, seg := range segments {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, seg *Model) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			found, timing := seg.searchTimed(query)
+			kept := found[:0]
+			for _, r := range found {
+				if !hidden(i, r.Path) {
+					kept = append(kept, r)
+				}
+			}
+			totals[i] = len(kept)
+			if k > 0 && len(kept) > k {
+				kept = kept[:k]
+			}
+			lists[i], timings[i] = kept, timing
+		}(i, seg)
+	}
+	wg.Wait()
+
+	// segments are searched side by side, so each phase took as long as in
+	// the slowest one
+	timing := &SearchTiming{}
+	total := 0
+	for i, t := range timings {
+		total += totals[i]
+		if t.Tokenize > timing.Tokenize {
+			timing.Tokenize = t.Tokenize
+		}
+		if t.Candidates > timing.Candidates {
+			timing.Candidates = t.Candidates
+		}
+		if t.Score > timing.Score {
+			timing.Score = t.Score
+		}
+		if t.Sort > timing.Sort {
+			timing.Sort = t.Sort
+		}
+	}
+	start := time.Now()
+	merged := mergeTop(lists, k)
+	timing.Sort += time.Since(start)
+	return merged, total, timing
+}
@@ -0,0 +1,223 @@
+package sego
+
+import (
+	"sort"
+	"strings"
+)
+
+// phoneticLetters upper cases word, folds accents and transliterations
+// spelled differently in other languages ("Tschebyscheff") and drops all but
+// the letters A-Z
+func phoneticLetters(word string) []byte {
+	word = strings.ToUpper(asciiFold(word))
+	word = strings.ReplaceAll(word, "TSCH", "CH")
+	word = strings.ReplaceAll(word, "SCH", "SH")
+	letters := make([]byte, 0, len(word))
+	for i := 0; i < len(word); i++ {
+		if c := word[i]; c >= 'A' && c <= 'Z' {
+			letters = append(letters, c)
+		}
+	}
+	return letters
+}
+
+var soundexDigits = [26]byte{
+	// A    B    C    D    E    F    G    H    I    J    K    L    M
+	0, '1', '2', '3', 0, '1', '2', 0, 0, '2', '2', '4', '5',
+	// N    O    P    Q    R    S    T    U    V    W    X    Y    Z
+	'5', 0, '1', '2', '6', '2', '3', 0, '1', 0, '2', 0, '2',
+}
+
+// soundex codes word as its first letter and three digits for the consonants
+// after it, "Robert" and "Rupert" are both R163. Words without letters have
+// no code.
+func soundex(word string) string {
+	letters := phoneticLetters(word)
+	if len(letters) == 0 {
+		return ""
+	}
+	code := []byte{letters[0]}
+	last := soundexDigits[letters[0]-'A']
+	for _, c := range letters[1:] {
+		digit := soundexDigits[c-'A']
+		if digit != 0 && digit != last {
+			code = append(code, digit)
+			if len(code) == 4 {
+				break
+			}
+		}
+		// H and W don't separate consonants with the same digit, vowels do
+		if c != 'H' && c != 'W' {
+			last = digit
+		}
+	}
+	for len(code) < 4 {
+		code = append(code, '0')
+	}
+	return string(code)
+}
+
+func isVowelByte(c byte) bool {
+	return c == 'A' || c == 'E' || c == 'I' || c == 'O' || c == 'U'
+}
+
+// metaphone codes how word sounds in English following Lawrence Philips'
+// rules, "Chebyshev" and "Tschebyscheff" are both XBXF. 0 stands for "th".
+func metaphone(word string) string {
+	w := phoneticLetters(word)
+	if len(w) == 0 {
+		return ""
+	}
+	at := func(i int) byte {
+		if i < 0 || i >= len(w) {
+			return 0
+		}
+		return w[i]
+	}
+	follows := func(i int, s string) bool {
+		return strings.HasPrefix(string(w[i:]), s)
+	}
+
+	switch {
+	case follows(0, "AE"), follows(0, "GN"), follows(0, "KN"), follows(0, "PN"), follows(0, "WR"):
+		w = w[1:]
+	case w[0] == 'X':
+		w[0] = 'S'
+	case follows(0, "WH"):
+		w = append([]byte{'W'}, w[2:]...)
+	}
+
+	var code strings.Builder
+	for i, c := range w {
+		if c == at(i-1) && c != 'C' {
+			continue
+		}
+		next := at(i + 1)
+		switch c {
+		case 'A', 'E', 'I', 'O', 'U':
+			if i == 0 {
+				code.WriteByte(c)
+			}
+		case 'B':
+			if !(at(i-1) == 'M' && i == len(w)-1) {
+				code.WriteByte('B')
+			}
+		case 'C':
+			switch {
+			case follows(i, "CIA"), next == 'H' && at(i-1) != 'S':
+				code.WriteByte('X')
+			case next == 'I' || next == 'E' || next == 'Y':
+				if at(i-1) != 'S' {
+					code.WriteByte('S')
+				}
+			default:
+				code.WriteByte('K')
+			}
+		case 'D':
+			if next == 'G' && (at(i+2) == 'E' || at(i+2) == 'Y' || at(i+2) == 'I') {
+				code.WriteByte('J')
+			} else {
+				code.WriteByte('T')
+			}
+		case 'G':
+			switch {
+			case next == 'H' && i+2 < len(w) && !isVowelByte(at(i+2)):
+			case at(i-1) == 'D' && (next == 'E' || next == 'Y' || next == 'I'):
+			case next == 'N' && (i+2 == len(w) || follows(i+1, "NED") && i+4 == len(w)):
+			case (next == 'I' || next == 'E' || next == 'Y') && at(i-1) != 'G':
+				code.WriteByte('J')
+			default:
+				code.WriteByte('K')
+			}
+		case 'H':
+			prev := at(i - 1)
+			if isVowelByte(prev) && !isVowelByte(next) {
+				continue
+			}
+			if prev == 'C' || prev == 'S' || prev == 'P' || prev == 'T' || prev == 'G' {
+				continue
+			}
+			code.WriteByte('H')
+		case 'K':
+			if at(i-1) != 'C' {
+				code.WriteByte('K')
+			}
+		case 'P':
+			if next == 'H' {
+				code.WriteByte('F')
+			} else {
+				code.WriteByte('P')
+			}
+		case 'Q':
+			code.WriteByte('K')
+		case 'S':
+			if next == 'H' || follows(i, "SIO") || follows(i, "SIA") {
+				code.WriteByte('X')
+			} else {
+				code.WriteByte('S')
+			}
+		case 'T':
+			switch {
+			case follows(i, "TIA"), follows(i, "TIO"):
+				code.WriteByte('X')
+			case next == 'H':
+				code.WriteByte('0')
+			case follows(i, "TCH"):
+			default:
+				code.WriteByte('T')
+			}
+		case 'V':
+			code.WriteByte('F')
+		case 'W', 'Y':
+			if isVowelByte(next) {
+				code.WriteByte(c)
+			}
+		case 'X':
+			code.WriteString("KS")
+		case 'Z':
+			code.WriteByte('S')
+		default:
+			code.WriteByte(c)
+		}
+	}
+	return code.String()
+}
+
+// phoneticFilter replaces tokens by their code, tokens without letters like
+// numbers stay as they are
+func phoneticFilter(code func(string) string) TokenFilter {
+	return mapTokens(func(token string) string {
+		if c := code(token); c != "" {
+			return c
+		}
+		return token
+	})
+}
+
+// phoneticQuery adds the terms of the index that sound like those of query to
+// it, so names are found however they are spelled
+func (m *Model) phoneticQuery(query string) string {
+	inQuery := make(map[string]bool)
+	codes := make(map[string]bool)
+	for _, token := range tokenize(parseQuery(query).Text, m.Lexer) {
+		inQuery[token] = true
+		if code := metaphone(token); code != "" {
+			codes[code] = true
+		}
+	}
+	if len(codes) == 0 {
+		return query
+	}
+
+	alike := make([]string, 0)
+	for term := range m.DF {
+		if !inQuery[term] && codes[metaphone(term)] {
+			alike = append(alike, term)
+		}
+	}
+	if len(alike) == 0 {
+		return query
+	}
+	sort.Strings(alike)
+	return query + " " + strings.Join(alike, " ")
+}
@@ -0,0 +1,133 @@
+package sego
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+type Collocation struct {
+	Phrase string
+	Count  int
+	// pointwise mutual information, how much more often the words occur
+	// together than they would by chance
+	PMI float64
+}
+
+// ngramCounter counts the words and word sequences of a corpus
+type ngramCounter struct {
+	n      int
+	words  map[string]int
+	ngrams map[string]int
+	total  int
+}
+
+func isWordToken(token string) bool {
+	for _, r := range token {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// add counts the tokens of a document, sequences don't span punctuation
+func (c *ngramCounter) add(tokens []string) {
+	run := make([]string, 0)
+	for _, token := range tokens {
+		if !isWordToken(token) {
+			run = run[:0]
+			continue
+		}
+		c.words[token]++
+		c.total++
+		run = append(run, token)
+		if len(run) >= c.n {
+			c.ngrams[strings.Join(run[len(run)-c.n:], " ")]++
+		}
+	}
+}
+
+// collocations scores the sequences seen at least minCount times, highest PMI first
+func (c *ngramCounter) collocations(minCount int) []Collocation {
+	result := make([]Collocation, 0)
+	total := float64(c.total)
+	for ngram, count := range c.ngrams {
+		if count < minCount {
+			continue
+		}
+		pmi := math.Log(float64(count) / total)
+		for _, word := range strings.Fields(ngram) {
+			pmi -= math.Log(float64(c.words[word]) / total)
+		}
+		result = append(result, Collocation{Phrase: ngram, Count: count, PMI: pmi})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].PMI != result[j].PMI {
+			return result[i].PMI > result[j].PMI
+		}
+		return result[i].Phrase < result[j].Phrase
+	})
+	return result
+}
+
+func runPhrases(args []string) {
+	fs := flag.NewFlagSet("phrases", flag.ExitOnError)
+	model := newModel()
+	model.Lexer.registerFlags(fs)
+	config := newConfig()
+	config.registerFlags(fs)
+	n := fs.Int("words", 2, "phrase length, 2 for bigrams or 3 for trigrams")
+	minCount := fs.Int("min-count", 5, "leave out phrases seen fewer times, PMI is unreliable for rare ones")
+	top := fs.Int("n", 50, "number of phrases to show, 0 for all")
+	fs.Parse(args)
+	if fs.NArg() != 1 || *n < 2 {
+		log.Fatal("usage: sego phrases [flags] <dir|archive|url>")
+	}
+
+	source, err := openSource(fs.Arg(0), config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer closeSource(source)
+	names, _, err := source.List()
+	if err != nil {
+		log.Fatal(err)
+	}
+	model.setupAnalyzers(config)
+
+	counter := &ngramCounter{n: *n, words: make(map[string]int), ngrams: make(map[string]int)}
+	for _, name := range names {
+		r, err := source.Open(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		content, err := readLimit(r, config.MaxFileSize)
+		r.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+		content, _, err = extractText(name, content, config)
+		if err != nil || isBinary(content) {
+			continue
+		}
+		opts := model.Lexer
+		if analyzer, ok := model.Analyzers[config.analyzerName(name, content)]; ok {
+			opts = analyzer
+		}
+		counter.add(tokenize(string(content), opts))
+	}
+
+	collocations := counter.collocations(*minCount)
+	if *top > 0 && len(collocations) > *top {
+		collocations = collocations[:*top]
+	}
+	for _, c := range collocations {
+		fmt.Fprintf(os.Stdout, "%s\t%d\t%.3f\n", c.Phrase, c.Count, c.PMI)
+	}
+}
@@ -0,0 +1,106 @@
+package sego
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Pins map query patterns like "install*" to the paths of documents shown
+// first, in that order, for queries matching them
+type Pins map[string][]string
+
+func loadPins(file string) (Pins, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var pins Pins
+	if err := json.Unmarshal(data, &pins); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	normalized := make(Pins, len(pins))
+	for pattern, paths := range pins {
+		pattern = normalizeQueryText(pattern)
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("%s: pattern %q: %w", file, pattern, err)
+		}
+		normalized[pattern] = append(normalized[pattern], paths...)
+	}
+	return normalized, nil
+}
+
+// normalizeQueryText lower cases query and collapses its whitespace, so pins
+// match however the query was typed
+func normalizeQueryText(query string) string {
+	return strings.Join(strings.Fields(strings.ToLower(query)), " ")
+}
+
+// match returns the paths pinned for query, those of an exact pattern before
+// those of wildcard ones in pattern order
+func (p Pins) match(query string) []string {
+	query = normalizeQueryText(query)
+	patterns := make([]string, 0)
+	for pattern := range p {
+		if ok, _ := path.Match(pattern, query); ok && pattern != query {
+			patterns = append(patterns, pattern)
+		}
+	}
+	sort.Strings(patterns)
+	if _, ok := p[query]; ok {
+		patterns = append([]string{query}, patterns...)
+	}
+
+	paths := make([]string, 0)
+	for _, pattern := range patterns {
+		paths = append(paths, p[pattern]...)
+	}
+	return paths
+}
+
+// pin moves the documents at paths to the top of results flagged as pinned,
+// even when the query didn't match them. Paths not indexed are left out.
+func (m *Model) pin(results SearchResults, paths []string) SearchResults {
+	if len(paths) == 0 {
+		return results
+	}
+	now := time.Now()
+	pinned := make(SearchResults, 0, len(paths))
+	seen := make(map[string]bool)
+	for _, p := range paths {
+		id, ok := m.docID(p)
+		if !ok || seen[p] || m.Deleted[id] {
+			continue
+		}
+		doc, hasDoc := m.Docs[id]
+		if hasDoc && doc.expired(now) {
+			continue
+		}
+		seen[p] = true
+		r := SearchResult{ID: id, Path: p, Pinned: true}
+		if hasDoc {
+			r.Title = doc.Title
+			r.Description = doc.Description
+		}
+		pinned = append(pinned, r)
+	}
+
+	rest := make(SearchResults, 0, len(results))
+	for _, r := range results {
+		if seen[r.Path] {
+			for i := range pinned {
+				if pinned[i].Path == r.Path {
+					pinned[i].Rank = r.Rank
+					pinned[i].More = r.More
+				}
+			}
+			continue
+		}
+		rest = append(rest, r)
+	}
+	return append(pinned, rest...)
+}
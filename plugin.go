@@ -0,0 +1,153 @@
+package sego
+
+import (
+	"fmt"
+	"math"
+	"plugin"
+	"time"
+)
+
+// TokenFilter rewrites the (uppercased) tokens of a text, it may drop, change
+// or add tokens
+type TokenFilter func(tokens []string) []string
+
+// filters provided by plugins, by name
+var tokenFilters = make(map[string]TokenFilter)
+
+// loadPlugin opens a Go plugin built with -buildmode=plugin that exports
+//
+//	var Filters = map[string]func(tokens []string) []string{...}
+//
+// and registers its filters so analyzers can name them, or quality signals
+// scoring a document 0..1 by its path, length in tokens and date
+//
+//	var Signals = map[string]func(path string, length int, date time.Time) float64{...}
+func loadPlugin(path string) error {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return err
+	}
+	filtersSymbol, err := p.Lookup("Filters")
+	signalsSymbol, signalsErr := p.Lookup("Signals")
+	if err != nil && signalsErr != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if err == nil {
+		filters, ok := filtersSymbol.(*map[string]func([]string) []string)
+		if !ok {
+			return fmt.Errorf("%s: Filters is %T, not map[string]func([]string) []string", path, filtersSymbol)
+		}
+		for name, filter := range *filters {
+			tokenFilters[name] = filter
+		}
+	}
+	if signalsErr == nil {
+		signals, ok := signalsSymbol.(*map[string]func(string, int, time.Time) float64)
+		if !ok {
+			return fmt.Errorf("%s: Signals is %T, not map[string]func(string, int, time.Time) float64", path, signalsSymbol)
+		}
+		for name, signal := range *signals {
+			qualitySignals[name] = pluginSignal(signal)
+		}
+	}
+	return nil
+}
+
+// pluginSignal adapts a signal of a plugin, keeping its scores within 0..1
+func pluginSignal(signal func(string, int, time.Time) float64) qualitySignal {
+	return func(doc *Document, opts *QualityOptions, now time.Time) float64 {
+		date, _ := doc.date()
+		return math.Max(0, math.Min(1, signal(doc.Path, doc.Length, date)))
+	}
+}
+
+// loadPlugins loads the plugins the index was built with and checks that
+// every filter its analyzers use exists
+func (m *Model) loadPlugins() error {
+	for _, path := range m.Plugins {
+		if err := loadPlugin(path); err != nil {
+			return err
+		}
+	}
+	if err := m.Lexer.checkFilters(); err != nil {
+		return err
+	}
+	if m.Quality != nil {
+		if err := m.Quality.check(); err != nil {
+			return err
+		}
+	}
+	for name, opts := range m.Analyzers {
+		if err := opts.checkFilters(); err != nil {
+			return fmt.Errorf("analyzer %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (o *LexerOptions) checkFilters() error {
+	if err := checkLang(o.Lang); err != nil {
+		return err
+	}
+	if o.Dictionary != "" {
+		if _, err := loadDictionary(o.Dictionary); err != nil {
+			return err
+		}
+	}
+	for _, name := range o.langFilters() {
+		if _, err := lookupFilter(name); err != nil {
+			return err
+		}
+	}
+	for typ, filters := range o.TypeFilters {
+		if _, err := parseTokenType(string(typ)); err != nil {
+			return err
+		}
+		for _, name := range filters {
+			if _, err := lookupFilter(name); err != nil {
+				return fmt.Errorf("%s: %w", typ, err)
+			}
+		}
+	}
+	return nil
+}
+
+// applyFilters runs tokens through the filters in order, they were checked
+// when the analyzer was set up
+func applyFilters(tokens []string, names []string) []string {
+	for _, name := range names {
+		if filter, err := lookupFilter(name); err == nil {
+			tokens = filter(tokens)
+		}
+	}
+	return tokens
+}
+
+// filterTokens runs all but the keywords through the filters, keywords are
+// kept exactly as they are
+func (o LexerOptions) filterTokens(tokens []string, caser func(string) string) []string {
+	filters := o.langFilters()
+	if len(o.Keywords) == 0 || len(filters) == 0 {
+		return applyFilters(tokens, filters)
+	}
+	keywords := o.keywordSet(caser)
+	result := make([]string, 0, len(tokens))
+	start := 0
+	for i, token := range tokens {
+		if keywords[token] {
+			// capped so filters adding tokens can't overwrite the keyword
+			result = append(result, applyFilters(tokens[start:i:i], filters)...)
+			result = append(result, token)
+			start = i + 1
+		}
+	}
+	return append(result, applyFilters(tokens[start:], filters)...)
+}
+
+func (o LexerOptions) keywordSet(caser func(string) string) map[string]bool {
+	keywords := make(map[string]bool, len(o.Keywords))
+	for _, keyword := range o.Keywords {
+		keywords[caser(keyword)] = true
+	}
+	return keywords
+}
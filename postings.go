@@ -0,0 +1,436 @@
+package sego
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// The term tables of documents are saved inverted, as a posting list per term
+// of the documents containing it and how often. Documents are numbered by
+// their IDs in order and each posting is the difference to the document
+// before and the count, as varints. Postings come in blocks of
+// postingBlockSize, each headed by its last document and its length in bytes,
+// so going through a list can skip the blocks before a document without
+// decoding them. The lists are base64 encoded after the document IDs and are
+// keyed by front coded terms like the term dictionary. Indexes saved before
+// have plain JSON objects and are read as well.
+
+const postingBlockSize = 128
+
+var errCorruptPostings = errors.New("corrupt posting lists")
+
+// appendPostings encodes docs, in ascending order, and their counts as a
+// posting list
+func appendPostings(buf []byte, docs []int, counts []int) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(docs)))
+	last := -1
+	body := make([]byte, 0, 2*postingBlockSize)
+	for start := 0; start < len(docs); start += postingBlockSize {
+		end := start + postingBlockSize
+		if end > len(docs) {
+			end = len(docs)
+		}
+		body = body[:0]
+		prev := last
+		for i := start; i < end; i++ {
+			body = binary.AppendUvarint(body, uint64(docs[i]-prev))
+			body = binary.AppendUvarint(body, uint64(counts[i]))
+			prev = docs[i]
+		}
+		buf = binary.AppendUvarint(buf, uint64(docs[end-1]-last))
+		buf = binary.AppendUvarint(buf, uint64(len(body)))
+		buf = append(buf, body...)
+		last = docs[end-1]
+	}
+	return buf
+}
+
+// postingIterator goes through an encoded posting list
+type postingIterator struct {
+	// blocks not started yet
+	data []byte
+	// rest of the current block
+	block []byte
+	// postings in the list
+	n int
+	// current document and count, doc is -1 before the first
+	doc   int
+	count int
+	// last document of the current block
+	blockLast int
+	err       error
+}
+
+// newPostingIterator reads the length of the list at the start of data and
+// returns the rest of data after the list
+func newPostingIterator(data []byte) (*postingIterator, []byte, error) {
+	n, k := binary.Uvarint(data)
+	// every posting takes two bytes at least
+	if k <= 0 || n > uint64(len(data)) {
+		return nil, nil, errCorruptPostings
+	}
+	it := &postingIterator{n: int(n), doc: -1, blockLast: -1}
+	rest := data[k:]
+	size := 0
+	for i := 0; i < int(n); i += postingBlockSize {
+		_, k1 := binary.Uvarint(rest[size:])
+		if k1 <= 0 {
+			return nil, nil, errCorruptPostings
+		}
+		length, k2 := binary.Uvarint(rest[size+k1:])
+		if k2 <= 0 || length > uint64(len(rest)-size-k1-k2) {
+			return nil, nil, errCorruptPostings
+		}
+		size += k1 + k2 + int(length)
+	}
+	it.data = rest[:size]
+	return it, rest[size:], nil
+}
+
+// maxPostingDoc bounds document numbers so adding deltas can't overflow
+const maxPostingDoc = 1 << 31
+
+// nextBlock starts the next block, or returns false at the end of the list
+func (it *postingIterator) nextBlock() bool {
+	if len(it.data) == 0 || it.err != nil {
+		return false
+	}
+	delta, k1 := binary.Uvarint(it.data)
+	length, k2 := binary.Uvarint(it.data[k1:])
+	// documents ascend, every block ends after the one before
+	if delta == 0 || delta > uint64(maxPostingDoc-it.blockLast) {
+		it.err = errCorruptPostings
+		return false
+	}
+	it.doc = it.blockLast
+	it.blockLast += int(delta)
+	it.block = it.data[k1+k2 : k1+k2+int(length)]
+	it.data = it.data[k1+k2+int(length):]
+	return true
+}
+
+// next moves to the next posting, or returns false at the end of the list
+func (it *postingIterator) next() bool {
+	if len(it.block) == 0 && !it.nextBlock() {
+		return false
+	}
+	delta, k1 := binary.Uvarint(it.block)
+	if k1 <= 0 {
+		it.err = errCorruptPostings
+		return false
+	}
+	count, k2 := binary.Uvarint(it.block[k1:])
+	if k2 <= 0 || delta == 0 || delta > uint64(it.blockLast-it.doc) || count > maxPostingDoc {
+		it.err = errCorruptPostings
+		return false
+	}
+	it.block = it.block[k1+k2:]
+	it.doc += int(delta)
+	it.count = int(count)
+	return true
+}
+
+// advance moves to the first posting of a document at or after target,
+// skipping whole blocks that end before it
+func (it *postingIterator) advance(target int) bool {
+	if it.doc >= target {
+		return true
+	}
+	for it.blockLast < target {
+		it.block = nil
+		if !it.nextBlock() {
+			return false
+		}
+	}
+	for it.next() {
+		if it.doc >= target {
+			return true
+		}
+	}
+	return false
+}
+
+func (t TermFreqTable) MarshalJSON() ([]byte, error) {
+	if t == nil {
+		return []byte("null"), nil
+	}
+	index := invertTermFreqs(t)
+	buf := make([]byte, 0, 1024)
+	buf = binary.AppendUvarint(buf, uint64(len(index.docs)))
+	for _, id := range index.docs {
+		buf = binary.AppendUvarint(buf, uint64(len(id)))
+		buf = append(buf, id...)
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(index.terms)))
+	prev := ""
+	for i, term := range index.terms {
+		shared := 0
+		if i%termBlockSize != 0 {
+			shared = commonPrefix(prev, term)
+		}
+		buf = binary.AppendUvarint(buf, uint64(shared))
+		buf = binary.AppendUvarint(buf, uint64(len(term)-shared))
+		buf = append(buf, term[shared:]...)
+		buf = append(buf, index.lists[term]...)
+		prev = term
+	}
+	return json.Marshal(base64.StdEncoding.EncodeToString(buf))
+}
+
+func (t *TermFreqTable) UnmarshalJSON(data []byte) error {
+	table, _, err := decodeTermFreqs(data)
+	if err != nil {
+		return err
+	}
+	*t = table
+	return nil
+}
+
+// decodeTermFreqs reads term tables saved as posting lists, or as plain JSON
+// by indexes saved before, and also returns the posting lists as read so
+// queries can go through them, nil for plain JSON
+func decodeTermFreqs(data []byte) (TermFreqTable, *postingIndex, error) {
+	if len(data) == 0 || data[0] != '"' {
+		var plain map[string]TermFreq
+		if err := json.Unmarshal(data, &plain); err != nil {
+			return nil, nil, err
+		}
+		return plain, nil, nil
+	}
+	var encoded string
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return nil, nil, err
+	}
+	buf, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	uvarint := func() (int, error) {
+		v, n := binary.Uvarint(buf)
+		if n <= 0 || v > 1<<31 {
+			return 0, errCorruptPostings
+		}
+		buf = buf[n:]
+		return int(v), nil
+	}
+	n, err := uvarint()
+	if err != nil || n > len(buf) {
+		return nil, nil, errCorruptPostings
+	}
+	table := make(TermFreqTable, n)
+	docs := make([]TermFreq, n)
+	index := &postingIndex{docs: make([]string, n), lists: make(map[string][]byte)}
+	for i := range docs {
+		length, err := uvarint()
+		if err != nil || length > len(buf) {
+			return nil, nil, errCorruptPostings
+		}
+		docs[i] = make(TermFreq)
+		index.docs[i] = string(buf[:length])
+		table[index.docs[i]] = docs[i]
+		buf = buf[length:]
+	}
+	terms, err := uvarint()
+	if err != nil || terms > len(buf) {
+		return nil, nil, errCorruptPostings
+	}
+	index.terms = make([]string, 0, terms)
+	term := make([]byte, 0, 32)
+	for i := 0; i < terms; i++ {
+		shared, err := uvarint()
+		if err != nil || shared > len(term) {
+			return nil, nil, errCorruptPostings
+		}
+		length, err := uvarint()
+		if err != nil || length > len(buf) {
+			return nil, nil, errCorruptPostings
+		}
+		term = append(term[:shared], buf[:length]...)
+		buf = buf[length:]
+		key := string(term)
+		index.terms = append(index.terms, key)
+		it, rest, err := newPostingIterator(buf)
+		if err != nil {
+			return nil, nil, err
+		}
+		index.lists[key] = buf[:len(buf)-len(rest)]
+		buf = rest
+		for it.next() {
+			if it.doc < 0 || it.doc >= len(docs) {
+				return nil, nil, errCorruptPostings
+			}
+			docs[it.doc][key] = it.count
+		}
+		if it.err != nil {
+			return nil, nil, it.err
+		}
+	}
+	return table, index, nil
+}
+
+// UnmarshalJSON keeps the posting lists the term tables are read from, so
+// queries requiring terms go through them
+func (m *Model) UnmarshalJSON(data []byte) error {
+	type model Model
+	aux := struct {
+		*model
+		TF json.RawMessage `json:"tf"`
+	}{model: (*model)(m)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if len(aux.TF) == 0 {
+		return nil
+	}
+	table, index, err := decodeTermFreqs(aux.TF)
+	if err != nil {
+		return err
+	}
+	m.TF = table
+	if index != nil {
+		m.postings = &postingCache{index: index}
+	}
+	return nil
+}
+
+// postingIndex is the term tables of a model inverted, documents are numbered
+// by their position in docs
+type postingIndex struct {
+	docs  []string
+	terms []string
+	lists map[string][]byte
+}
+
+func invertTermFreqs(t TermFreqTable) *postingIndex {
+	index := &postingIndex{docs: make([]string, 0, len(t)), lists: make(map[string][]byte)}
+	for id := range t {
+		index.docs = append(index.docs, id)
+	}
+	sort.Strings(index.docs)
+
+	docs := make(map[string][]int)
+	counts := make(map[string][]int)
+	for i, id := range index.docs {
+		for term, n := range t[id] {
+			docs[term] = append(docs[term], i)
+			counts[term] = append(counts[term], n)
+		}
+	}
+	index.terms = make([]string, 0, len(docs))
+	for term := range docs {
+		index.terms = append(index.terms, term)
+	}
+	sort.Strings(index.terms)
+	for _, term := range index.terms {
+		index.lists[term] = appendPostings(nil, docs[term], counts[term])
+	}
+	return index
+}
+
+// postingCache is the posting index of a loaded model, as read or, for
+// indexes saved before posting lists, built when a query first requires terms
+type postingCache struct {
+	once sync.Once
+	// version of the model it was loaded as, once changed it is out of date
+	version uint64
+	index   *postingIndex
+}
+
+// requireTerms narrows allowed, a bitmap over docs or nil for all of them, to
+// the documents containing every required word. A word is analyzed like
+// queries are and found if a document has all the terms of one of the
+// languages it is analyzed as.
+func (m *Model) requireTerms(docs []string, required []string, filters []Filter, allowed bitmap) bitmap {
+	for _, word := range required {
+		var found bitmap
+		for _, terms := range m.queryVariants(word, filters) {
+			if len(terms) == 0 {
+				continue
+			}
+			matches := m.containingAll(docs, terms, allowed)
+			if found == nil {
+				found = matches
+			} else {
+				found.or(matches)
+			}
+		}
+		if found != nil {
+			allowed = found
+		}
+	}
+	return allowed
+}
+
+// containingAll narrows allowed, a bitmap over docs or nil for all of them, to
+// the documents containing all of terms by intersecting their posting lists,
+// shortest first
+func (m *Model) containingAll(docs []string, terms []string, allowed bitmap) bitmap {
+
+	matches := newBitmap(len(docs))
+	if m.postings == nil || m.postings.version != m.version {
+		for i, id := range docs {
+			if allowed != nil && !allowed.has(i) {
+				continue
+			}
+			all := true
+			for _, term := range terms {
+				if m.TF[id][term] == 0 {
+					all = false
+					break
+				}
+			}
+			if all {
+				matches.set(i)
+			}
+		}
+		return matches
+	}
+
+	m.postings.once.Do(func() {
+		if m.postings.index == nil {
+			m.postings.index = invertTermFreqs(m.TF)
+		}
+	})
+	index := m.postings.index
+	its := make([]*postingIterator, 0, len(terms))
+	for _, term := range terms {
+		list, ok := index.lists[term]
+		if !ok {
+			return matches
+		}
+		it, _, err := newPostingIterator(list)
+		if err != nil {
+			return matches
+		}
+		its = append(its, it)
+	}
+	sort.Slice(its, func(i, j int) bool { return its[i].n < its[j].n })
+
+	lead, rest := its[0], its[1:]
+	for lead.next() {
+		doc := lead.doc
+		all := true
+		for _, it := range rest {
+			if !it.advance(doc) {
+				return matches
+			}
+			if it.doc != doc {
+				all = false
+				break
+			}
+		}
+		if !all {
+			continue
+		}
+		id := index.docs[doc]
+		if i := sort.SearchStrings(docs, id); i < len(docs) && docs[i] == id && (allowed == nil || allowed.has(i)) {
+			matches.set(i)
+		}
+	}
+	return matches
+}
@@ -0,0 +1,165 @@
+package sego
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestTermFreqTableRoundTrip(t *testing.T) {
+	big := make(TermFreqTable)
+	for i := 0; i < 3*postingBlockSize+7; i++ {
+		tf := TermFreq{"COMMON": i%5 + 1}
+		if i%3 == 0 {
+			tf["THIRD"] = 2
+		}
+		big[fmt.Sprintf("doc%04d", i)] = tf
+	}
+	tables := []TermFreqTable{
+		{},
+		{"1": {"A": 1}},
+		{"1": {"SHADER": 3, "SHADOW": 1}, "2": {"SHADER": 1}, "3": {}},
+		big,
+	}
+	for _, table := range tables {
+		data, err := json.Marshal(table)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got TermFreqTable
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, table) {
+			t.Errorf("round trip of %d documents differs", len(table))
+		}
+	}
+}
+
+func TestTermFreqTablePlainJSON(t *testing.T) {
+	var got TermFreqTable
+	if err := json.Unmarshal([]byte(`{"1": {"A": 2}}`), &got); err != nil {
+		t.Fatal(err)
+	}
+	if want := (TermFreqTable{"1": {"A": 2}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// postingPayload encodes one document "a" and the term "x" with a posting
+// list of one block, its last document and the delta and count in it
+func postingPayload(blockDelta, delta, count uint64) []byte {
+	var buf []byte
+	for _, v := range []uint64{1, 1} {
+		buf = binary.AppendUvarint(buf, v)
+	}
+	buf = append(buf, 'a')
+	for _, v := range []uint64{1, 0, 1} {
+		buf = binary.AppendUvarint(buf, v)
+	}
+	buf = append(buf, 'x')
+	body := binary.AppendUvarint(nil, delta)
+	body = binary.AppendUvarint(body, count)
+	buf = binary.AppendUvarint(buf, 1)
+	buf = binary.AppendUvarint(buf, blockDelta)
+	buf = binary.AppendUvarint(buf, uint64(len(body)))
+	return append(buf, body...)
+}
+
+func TestTermFreqTableCorrupt(t *testing.T) {
+	valid := postingPayload(1, 1, 4)
+	var table TermFreqTable
+	data, _ := json.Marshal(base64.StdEncoding.EncodeToString(valid))
+	if err := json.Unmarshal(data, &table); err != nil {
+		t.Fatalf("valid payload: %s", err)
+	}
+	if table["a"]["x"] != 4 {
+		t.Fatalf("valid payload read as %v", table)
+	}
+
+	payloads := map[string][]byte{
+		"truncated":          valid[:len(valid)-1],
+		"overflowing block":  postingPayload(1<<63, 1<<63, 1),
+		"negative document":  postingPayload(1<<64-1, 1<<64-1, 1),
+		"document past end":  postingPayload(5, 5, 1),
+		"beyond block":       postingPayload(1, 2, 1),
+		"zero delta":         postingPayload(1, 0, 1),
+		"more postings":      append(postingPayload(1, 1, 1)[:7], 0xff, 0xff, 0xff, 0x0f),
+		"too many documents": {0xff, 0xff, 0xff, 0xff, 0x0f},
+	}
+	for name, payload := range payloads {
+		data, _ := json.Marshal(base64.StdEncoding.EncodeToString(payload))
+		var table TermFreqTable
+		if err := json.Unmarshal(data, &table); err == nil {
+			t.Errorf("%s: read as %v", name, table)
+		}
+	}
+}
+
+func TestRequireTermsFromPostings(t *testing.T) {
+	m := newModel()
+	config := newConfig()
+	for path, content := range map[string]string{
+		"a.txt": "vertex shader uniforms",
+		"b.txt": "fragment shader",
+		"c.txt": "vertex buffer",
+	} {
+		if err := m.apply(&IngestMessage{Path: path, Content: content}, config); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := parseModel(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.postings.index == nil {
+		t.Fatal("posting lists weren't kept")
+	}
+
+	docs := loaded.docIDs()
+	matches := loaded.requireTerms(docs, []string{"vertex", "shader"}, nil, nil)
+	got := make([]string, 0)
+	for i, id := range docs {
+		if matches.has(i) {
+			got = append(got, loaded.docPath(id))
+		}
+	}
+	if want := []string{"a.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestRequireTermsAutoLanguage(t *testing.T) {
+	config := newConfig()
+	m := newModel()
+	m.Lexer.Lang = LangAuto
+	content := "The knights were running through the forest while the dragons were sleeping in their caves"
+	if err := m.apply(&IngestMessage{Path: "a.txt", Content: content}, config); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Languages) == 0 {
+		t.Fatal("no language detected")
+	}
+	if results, _ := m.searchTimed("+running"); len(results) != 1 {
+		t.Errorf("+running found %d documents", len(results))
+	}
+	for _, query := range []Node{
+		&TermNode{Term: "running"},
+		&PhraseNode{Terms: []string{"running", "dragons"}},
+	} {
+		results, err := m.SearchAST(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 {
+			t.Errorf("%#v found %d documents", query, len(results))
+		}
+	}
+}
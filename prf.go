@@ -0,0 +1,47 @@
+package sego
+
+import (
+	"sort"
+	"strings"
+)
+
+// expandQuery adds the terms weighing most in the top docs results of a first
+// search to query, assuming those documents are relevant. Terms in just one
+// document or already in the query are left out.
+func (m *Model) expandQuery(query string, results SearchResults, docs, terms int) string {
+	inQuery := make(map[string]bool)
+	for _, token := range tokenize(parseQuery(query).Text, m.Lexer) {
+		inQuery[token] = true
+	}
+
+	n := len(m.docIDs())
+	weight := make(map[string]float64)
+	for i, r := range results {
+		if i >= docs || r.Rank <= 0 {
+			break
+		}
+		for term, x := range m.tfidfVector(r.ID, n) {
+			if !inQuery[term] && m.DF[term] > 1 {
+				weight[term] += x
+			}
+		}
+	}
+
+	candidates := make([]string, 0, len(weight))
+	for term := range weight {
+		candidates = append(candidates, term)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if weight[candidates[i]] != weight[candidates[j]] {
+			return weight[candidates[i]] > weight[candidates[j]]
+		}
+		return candidates[i] < candidates[j]
+	})
+	if len(candidates) > terms {
+		candidates = candidates[:terms]
+	}
+	if len(candidates) == 0 {
+		return query
+	}
+	return query + " " + strings.Join(candidates, " ")
+}
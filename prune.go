@@ -0,0 +1,155 @@
+package sego
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"sort"
+)
+
+type PruneOptions struct {
+	// drop terms in a single document, mostly typos, ids and numbers
+	Hapaxes bool
+	// drop terms in more than this share of the documents, 0 keeps them
+	MaxDF float64
+	// keep only the terms weighing most in every document, 0 keeps all
+	MaxTermsPerDoc int
+}
+
+// PruneReport says what prune dropped
+type PruneReport struct {
+	Hapaxes []string `json:"hapaxes"`
+	Common  []string `json:"common"`
+	// document ID => how many of its terms were over MaxTermsPerDoc
+	Capped   map[string]int `json:"capped"`
+	Postings int            `json:"postings"`
+}
+
+// prune shrinks the index by dropping terms that do little for ranking.
+// Document lengths stay the same so pruned documents aren't taken for short ones.
+func (m *Model) prune(opts PruneOptions) *PruneReport {
+	report := &PruneReport{
+		Hapaxes: make([]string, 0),
+		Common:  make([]string, 0),
+		Capped:  make(map[string]int),
+	}
+	n := len(m.docIDs())
+	drop := make(map[string]bool)
+	for term, df := range m.DF {
+		switch {
+		case df <= 0:
+		case opts.Hapaxes && df == 1:
+			report.Hapaxes = append(report.Hapaxes, term)
+			drop[term] = true
+		case opts.MaxDF > 0 && float64(df) > opts.MaxDF*float64(n):
+			report.Common = append(report.Common, term)
+			drop[term] = true
+		}
+	}
+	sort.Strings(report.Hapaxes)
+	sort.Strings(report.Common)
+
+	for id, tf := range m.TF {
+		for term := range tf {
+			if drop[term] {
+				m.dropPosting(id, term)
+				report.Postings++
+			}
+		}
+		if opts.MaxTermsPerDoc <= 0 || len(tf) <= opts.MaxTermsPerDoc {
+			continue
+		}
+
+		length := m.docLength(id)
+		weight := make(map[string]float64, len(tf))
+		terms := make([]string, 0, len(tf))
+		for term := range tf {
+			weight[term] = calculateTF(term, tf, length) * calculateIDF(m.DF[term], n, IDFSmooth)
+			terms = append(terms, term)
+		}
+		sort.Slice(terms, func(i, j int) bool {
+			if weight[terms[i]] != weight[terms[j]] {
+				return weight[terms[i]] > weight[terms[j]]
+			}
+			return terms[i] < terms[j]
+		})
+		for _, term := range terms[opts.MaxTermsPerDoc:] {
+			m.dropPosting(id, term)
+			report.Postings++
+		}
+		report.Capped[id] = len(terms) - opts.MaxTermsPerDoc
+	}
+	if report.Postings > 0 {
+		m.Pruned = true
+	}
+	m.version++
+	return report
+}
+
+// dropPosting removes a term from a document, keeping its length
+func (m *Model) dropPosting(id, term string) {
+	doc, ok := m.Docs[id]
+	if !ok {
+		doc = &Document{Path: m.docPath(id)}
+		m.Docs[id] = doc
+	}
+	if doc.Length == 0 {
+		doc.Length = sumTF(m.TF[id])
+	}
+	delete(m.TF[id], term)
+	m.DF[term]--
+	if m.DF[term] <= 0 {
+		delete(m.DF, term)
+	}
+}
+
+func runPrune(args []string) {
+	fs := flag.NewFlagSet("prune", flag.ExitOnError)
+	var opts PruneOptions
+	fs.BoolVar(&opts.Hapaxes, "hapaxes", false, "drop terms that occur in a single document")
+	fs.Float64Var(&opts.MaxDF, "max-df", 0, "drop terms in more than this share of the documents, e.g. 0.5")
+	fs.IntVar(&opts.MaxTermsPerDoc, "max-terms-per-doc", 0, "keep only this many of the highest weighted terms per document")
+	reportPath := fs.String("report", "", "write the pruned terms to this JSON file")
+	dryRun := fs.Bool("dry-run", false, "only report what would be pruned")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		log.Fatal("usage: sego prune [flags] <index>")
+	}
+
+	model, err := newModelFromJson(fs.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	before, err := json.Marshal(model)
+	if err != nil {
+		log.Fatal(err)
+	}
+	report := model.prune(opts)
+	after, err := json.Marshal(model)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("Pruned %d hapaxes, %d common terms and %d documents' rarest terms, %d postings in all, %s => %s",
+		len(report.Hapaxes), len(report.Common), len(report.Capped), report.Postings,
+		formatSize(int64(len(before))), formatSize(int64(len(after))))
+	if len(report.Common) > 0 {
+		log.Printf("Common terms: %v", report.Common)
+	}
+	if *reportPath != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := os.WriteFile(*reportPath, data, 0666); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *dryRun {
+		return
+	}
+	if err := model.saveAsJson(fs.Arg(0)); err != nil {
+		log.Fatal(err)
+	}
+}
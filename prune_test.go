@@ -0,0 +1,39 @@
+package sego
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestPruneThenCheck(t *testing.T) {
+	config := newConfig()
+	m := newModel()
+	for path, content := range map[string]string{
+		"a.txt": "shader shader vertex typo",
+		"b.txt": "shader fragment",
+	} {
+		if err := m.apply(&IngestMessage{Path: path, Content: content}, config); err != nil {
+			t.Fatal(err)
+		}
+	}
+	id, _ := m.docID("a.txt")
+	length := m.Docs[id].Length
+	if report := m.prune(PruneOptions{Hapaxes: true}); report.Postings == 0 {
+		t.Fatal("nothing pruned")
+	}
+	path := filepath.Join(t.TempDir(), "index.json")
+	if err := m.saveAsJson(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := newModelFromJson(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if problems := loaded.check(); len(problems) != 0 {
+		t.Errorf("pruned index has problems: %v", problems)
+	}
+	loaded.repair()
+	if got := loaded.Docs[id].Length; got != length {
+		t.Errorf("repair changed the length from %d to %d", length, got)
+	}
+}
@@ -0,0 +1,89 @@
+package sego
+
+import "unicode"
+
+type PunctPolicy string
+
+const (
+	// "a->b;" => "A", "-", ">", "B", ";"
+	PunctSplit PunctPolicy = "split"
+	// "a->b;" => "A", "B"
+	PunctDrop PunctPolicy = "drop"
+	// "a->b;" => "A", "->", "B", ";", each run one token
+	PunctMerge PunctPolicy = "merge"
+)
+
+func isPunctuation(r rune) bool {
+	return (unicode.IsPunct(r) || unicode.IsSymbol(r)) && !isEmoji(r)
+}
+
+// keptPunctuation returns the length of the longest sequence to keep whole
+// text starts with, so "->>" wins over "->"
+func (o LexerOptions) keptPunctuation(text []rune) int {
+	longest := 0
+	for _, keep := range o.KeepPunct {
+		if n := len([]rune(keep)); n > longest && hasPrefixRunes(text, keep) {
+			longest = n
+		}
+	}
+	return longest
+}
+
+func hasPrefixRunes(text []rune, prefix string) bool {
+	i := 0
+	for _, r := range prefix {
+		if i >= len(text) || text[i] != r {
+			return false
+		}
+		i++
+	}
+	return i > 0
+}
+
+// chopPunctuation chops a run of punctuation and symbols, returning its first
+// token by the punctuation policy and queuing the others, nil if it drops all
+func (l *lexer) chopPunctuation() []rune {
+	n := 0
+	for n < len(l.content) && isPunctuation(l.content[n]) && !(n > 0 && l.content[n] == '<' && l.opts.Markup != MarkupNone) {
+		n++
+	}
+	run := l.chop(n)
+
+	tokens := make([][]rune, 0)
+	merged := 0
+	flush := func(i int) {
+		if merged < i {
+			tokens = append(tokens, run[merged:i])
+		}
+	}
+	for i := 0; i < len(run); {
+		if kept := l.opts.keptPunctuation(run[i:]); kept > 0 {
+			if l.opts.Punctuation == PunctMerge {
+				flush(i)
+			}
+			tokens = append(tokens, run[i:i+kept])
+			i += kept
+			merged = i
+			continue
+		}
+		switch l.opts.Punctuation {
+		case PunctDrop:
+		case PunctMerge:
+			i++
+			continue
+		default:
+			tokens = append(tokens, run[i:i+1])
+		}
+		i++
+		merged = i
+	}
+	if l.opts.Punctuation == PunctMerge {
+		flush(len(run))
+	}
+
+	if len(tokens) == 0 {
+		return nil
+	}
+	l.pending = append(l.pending, tokens[1:]...)
+	return tokens[0]
+}
@@ -0,0 +1,160 @@
+package sego
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// QualityOptions weigh signals of how good a document is whatever the query
+// into a prior its rank is multiplied with, like {"weights": {"length": 0.2,
+// "depth": 0.1, "freshness": 0.5}}
+type QualityOptions struct {
+	// how much each signal adds to the rank of a document scoring 1 on it,
+	// e.g. 0.5 for up to 50% more
+	Weights map[string]float64 `json:"weights"`
+	// documents this many tokens long score best on length, default 300
+	IdealLength int `json:"ideal_length,omitempty"`
+	// how long until a document is half as fresh, e.g. "4380h", default a year
+	HalfLife string `json:"half_life,omitempty"`
+
+	// names of the weighted signals in order, set by check
+	names []string
+}
+
+// qualitySignal scores a document 0..1 whatever the query
+type qualitySignal func(doc *Document, opts *QualityOptions, now time.Time) float64
+
+// signals by name, plugins may add more
+var qualitySignals = map[string]qualitySignal{
+	"length":    lengthSignal,
+	"depth":     depthSignal,
+	"freshness": freshnessSignal,
+}
+
+// lengthSignal favors documents neither much shorter nor much longer than
+// the ideal length, like stubs or whole manuals
+func lengthSignal(doc *Document, opts *QualityOptions, now time.Time) float64 {
+	ideal := opts.IdealLength
+	if ideal <= 0 {
+		ideal = 300
+	}
+	if doc.Length <= 0 {
+		return 0
+	}
+	ratio := float64(doc.Length) / float64(ideal)
+	if ratio > 1 {
+		ratio = 1 / ratio
+	}
+	return ratio
+}
+
+// depthSignal favors documents near the top of a site or folder, overview
+// pages over those deep down, 1 at the top and halving every two levels
+func depthSignal(doc *Document, opts *QualityOptions, now time.Time) float64 {
+	p := doc.Path
+	if doc.Parent != "" {
+		p = doc.Parent
+	}
+	if u, err := url.Parse(p); err == nil && u.Host != "" {
+		p = u.Path
+	}
+	depth := strings.Count(strings.Trim(p, "/"), "/")
+	return math.Pow(0.5, float64(depth)/2)
+}
+
+// freshnessSignal favors documents published or changed recently, 1 for now
+// and halving every half-life, 0 for those without a date
+func freshnessSignal(doc *Document, opts *QualityOptions, now time.Time) float64 {
+	date, ok := doc.date()
+	if !ok {
+		return 0
+	}
+	halfLife, err := time.ParseDuration(opts.HalfLife)
+	if err != nil || halfLife <= 0 {
+		halfLife = 365 * 24 * time.Hour
+	}
+	age := now.Sub(date)
+	if age < 0 {
+		return 1
+	}
+	return math.Pow(0.5, float64(age)/float64(halfLife))
+}
+
+// date is when the document was published, or else last changed
+func (d *Document) date() (time.Time, bool) {
+	switch {
+	case d.Published != nil:
+		return *d.Published, true
+	case d.Modified != nil:
+		return *d.Modified, true
+	case d.LastModified != "":
+		t, err := http.ParseTime(d.LastModified)
+		return t, err == nil
+	}
+	return time.Time{}, false
+}
+
+func (o *QualityOptions) check() error {
+	for name, weight := range o.Weights {
+		if _, ok := qualitySignals[name]; !ok {
+			return fmt.Errorf("unknown quality signal %q", name)
+		}
+		if weight < 0 {
+			return fmt.Errorf("negative weight %g for quality signal %s", weight, name)
+		}
+	}
+	if o.HalfLife != "" {
+		if _, err := time.ParseDuration(o.HalfLife); err != nil {
+			return fmt.Errorf("quality half-life: %w", err)
+		}
+	}
+	o.names = o.signalNames()
+	return nil
+}
+
+// signalNames are the names of the weighted signals, sorted
+func (o *QualityOptions) signalNames() []string {
+	names := make([]string, 0, len(o.Weights))
+	for name := range o.Weights {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// quality is the prior a document's rank is multiplied with, 1 plus the
+// weighted scores of the signals
+func (m *Model) quality(id string, now time.Time) float64 {
+	doc, ok := m.Docs[id]
+	if m.Quality == nil || !ok {
+		return 1
+	}
+	prior := 1.0
+	// in the same order every time, so the sum is too
+	for _, name := range m.Quality.names {
+		if signal, ok := qualitySignals[name]; ok {
+			prior += m.Quality.Weights[name] * signal(doc, m.Quality, now)
+		}
+	}
+	return prior
+}
+
+// setModified records when the file at path, and so its sections or chunks,
+// last changed
+func (m *Model) setModified(path string, modified time.Time) {
+	modified = modified.UTC().Truncate(time.Second)
+	ids := m.sectionsOf(path)
+	if id, ok := m.docID(path); ok && !contains(ids, id) {
+		ids = append(ids, id)
+	}
+	for _, id := range ids {
+		if doc, ok := m.Docs[id]; ok {
+			doc.Modified = &modified
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package sego
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestModifiedAfterRename(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, "old.txt")
+	if err := os.WriteFile(old, []byte("vertex shader"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	config := newConfig()
+	m := newModel()
+	m.Quality = &QualityOptions{Weights: map[string]float64{"freshness": 1}}
+	if err := m.Quality.check(); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.index(newDirSource(dir, config), config); err != nil {
+		t.Fatal(err)
+	}
+
+	renamed := filepath.Join(dir, "new.txt")
+	if err := os.Rename(old, renamed); err != nil {
+		t.Fatal(err)
+	}
+	modified := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	if err := os.Chtimes(renamed, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.index(newDirSource(dir, config), config); err != nil {
+		t.Fatal(err)
+	}
+	doc, ok := m.document(renamed)
+	if !ok {
+		t.Fatal("renamed document is gone")
+	}
+	if doc.Modified == nil || !doc.Modified.Equal(modified) {
+		t.Errorf("renamed document modified %v, want %v", doc.Modified, modified)
+	}
+}
+
+func TestQualityOrder(t *testing.T) {
+	m := newModel()
+	if err := m.apply(&IngestMessage{Path: "docs/a.txt", Content: "shader"}, newConfig()); err != nil {
+		t.Fatal(err)
+	}
+	id, _ := m.docID("docs/a.txt")
+	weights := make(map[string]float64)
+	for i, name := range []string{"length", "depth", "freshness"} {
+		weights[name] = 0.1 * float64(i+1)
+	}
+	m.Quality = &QualityOptions{Weights: weights}
+	if err := m.Quality.check(); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	want := m.quality(id, now)
+	if want == 1 {
+		t.Fatal("no signal weighed in")
+	}
+	for i := 0; i < 50; i++ {
+		if got := m.quality(id, now); got != want {
+			t.Fatalf("quality %v, then %v", want, got)
+		}
+	}
+}
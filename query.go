@@ -0,0 +1,134 @@
+package sego
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// fields that can be used as `field:value` filters in queries, values ending
+// in * match those starting with the rest, like tag:gl*
+var filterFields = map[string]func(doc *Document, value string) bool{
+	"lang": func(doc *Document, value string) bool { return filterValueMatches(doc.Lang, value) },
+	"tag": func(doc *Document, value string) bool {
+		for _, tag := range doc.Tags {
+			if filterValueMatches(tag, value) {
+				return true
+			}
+		}
+		return false
+	},
+	// version:all searches all versions instead of the newest
+	"version": func(doc *Document, value string) bool {
+		return value == "all" || filterValueMatches(doc.Version, value)
+	},
+}
+
+func filterValueMatches(have, value string) bool {
+	if prefix, ok := strings.CutSuffix(value, "*"); ok {
+		return have != "" && strings.HasPrefix(have, prefix)
+	}
+	return have == value
+}
+
+type Filter struct {
+	Field string
+	Value string
+}
+
+type Query struct {
+	// free text to be scored
+	Text    string
+	Filters []Filter
+	// beginnings of terms to be scored as the terms they start, from "shad*"
+	Prefixes []string
+	// words documents have to contain, from "+shader", they are scored too
+	Required []string
+}
+
+func parseQuery(query string) Query {
+	words := make([]string, 0)
+	filters := make([]Filter, 0)
+	prefixes := make([]string, 0)
+	required := make([]string, 0)
+	for _, word := range strings.Fields(query) {
+		if term, ok := strings.CutPrefix(word, "+"); ok && term != "" {
+			required = append(required, term)
+			words = append(words, term)
+			continue
+		}
+		// filters first, their values may end in * too
+		field, value, ok := strings.Cut(word, ":")
+		if _, known := filterFields[strings.ToLower(field)]; ok && known && value != "" {
+			filters = append(filters, Filter{
+				Field: strings.ToLower(field),
+				Value: strings.ToLower(value),
+			})
+			continue
+		}
+		if prefix, ok := strings.CutSuffix(word, "*"); ok && prefix != "" && !strings.Contains(prefix, "*") {
+			prefixes = append(prefixes, prefix)
+			continue
+		}
+		words = append(words, word)
+	}
+
+	return Query{
+		Text:     strings.Join(words, " "),
+		Filters:  filters,
+		Prefixes: prefixes,
+		Required: required,
+	}
+}
+
+// filterBitmap evaluates the filters over docs, filters on the same field are
+// ORed, different fields are ANDed. Returns nil when there is nothing to filter.
+func (m *Model) filterBitmap(docs []string, filters []Filter) bitmap {
+	if len(filters) == 0 {
+		return nil
+	}
+
+	byField := make(map[string]bitmap)
+	for _, filter := range filters {
+		matches := newBitmap(len(docs))
+		match := filterFields[filter.Field]
+		for i, id := range docs {
+			if doc, ok := m.Docs[id]; ok && match(doc, filter.Value) {
+				matches.set(i)
+			}
+		}
+		if b, ok := byField[filter.Field]; ok {
+			b.or(matches)
+		} else {
+			byField[filter.Field] = matches
+		}
+	}
+
+	var result bitmap
+	for _, b := range byField {
+		if result == nil {
+			result = b
+		} else {
+			result.and(b)
+		}
+	}
+	return result
+}
+
+// docIDs returns the indexed documents that weren't deleted and haven't
+// expired in a stable order
+func (m *Model) docIDs() []string {
+	now := time.Now()
+	docs := make([]string, 0, len(m.TF))
+	for id := range m.TF {
+		if m.Deleted[id] {
+			continue
+		}
+		if doc, ok := m.Docs[id]; ok && doc.expired(now) {
+			continue
+		}
+		docs = append(docs, id)
+	}
+	sort.Strings(docs)
+	return docs
+}
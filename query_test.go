@@ -0,0 +1,44 @@
+package sego
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  Query
+	}{
+		{"vertex shader", Query{Text: "vertex shader"}},
+		{"shader lang:EN Tag:gl", Query{
+			Text:    "shader",
+			Filters: []Filter{{Field: "lang", Value: "en"}, {Field: "tag", Value: "gl"}},
+		}},
+		{"+uniform buffer", Query{Text: "uniform buffer", Required: []string{"uniform"}}},
+		{"shad* map", Query{Text: "map", Prefixes: []string{"shad"}}},
+		{"tag:gl* shad*", Query{
+			Filters:  []Filter{{Field: "tag", Value: "gl*"}},
+			Prefixes: []string{"shad"},
+		}},
+		{"color:red", Query{Text: "color:red"}},
+		{"tag: * sh*d* +", Query{Text: "tag: * sh*d* +"}},
+		{"", Query{}},
+	}
+	for _, test := range tests {
+		// parsed queries have empty lists, not nil ones
+		want := test.want
+		if want.Filters == nil {
+			want.Filters = []Filter{}
+		}
+		if want.Prefixes == nil {
+			want.Prefixes = []string{}
+		}
+		if want.Required == nil {
+			want.Required = []string{}
+		}
+		if got := parseQuery(test.query); !reflect.DeepEqual(got, want) {
+			t.Errorf("%q parsed as %+v, want %+v", test.query, got, want)
+		}
+	}
+}
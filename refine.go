@@ -0,0 +1,26 @@
+package sego
+
+// within keeps the results that matched each of the previous queries too, so
+// a search can be narrowed down step by step without the server keeping the
+// earlier result sets
+func (m *Model) within(results SearchResults, previous []string) SearchResults {
+	if len(previous) == 0 {
+		return results
+	}
+
+	matches := make(map[string]int)
+	for _, query := range previous {
+		for _, r := range m.search(query) {
+			if r.Rank > 0 {
+				matches[r.ID]++
+			}
+		}
+	}
+	narrowed := make(SearchResults, 0)
+	for _, r := range results {
+		if matches[r.ID] == len(previous) {
+			narrowed = append(narrowed, r)
+		}
+	}
+	return narrowed
+}
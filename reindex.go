@@ -0,0 +1,95 @@
+package sego
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+// reindexMatching reads the documents query matches again from their files and
+// extracts and analyzes them with config, like after changing the analyzer
+// for some of them. Documents that aren't local files, like crawled pages, or
+// whose file is gone are left as they are.
+func (m *Model) reindexMatching(query Node, config *Config) (*IndexStats, error) {
+	paths, err := m.matchingPaths(query, nil)
+	if err != nil {
+		return nil, err
+	}
+	// analyzers come from config, not the index, so changes to them apply
+	m.setupAnalyzers(config)
+	if m.Stats == nil {
+		m.Stats = newIndexStats()
+	}
+
+	stats := newIndexStats()
+	for _, path := range paths {
+		stats.Files++
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			stats.skip(path, "not a readable file")
+			continue
+		}
+		sizeLimited := ""
+		if config.MaxFileSize > 0 && info.Size() > config.MaxFileSize {
+			sizeLimited = fmt.Sprintf("%d bytes, max file size is %d", info.Size(), config.MaxFileSize)
+			if config.OnLimit == "skip" {
+				stats.skip(path, sizeLimited)
+				continue
+			}
+		}
+
+		log.Printf("Reindexing: %s", path)
+		r, err := os.Open(path)
+		if err != nil {
+			return stats, err
+		}
+		content, err := readLimit(r, config.MaxFileSize)
+		r.Close()
+		if err != nil {
+			return stats, err
+		}
+		if err := m.indexDocument(path, content, sizeLimited, config, stats); err != nil {
+			return stats, err
+		}
+		if m.Quality != nil {
+			m.setModified(path, info.ModTime())
+		}
+	}
+	m.indexVectors()
+	return stats, nil
+}
+
+func runReindex(args []string) {
+	fs := flag.NewFlagSet("reindex", flag.ExitOnError)
+	indexPath := fs.String("index", "index-new.json", "index to update")
+	query := fs.String("query", "", "reindex the documents matching this, like 'path:**/*.md'")
+	config := newConfig()
+	config.registerFlags(fs)
+	fs.Parse(args)
+	if *query == "" || fs.NArg() > 0 {
+		log.Fatal("usage: sego reindex [-index index.json] [flags] -query query")
+	}
+	for name, opts := range config.Analyzers {
+		if err := opts.checkFilters(); err != nil {
+			log.Fatalf("analyzer %s: %s", name, err)
+		}
+	}
+
+	selection, err := parseSelection(*query)
+	if err != nil {
+		log.Fatal(err)
+	}
+	model, err := newModelFromJson(*indexPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	stats, err := model.reindexMatching(selection, config)
+	if err != nil {
+		config.fatal(*indexPath, err)
+	}
+	if err := model.saveAsJson(*indexPath); err != nil {
+		config.fatal(*indexPath, err)
+	}
+	log.Printf("Reindexed %s", stats)
+}
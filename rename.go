@@ -0,0 +1,74 @@
+package sego
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+)
+
+func contentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:16])
+}
+
+// renames tracks the documents of a source that weren't listed anymore, a new
+// document with the same content as one of them is taken to be it moved
+type renames struct {
+	// path => content hash
+	gone map[string]string
+	// content hash => paths
+	byHash map[string][]string
+}
+
+func (m *Model) goneDocuments(source Source, names []string) *renames {
+	listed := make(map[string]bool, len(names))
+	for _, name := range names {
+		listed[name] = true
+	}
+	r := &renames{gone: make(map[string]string), byHash: make(map[string][]string)}
+	for path, id := range m.paths {
+		// sections are there as long as their page is
+		name, hash := path, m.Docs[id].Hash
+		if parent := m.Docs[id].Parent; parent != "" {
+			name, hash = parent, ""
+		}
+		if listed[name] || m.Deleted[id] || !source.Contains(name) {
+			continue
+		}
+		r.gone[path] = hash
+		if hash != "" {
+			r.byHash[hash] = append(r.byHash[hash], path)
+		}
+	}
+	return r
+}
+
+// rename moves a gone document with the content of the new document at path
+// there and reports whether there was one
+func (m *Model) rename(r *renames, path string, content []byte) bool {
+	if len(r.gone) == 0 {
+		return false
+	}
+	if _, known := m.docID(path); known {
+		return false
+	}
+	hash := contentHash(content)
+	candidates := r.byHash[hash]
+	if len(candidates) == 0 {
+		return false
+	}
+	from := candidates[0]
+	r.byHash[hash] = candidates[1:]
+	delete(r.gone, from)
+	m.move(from, path)
+	log.Printf("Renamed: %s => %s", from, path)
+	return true
+}
+
+// removeGone deletes the gone documents that weren't renamed
+func (m *Model) removeGone(r *renames) {
+	for path := range r.gone {
+		log.Printf("Removing: %s", path)
+		m.removeDocument(path)
+	}
+}
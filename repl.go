@@ -0,0 +1,211 @@
+package sego
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// explain breaks the rank of a document for query down into what each term,
+// the anchor text, the n-grams and the boosts contribute
+func (m *Model) explain(w io.Writer, query string, id string) {
+	plan := m.plan(query, nil, &SearchTiming{})
+	tokens, weights := plan.tokens, plan.weights
+	tf := m.TF[id]
+	length := m.docLength(id)
+
+	fmt.Fprintf(w, "%s, %d terms\n", m.docPath(id), length)
+	for _, t := range weights {
+		score := calculateTF(t.Term, tf, length) * t.Weight
+		fmt.Fprintf(w, "  %-20s tf %d/%d  df %d  weight %.4f  => %.6f\n", t.Term, tf[t.Term], length, m.DF[t.Term], t.Weight, score)
+	}
+	if m.AnchorBoost > 0 {
+		score := m.anchorRank(m.docPath(id), weights)
+		fmt.Fprintf(w, "  %-20s => %.6f\n", "anchor text", score)
+	}
+	if m.AttrBoost > 0 {
+		score := m.attributeRank(id, weights)
+		fmt.Fprintf(w, "  %-20s => %.6f\n", "attribute text", score)
+	}
+	if plan.gramWeights != nil {
+		score := m.gramRank(id, plan.gramWeights)
+		fmt.Fprintf(w, "  %-20s => %.6f\n", "n-grams", score)
+	}
+	if m.Quality != nil {
+		for _, name := range m.Quality.names {
+			if signal, ok := qualitySignals[name]; ok {
+				fmt.Fprintf(w, "  %-20s %.4f  weight %.4f\n", name, signal(m.Docs[id], m.Quality, plan.now), m.Quality.Weights[name])
+			}
+		}
+		fmt.Fprintf(w, "  %-20s x %.4f\n", "quality", m.quality(id, plan.now))
+	}
+	fmt.Fprintf(w, "  %-20s x %.4f\n", "boost", m.boost(id, tokens, plan.now))
+	// the rank as searching scores it
+	plan.docs, plan.allowed = []string{id}, nil
+	scored := m.score(plan, 0, 1)
+	fmt.Fprintf(w, "  %-20s = %.6f\n", "rank", scored[0].Rank)
+}
+
+type repl struct {
+	model   *Model
+	limit   int
+	filters []string
+	history []string
+	// of the last search
+	query   string
+	results SearchResults
+}
+
+func (r *repl) search(query string) {
+	r.query = strings.Join(append([]string{query}, r.filters...), " ")
+	start := time.Now()
+	results, _ := r.model.searchTimed(r.query)
+	results = r.model.collapseVersions(results)
+	took := time.Since(start)
+	if len(results) > r.limit {
+		results = results[:r.limit]
+	}
+	r.results = results
+	for i, v := range results {
+		fmt.Printf("%2d. %s => %f\n", i+1, v.Path, v.Rank)
+	}
+	fmt.Printf("(%s)\n", took.Round(time.Microsecond))
+}
+
+// result returns the result numbered by arg, the first one if arg is empty
+func (r *repl) result(arg string) (SearchResult, bool) {
+	n := 1
+	if arg != "" {
+		var err error
+		if n, err = strconv.Atoi(arg); err != nil {
+			fmt.Printf("not a result number: %s\n", arg)
+			return SearchResult{}, false
+		}
+	}
+	if n < 1 || n > len(r.results) {
+		fmt.Printf("no result %d\n", n)
+		return SearchResult{}, false
+	}
+	return r.results[n-1], true
+}
+
+// command runs a :command, it returns false when the session is over
+func (r *repl) command(line string) bool {
+	name, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+	switch name {
+	case ":quit", ":q":
+		return false
+	case ":limit":
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 {
+			fmt.Printf("limit is %d\n", r.limit)
+			break
+		}
+		r.limit = n
+	case ":filter":
+		switch {
+		case arg == "":
+			fmt.Printf("filters: %s\n", strings.Join(r.filters, " "))
+		case arg == "clear":
+			r.filters = nil
+		default:
+			if len(parseQuery(arg).Filters) == 0 {
+				fmt.Printf("not a filter: %s, use e.g. lang:en, tag:api or version:gl3\n", arg)
+				break
+			}
+			r.filters = append(r.filters, strings.Fields(arg)...)
+		}
+	case ":explain":
+		if res, ok := r.result(arg); ok {
+			r.model.explain(os.Stdout, r.query, res.ID)
+		}
+	case ":open":
+		if res, ok := r.result(arg); ok {
+			if err := openLocation(r.model.location(res)); err != nil {
+				fmt.Println(err)
+			}
+		}
+	case ":history":
+		for i, query := range r.history {
+			fmt.Printf("%3d  %s\n", i+1, query)
+		}
+	default:
+		fmt.Println(":limit n, :filter field:value|clear, :explain [n], :open [n], :history, !n to repeat a query, :quit")
+	}
+	return true
+}
+
+func replHistoryPath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "sego", "repl-history")
+}
+
+func runRepl(args []string) {
+	fs := flag.NewFlagSet("repl", flag.ExitOnError)
+	indexPath := fs.String("index", "index-new.json", "index to search")
+	limit := fs.Int("n", 10, "number of results to show")
+	fs.Parse(args)
+
+	start := time.Now()
+	model, err := newModelFromJson(*indexPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Loaded %s in %s, %d documents. :help for commands\n", *indexPath, time.Since(start).Round(time.Millisecond), len(model.docIDs()))
+
+	r := &repl{model: model, limit: *limit}
+	var history *os.File
+	if file := replHistoryPath(); file != "" {
+		if data, err := os.ReadFile(file); err == nil && len(data) > 0 {
+			r.history = strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+		}
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err == nil {
+			history, _ = os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		}
+	}
+	if history != nil {
+		defer history.Close()
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Print("> ")
+		if !scanner.Scan() {
+			fmt.Println()
+			break
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if n, err := strconv.Atoi(strings.TrimPrefix(line, "!")); strings.HasPrefix(line, "!") && err == nil {
+			if n < 1 || n > len(r.history) {
+				fmt.Printf("no query %d in the history\n", n)
+				continue
+			}
+			line = r.history[n-1]
+			fmt.Println(line)
+		}
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, ":"):
+			if !r.command(line) {
+				return
+			}
+		default:
+			r.history = append(r.history, line)
+			if history != nil {
+				fmt.Fprintln(history, line)
+			}
+			r.search(line)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package sego
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestExplainMatchesSearch(t *testing.T) {
+	config := newConfig()
+	m := newModel()
+	for path, content := range map[string]string{
+		"a.txt": "the vertex shader and the shadow map",
+		"b.txt": "fragment shaders",
+		"c.txt": "the vertex buffer",
+	} {
+		if err := m.apply(&IngestMessage{Path: path, Content: content}, config); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, query := range []string{"vertex shader", "shad*", "the vertex lang:en"} {
+		results, _ := m.searchTimed(query)
+		for _, r := range results {
+			var out strings.Builder
+			m.explain(&out, query, r.ID)
+			if want := fmt.Sprintf("= %.6f\n", r.Rank); !strings.HasSuffix(out.String(), want) {
+				t.Errorf("%q explains %s as\n%s\nwant rank %.6f", query, r.Path, out.String(), r.Rank)
+			}
+		}
+	}
+}
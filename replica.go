@@ -0,0 +1,93 @@
+package sego
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// snapshotCache keeps the snapshot served to replicas until the model changes
+type snapshotCache struct {
+	version uint64
+	etag    string
+	data    []byte
+}
+
+func (s *server) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	s.cacheMu.Lock()
+	if s.cache == nil || s.cache.version != s.model.version {
+		var buf bytes.Buffer
+		if err := s.model.Snapshot(&buf, nil, false); err != nil {
+			s.cacheMu.Unlock()
+			s.mu.RUnlock()
+			httpError(w, http.StatusInternalServerError, err)
+			return
+		}
+		sum := sha256.Sum256(buf.Bytes())
+		s.cache = &snapshotCache{
+			version: s.model.version,
+			etag:    `"` + hex.EncodeToString(sum[:16]) + `"`,
+			data:    buf.Bytes(),
+		}
+	}
+	cache := s.cache
+	s.cacheMu.Unlock()
+	s.mu.RUnlock()
+
+	w.Header().Set("ETag", cache.etag)
+	if r.Header.Get("If-None-Match") == cache.etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Write(cache.data)
+}
+
+// pull fetches the primary's snapshot unless it still has etag and swaps it
+// in, returning the etag of what is served now
+func (s *server) pull(client *http.Client, primary, etag string) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(primary, "/")+"/snapshot", nil)
+	if err != nil {
+		return etag, err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return etag, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		return etag, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return etag, fmt.Errorf("%s: %s", req.URL, resp.Status)
+	}
+
+	model, err := loadSnapshot(resp.Body)
+	if err != nil {
+		return etag, err
+	}
+	s.swap(model)
+	log.Printf("Pulled %d documents from %s", len(model.TF), primary)
+	return resp.Header.Get("ETag"), nil
+}
+
+// replicate keeps pulling the primary's index every interval
+func (s *server) replicate(primary string, interval time.Duration, etag string) {
+	client := &http.Client{Timeout: 5 * time.Minute}
+	for {
+		time.Sleep(interval)
+		var err error
+		if etag, err = s.pull(client, primary, etag); err != nil {
+			log.Printf("Pulling from %s: %s", primary, err)
+		}
+	}
+}
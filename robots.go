@@ -0,0 +1,79 @@
+package sego
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// robotsDirectives are what a page's robots meta tags and X-Robots-Tag
+// headers ask of crawlers
+type robotsDirectives struct {
+	noindex  bool
+	nofollow bool
+}
+
+// parse adds the directives of a robots meta tag or header value. Values
+// scoped to a crawler like "googlebot: noindex" only apply to that crawler and
+// are ignored.
+func (d *robotsDirectives) parse(value string) {
+	if name, rest, ok := strings.Cut(value, ":"); ok && !strings.ContainsAny(name, ",") {
+		if agent := strings.ToLower(strings.TrimSpace(name)); agent != "*" && agent != "sego" {
+			return
+		}
+		value = rest
+	}
+	for _, directive := range strings.Split(value, ",") {
+		switch strings.ToLower(strings.TrimSpace(directive)) {
+		case "noindex":
+			d.noindex = true
+		case "nofollow":
+			d.nofollow = true
+		case "none":
+			d.noindex = true
+			d.nofollow = true
+		}
+	}
+}
+
+// headDirectives reads the robots meta tags and the canonical URL from the
+// head of an HTML page
+func headDirectives(pageURL string, body []byte, d *robotsDirectives) (canonical string) {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return ""
+	}
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return canonical
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			attrs := make(map[string]string)
+			for _, attr := range token.Attr {
+				attrs[attr.Key] = attr.Val
+			}
+			switch token.Data {
+			case "meta":
+				if name := strings.ToLower(attrs["name"]); name == "robots" || name == "sego" {
+					d.parse(attrs["content"])
+				}
+			case "link":
+				for _, rel := range strings.Fields(strings.ToLower(attrs["rel"])) {
+					if rel == "canonical" && canonical == "" {
+						canonical = normalizeLink(base, attrs["href"])
+					}
+				}
+			case "body":
+				return canonical
+			}
+		case html.EndTagToken:
+			if name, _ := tokenizer.TagName(); string(name) == "head" {
+				return canonical
+			}
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package sego
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+
+	xhtml "golang.org/x/net/html"
+)
+
+// section is the part of a page under a heading, or before the first one
+type section struct {
+	// anchor of the heading, "" before the first one
+	id string
+	// text of the heading, and the heading as written in the page
+	title   string
+	heading string
+	body    strings.Builder
+}
+
+// text is what is indexed of the section, its heading and body
+func (s *section) text() string {
+	return s.heading + "\n\n" + s.body.String()
+}
+
+// splitSections splits a page at its headings of up to level into sections,
+// the text before the first heading is one too unless it is empty. Text of
+// HTML pages comes escaped, so it can be analyzed as markup like the page.
+func splitSections(content []byte, isHTML bool, level int) []*section {
+	var sections []*section
+	if isHTML {
+		sections = splitHTMLSections(content, level)
+	} else {
+		sections = splitMarkdownSections(content, level)
+	}
+	// headings without letters or digits, like "???", get their number as
+	// anchor instead of the page's path
+	for i := 1; i < len(sections); i++ {
+		if sections[i].id == "" {
+			sections[i].id = fmt.Sprintf("section-%d", i)
+		}
+	}
+	if len(sections) > 0 && sections[0].id == "" && strings.TrimSpace(sections[0].body.String()) == "" {
+		sections = sections[1:]
+	}
+
+	// anchors are unique within a page like headings numbered by GitHub
+	used := make(map[string]bool)
+	for _, s := range sections {
+		if s.id == "" {
+			continue
+		}
+		id := s.id
+		for n := 1; used[s.id]; n++ {
+			s.id = fmt.Sprintf("%s-%d", id, n)
+		}
+		used[s.id] = true
+	}
+	return sections
+}
+
+// headingLevel is the level of h1 to h6 elements, 0 for others
+func headingLevel(n *xhtml.Node) int {
+	if n.Type != xhtml.ElementNode || len(n.Data) != 2 || n.Data[0] != 'h' || n.Data[1] < '1' || n.Data[1] > '6' {
+		return 0
+	}
+	return int(n.Data[1] - '0')
+}
+
+// elements whose text isn't part of the page's content
+var nonContentTags = map[string]bool{"head": true, "script": true, "style": true, "template": true}
+
+func splitHTMLSections(content []byte, level int) []*section {
+	doc, err := xhtml.Parse(bytes.NewReader(content))
+	if err != nil {
+		return nil
+	}
+	current := &section{}
+	sections := []*section{current}
+	var walk func(n *xhtml.Node)
+	walk = func(n *xhtml.Node) {
+		switch {
+		case n.Type == xhtml.TextNode:
+			current.body.WriteString(html.EscapeString(n.Data))
+			current.body.WriteByte(' ')
+			return
+		case n.Type == xhtml.ElementNode && nonContentTags[n.Data]:
+			return
+		case headingLevel(n) > 0 && headingLevel(n) <= level:
+			heading := cleanText(nodeText(n))
+			id := headingAnchor(n)
+			if id == "" {
+				id = slugify(heading)
+			}
+			current = &section{id: id, title: heading, heading: html.EscapeString(heading)}
+			sections = append(sections, current)
+			return
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(doc)
+	return sections
+}
+
+// nodeText is the text within n
+func nodeText(n *xhtml.Node) string {
+	if n.Type == xhtml.TextNode {
+		return n.Data
+	}
+	var text strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		text.WriteString(nodeText(child))
+	}
+	return text.String()
+}
+
+// headingAnchor is the ID of a heading or of an anchor in it, "" if it has
+// none
+func headingAnchor(n *xhtml.Node) string {
+	if id := attr(n, "id"); id != "" {
+		return id
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != xhtml.ElementNode {
+			continue
+		}
+		if id := attr(child, "id"); id != "" {
+			return id
+		}
+		if child.Data == "a" && attr(child, "name") != "" {
+			return attr(child, "name")
+		}
+	}
+	return ""
+}
+
+var (
+	markdownHeadingRe = regexp.MustCompile(`^(#{1,6})[ \t]+(.+?)[ \t#]*$`)
+	// explicit anchors like "## Usage {#usage}"
+	markdownAnchorRe = regexp.MustCompile(`[ \t]*\{#([^}\s]+)\}$`)
+)
+
+func splitMarkdownSections(content []byte, level int) []*section {
+	current := &section{}
+	sections := []*section{current}
+	fence := ""
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+		} else if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+		} else if m := markdownHeadingRe.FindStringSubmatch(line); m != nil && len(m[1]) <= level {
+			heading, id := m[2], ""
+			if a := markdownAnchorRe.FindStringSubmatchIndex(heading); a != nil {
+				heading, id = heading[:a[0]], heading[a[2]:a[3]]
+			}
+			if id == "" {
+				id = slugify(heading)
+			}
+			current = &section{id: id, title: cleanText(heading), heading: line}
+			sections = append(sections, current)
+			continue
+		}
+		current.body.WriteString(line)
+		current.body.WriteByte('\n')
+	}
+	return sections
+}
+
+// slugify makes the anchor GitHub gives a heading, "Vertex Arrays (VAO)" =>
+// "vertex-arrays-vao"
+func slugify(heading string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(heading)) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsNumber(r) || r == '-' || r == '_':
+			b.WriteRune(r)
+		case r == ' ':
+			b.WriteByte('-')
+		}
+	}
+	return b.String()
+}
+
+// sectionTitle is the title of a section of the page titled page
+func sectionTitle(heading, page string) string {
+	switch {
+	case heading == "":
+		return page
+	case page == "" || page == heading:
+		return heading
+	}
+	return heading + " - " + page
+}
+
+// indexSections indexes the sections of the page d, each under the path of
+// the page with its anchor, and removes those it no longer has
+func (m *Model) indexSections(d *extracted, sections []*section, sizeLimited string, config *Config, stats *IndexStats) error {
+	page := extractMetadata(d.content)
+	page.merge(d.meta)
+	keep := make(map[string]bool, len(sections))
+	for _, s := range sections {
+		sub := *d
+		sub.parent = d.path
+		if s.id != "" {
+			sub.path = d.path + "#" + s.id
+		}
+		sub.content = []byte(s.text())
+		meta := *page
+		meta.Title = sectionTitle(s.title, page.Title)
+		if description := firstSentence([]byte(s.body.String()), false); description != "" {
+			meta.Description = html.UnescapeString(description)
+		}
+		sub.meta = &meta
+		keep[sub.path] = true
+		if err := m.indexExtracted(&sub, sizeLimited, config, stats); err != nil {
+			return err
+		}
+	}
+	m.removeSections(d.path, keep)
+	return nil
+}
+
+// sectionsOf returns the IDs of the sections or chunks of the page at path
+func (m *Model) sectionsOf(path string) []string {
+	if m.Sections == 0 && m.ChunkSize == 0 {
+		return nil
+	}
+	if m.sections == nil {
+		m.sections = make(map[string][]string)
+		for id, doc := range m.Docs {
+			if doc.Parent != "" {
+				m.sections[doc.Parent] = append(m.sections[doc.Parent], id)
+			}
+		}
+	}
+	ids := make([]string, 0, len(m.sections[path]))
+	for _, id := range m.sections[path] {
+		if doc, ok := m.Docs[id]; ok && doc.Parent == path && !m.Deleted[id] {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func (m *Model) addSection(path, id string) {
+	if m.sections != nil && !contains(m.sections[path], id) {
+		m.sections[path] = append(m.sections[path], id)
+	}
+}
+
+// removeSections deletes the sections or chunks of the page at path, and
+// what was indexed at the path itself, but those in keep and returns how
+// many it deleted
+func (m *Model) removeSections(path string, keep map[string]bool) int {
+	ids := m.sectionsOf(path)
+	if id, ok := m.docID(path); ok && !contains(ids, id) {
+		ids = append(ids, id)
+	}
+	removed := 0
+	for _, id := range ids {
+		if keep[m.docPath(id)] || m.Deleted[id] {
+			continue
+		}
+		if m.Deleted == nil {
+			m.Deleted = make(map[string]bool)
+		}
+		m.Deleted[id] = true
+		removed++
+	}
+	if removed > 0 {
+		m.version++
+	}
+	return removed
+}
@@ -0,0 +1,99 @@
+package sego
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitSections(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		isHTML  bool
+		level   int
+		// id and title of each section
+		want [][2]string
+	}{
+		{"markdown", "intro\n# Setup\ntext\n## Usage {#use}\nmore\n### Deep\nstill usage\n", false, 2,
+			[][2]string{{"", ""}, {"setup", "Setup"}, {"use", "Usage"}}},
+		{"markdown without intro", "# Vertex Arrays (VAO)\ntext\n", false, 1,
+			[][2]string{{"vertex-arrays-vao", "Vertex Arrays (VAO)"}}},
+		{"fenced heading", "# Real\n```\n# not a heading\n```\n", false, 1,
+			[][2]string{{"real", "Real"}}},
+		{"duplicate headings", "# Notes\na\n# Notes\nb\n# Notes\nc\n", false, 1,
+			[][2]string{{"notes", "Notes"}, {"notes-1", "Notes"}, {"notes-2", "Notes"}}},
+		{"html", `<p>intro</p><h2 id="first">First</h2><p>a</p><h3><a name="second"></a>Second</h3><p>b</p>`, true, 3,
+			[][2]string{{"", ""}, {"first", "First"}, {"second", "Second"}}},
+		{"heading without letters", "intro\n# ???\na\n# Real\nb\n# !!\nc\n", false, 1,
+			[][2]string{{"", ""}, {"section-1", "???"}, {"real", "Real"}, {"section-3", "!!"}}},
+		{"html heading without letters", `<h2>…</h2><p>a</p>`, true, 2,
+			[][2]string{{"section-1", "…"}}},
+		{"html below level", `<h1>Top</h1><h2>Sub</h2><p>a</p>`, true, 1,
+			[][2]string{{"top", "Top"}}},
+	}
+	for _, test := range tests {
+		got := make([][2]string, 0)
+		for _, s := range splitSections([]byte(test.content), test.isHTML, test.level) {
+			got = append(got, [2]string{s.id, s.title})
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: split into %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSplitSectionsEscapesHTML(t *testing.T) {
+	sections := splitSections([]byte(`<h1>a &lt; b</h1><p>x &amp; y</p>`), true, 1)
+	if len(sections) != 1 {
+		t.Fatalf("split into %d sections", len(sections))
+	}
+	if text := sections[0].text(); !strings.Contains(text, "a &lt; b") || !strings.Contains(text, "x &amp; y") {
+		t.Errorf("section text %q isn't escaped", text)
+	}
+}
+
+func TestSectionText(t *testing.T) {
+	dir := t.TempDir()
+	page := filepath.Join(dir, "page.md")
+	if err := os.WriteFile(page, []byte("intro\n# Setup\ninstall it\n# Usage\nrun it\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	m := newModel()
+	m.Sections = 1
+	tests := map[string]string{
+		page + "#usage": "# Usage\n\nrun it\n",
+		page + "#setup": "# Setup\n\ninstall it\n",
+		// a section gone since indexing shows the page
+		page + "#gone": "intro\n# Setup\ninstall it\n# Usage\nrun it\n",
+		page:           "intro\n# Setup\ninstall it\n# Usage\nrun it\n",
+		page + ".old":  "",
+	}
+	for path, want := range tests {
+		if got := m.documentText(path, newConfig()); got != want {
+			t.Errorf("%s: text %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestSectionsOrChunks(t *testing.T) {
+	config := newConfig()
+	fs := flag.NewFlagSet("index", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	config.registerFlags(fs)
+	if err := fs.Parse([]string{"-sections", "2", "-chunks", "300"}); err == nil {
+		t.Error("-sections and -chunks combined")
+	}
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"sections": 2, "chunk_size": 300}`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfig(path); err == nil {
+		t.Error("config with sections and chunks loaded")
+	}
+}
@@ -0,0 +1,546 @@
+package sego
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// A segmented index is a directory of immutable segments, each an index in the
+// usual JSON format, listed oldest first in segments.json. Live changes go to
+// a small in-memory segment that is flushed as a new one, and small segments
+// are merged in the background, so updating a large index never rewrites all
+// of it. A document added or deleted in a segment hides its copies in older
+// segments until they are merged.
+
+const segmentManifestName = "segments.json"
+
+type segmentInfo struct {
+	Name string `json:"name"`
+	Docs int    `json:"docs"`
+	// paths of documents this segment replaces or deletes in older ones
+	Removes []string `json:"removes,omitempty"`
+}
+
+type segmentManifest struct {
+	Segments []segmentInfo `json:"segments"`
+	// number of the last segment file written
+	Last int `json:"last"`
+}
+
+// segment is an open segment, the model of a flushed one never changes
+type segment struct {
+	segmentInfo
+	model   *Model
+	removes map[string]bool
+}
+
+func isSegmented(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+func readManifest(dir string) (*segmentManifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, segmentManifestName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return &segmentManifest{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var manifest segmentManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("%s: %w", dir, err)
+	}
+	return &manifest, nil
+}
+
+// writeManifest replaces the manifest in one step, so readers see either the
+// old or the new list of segments
+func writeManifest(dir string, manifest *segmentManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(dir, segmentManifestName+".tmp")
+	if err := os.WriteFile(tmp, data, 0666); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, segmentManifestName))
+}
+
+func (manifest *segmentManifest) nextName() string {
+	manifest.Last++
+	return fmt.Sprintf("%06d.json", manifest.Last)
+}
+
+func openSegments(dir string) (*segmentManifest, []*segment, error) {
+	manifest, err := readManifest(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	segments := make([]*segment, 0, len(manifest.Segments))
+	for _, info := range manifest.Segments {
+		model, err := newModelFromJson(filepath.Join(dir, info.Name))
+		if err != nil {
+			return nil, nil, err
+		}
+		segments = append(segments, newSegment(info, model))
+	}
+	return manifest, segments, nil
+}
+
+func newSegment(info segmentInfo, model *Model) *segment {
+	seg := &segment{segmentInfo: info, model: model, removes: make(map[string]bool)}
+	for _, path := range info.Removes {
+		seg.removes[path] = true
+	}
+	return seg
+}
+
+// writeSegment saves a segment under its name, it isn't part of the index
+// before it is added to the manifest
+func writeSegment(dir string, seg *segment) error {
+	seg.Docs = len(seg.model.docIDs())
+	seg.Removes = make([]string, 0, len(seg.removes))
+	for path := range seg.removes {
+		seg.Removes = append(seg.Removes, path)
+	}
+	sort.Strings(seg.Removes)
+	return seg.model.saveAsJson(filepath.Join(dir, seg.Name))
+}
+
+// loadSegmented opens a segmented index as one model
+func loadSegmented(dir string) (*Model, error) {
+	_, segments, err := openSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) == 1 {
+		return segments[0].model, nil
+	}
+	return mergeSegments(segments), nil
+}
+
+// saveSegmented replaces the segments of the index in dir with m
+func (m *Model) saveSegmented(dir string) error {
+	manifest, err := readManifest(dir)
+	if err != nil {
+		return err
+	}
+	old := manifest.Segments
+	seg := newSegment(segmentInfo{Name: manifest.nextName()}, m)
+	if err := writeSegment(dir, seg); err != nil {
+		return err
+	}
+	manifest.Segments = []segmentInfo{seg.segmentInfo}
+	if err := writeManifest(dir, manifest); err != nil {
+		return err
+	}
+	removeSegmentFiles(dir, old)
+	return nil
+}
+
+func removeSegmentFiles(dir string, segments []segmentInfo) {
+	for _, info := range segments {
+		if err := os.Remove(filepath.Join(dir, info.Name)); err != nil {
+			log.Printf("Removing segment: %s", err)
+		}
+	}
+}
+
+// mergeSegments combines segments, oldest first, into one model without the
+// documents a newer one of them replaced or deleted
+func mergeSegments(segments []*segment) *Model {
+	merged := newModel()
+	if len(segments) == 0 {
+		return merged
+	}
+	newest := segments[len(segments)-1].model
+	merged.Lexer = newest.Lexer
+	merged.Analysis = newest.Analysis
+	merged.Analyzers = newest.Analyzers
+	merged.AnalyzerHash = newest.AnalyzerHash
+	merged.Code = newest.Code
+	merged.Plugins = newest.Plugins
+	merged.IDF = newest.IDF
+	merged.StopwordFraction = newest.StopwordFraction
+	merged.Embedding = newest.Embedding
+	merged.NGrams = newest.NGrams
+	merged.VersionPattern, merged.versionRe = newest.VersionPattern, newest.versionRe
+	merged.Boosts = newest.Boosts
+	merged.AnchorBoost = newest.AnchorBoost
+	merged.AttrBoost = newest.AttrBoost
+	merged.Sections = newest.Sections
+	merged.ChunkSize = newest.ChunkSize
+	merged.PageRankWeight = newest.PageRankWeight
+	merged.Quality = newest.Quality
+
+	removed := make(map[string]bool)
+	for i := len(segments) - 1; i >= 0; i-- {
+		from := segments[i].model
+		merged.Pruned = merged.Pruned || from.Pruned
+		for _, lang := range from.Languages {
+			merged.noteLanguage(lang)
+		}
+		for _, id := range from.docIDs() {
+			if path := from.docPath(id); !removed[path] {
+				merged.copyDocument(from, id)
+			}
+		}
+		for url, anchors := range from.Anchors {
+			if merged.Anchors == nil {
+				merged.Anchors = make(map[string]TermFreq)
+			}
+			if _, ok := merged.Anchors[url]; !ok {
+				merged.Anchors[url] = anchors
+			}
+		}
+		for url, links := range from.Links {
+			if merged.Links == nil {
+				merged.Links = make(map[string][]link)
+			}
+			if _, ok := merged.Links[url]; !ok {
+				merged.Links[url] = links
+			}
+		}
+		for url, rank := range from.PageRank {
+			if merged.PageRank == nil {
+				merged.PageRank = make(map[string]float64)
+			}
+			if _, ok := merged.PageRank[url]; !ok {
+				merged.PageRank[url] = rank
+			}
+		}
+		for path := range segments[i].removes {
+			removed[path] = true
+		}
+		for path := range from.paths {
+			removed[path] = true
+		}
+	}
+	merged.indexVectors()
+	return merged
+}
+
+// copyDocument adds the document with id in from under a new ID, its term
+// table is shared since segments never change
+func (m *Model) copyDocument(from *Model, id string) {
+	newID := m.assignID(from.docPath(id))
+	tf := from.TF[id]
+	for term := range tf {
+		m.DF[term]++
+	}
+	m.TF[newID] = tf
+	if doc, ok := from.Docs[id]; ok {
+		copied := *doc
+		m.Docs[newID] = &copied
+	}
+	if vector, ok := from.Vectors[id]; ok {
+		if m.Vectors == nil {
+			m.Vectors = make(map[string][]float32)
+		}
+		m.Vectors[newID] = vector
+	}
+	if attrs, ok := from.AttrText[id]; ok {
+		if m.AttrText == nil {
+			m.AttrText = make(map[string]TermFreq)
+		}
+		m.AttrText[newID] = attrs
+	}
+	if m.NGrams != nil {
+		m.indexGrams(newID, tf)
+	}
+}
+
+// corpusStats are the document frequencies and count of a whole segmented
+// index, segments rank their documents by them so ranks compare across them.
+// Documents hidden by a newer segment count until they are merged away.
+type corpusStats struct {
+	docs     int
+	segments []*Model
+}
+
+// size is how many documents IDF is computed over, n those of the model
+func (c *corpusStats) size(n int) int {
+	if c == nil {
+		return n
+	}
+	return c.docs
+}
+
+func (m *Model) docFreq(term string) int {
+	if m.corpus == nil {
+		return m.DF[term]
+	}
+	df := 0
+	for _, seg := range m.corpus.segments {
+		df += seg.DF[term]
+	}
+	return df
+}
+
+func (m *Model) gramDocFreq(gram string) int {
+	if m.corpus == nil {
+		return m.GramDF[gram]
+	}
+	df := 0
+	for _, seg := range m.corpus.segments {
+		df += seg.GramDF[gram]
+	}
+	return df
+}
+
+// segmentSet is a snapshot of the flushed segments of an index
+type segmentSet struct {
+	segments []*segment
+	// paths of the documents of each segment a newer one replaced or deleted
+	hidden []map[string]bool
+}
+
+func newSegmentSet(segments []*segment) *segmentSet {
+	set := &segmentSet{segments: segments, hidden: make([]map[string]bool, len(segments))}
+	removed := make(map[string]bool)
+	for i := len(segments) - 1; i >= 0; i-- {
+		set.hidden[i] = removed
+		if len(segments[i].removes) > 0 {
+			newer := removed
+			removed = make(map[string]bool, len(newer)+len(segments[i].removes))
+			for path := range newer {
+				removed[path] = true
+			}
+			for path := range segments[i].removes {
+				removed[path] = true
+			}
+		}
+	}
+	return set
+}
+
+// search ranks the documents of every segment and of buffer, the segment
+// being written, by the statistics of all of them and returns the k best, all
+// if k is 0, and how many matched
+func (set *segmentSet) search(query string, buffer *segment, threads, k int) (SearchResults, int, *SearchTiming) {
+	segments := set.segments
+	if buffer != nil {
+		segments = append(segments[:len(segments):len(segments)], buffer)
+	}
+	corpus := &corpusStats{}
+	for _, seg := range segments {
+		corpus.segments = append(corpus.segments, seg.model)
+		corpus.docs += len(seg.model.TF) - len(seg.model.Deleted)
+	}
+
+	readers := make([]*Model, len(segments))
+	for i, seg := range segments {
+		reader := *seg.model
+		reader.corpus = corpus
+		// segments are already searched side by side
+		reader.threads = 1
+		readers[i] = &reader
+	}
+	hidden := func(i int, path string) bool {
+		return i < len(set.segments) && (set.hidden[i][path] || buffer != nil && buffer.removes[path])
+	}
+	return searchSegments(readers, query, hidden, threads, k)
+}
+
+// segmentWriter applies changes to a segmented index
+type segmentWriter struct {
+	dir    string
+	config *Config
+	// held for writing while the segments or the buffer change
+	mu       *sync.RWMutex
+	manifest *segmentManifest
+	set      *segmentSet
+	// the in-memory segment changes go to until it is flushed
+	buffer *segment
+	// segments over this many are merged in the background
+	maxSegments int
+	merging     bool
+	// goroutines searches use, 0 means GOMAXPROCS
+	threads int
+}
+
+func openSegmentWriter(dir string, config *Config, maxSegments int) (*segmentWriter, error) {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, err
+	}
+	manifest, segments, err := openSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+	w := &segmentWriter{
+		dir:         dir,
+		config:      config,
+		mu:          &sync.RWMutex{},
+		manifest:    manifest,
+		set:         newSegmentSet(segments),
+		maxSegments: maxSegments,
+	}
+	w.newBuffer()
+	return w, nil
+}
+
+// newBuffer starts an empty in-memory segment indexed like the newest one
+func (w *segmentWriter) newBuffer() {
+	model := newModel()
+	if n := len(w.set.segments); n > 0 {
+		newest := w.set.segments[n-1].model
+		model.Lexer = newest.Lexer
+		model.Analysis = newest.Analysis
+		model.Plugins = newest.Plugins
+		model.IDF = newest.IDF
+		model.StopwordFraction = newest.StopwordFraction
+		model.NGrams = newest.NGrams
+		model.VersionPattern, model.versionRe = newest.VersionPattern, newest.versionRe
+	}
+	w.buffer = newSegment(segmentInfo{}, model)
+}
+
+// apply adds or deletes the document of a message in the buffer, hiding what
+// older segments have at its path. The caller holds mu for writing.
+func (w *segmentWriter) apply(msg *IngestMessage) error {
+	if err := w.buffer.model.apply(msg, w.config); err != nil {
+		return err
+	}
+	w.buffer.removes[msg.Path] = true
+	return nil
+}
+
+func (w *segmentWriter) search(query string, k int) (SearchResults, int, *SearchTiming) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.set.search(query, w.buffer, w.threads, k)
+}
+
+// flush writes the buffer as the newest segment and returns its name, "" if
+// there was nothing to write. The caller holds mu for writing, searches wait
+// meanwhile which is quick for a buffer of a few minutes of changes.
+func (w *segmentWriter) flush() (string, error) {
+	if len(w.buffer.removes) == 0 {
+		return "", nil
+	}
+	seg := w.buffer
+	seg.model.compact()
+	seg.Name = w.manifest.nextName()
+	if err := writeSegment(w.dir, seg); err != nil {
+		return "", err
+	}
+	segments := append(w.set.segments[:len(w.set.segments):len(w.set.segments)], seg)
+	if err := w.commit(segments); err != nil {
+		return "", err
+	}
+	w.newBuffer()
+	if len(segments) > w.maxSegments && !w.merging {
+		w.merging = true
+		go w.merge()
+	}
+	return seg.Name, nil
+}
+
+// commit makes segments the index. The caller holds mu for writing.
+func (w *segmentWriter) commit(segments []*segment) error {
+	infos := make([]segmentInfo, len(segments))
+	for i, seg := range segments {
+		infos[i] = seg.segmentInfo
+	}
+	w.manifest.Segments = infos
+	if err := writeManifest(w.dir, w.manifest); err != nil {
+		return err
+	}
+	w.set = newSegmentSet(segments)
+	return nil
+}
+
+// merge combines the two adjacent segments with the fewest documents while
+// there are too many, searches go on meanwhile
+func (w *segmentWriter) merge() {
+	for {
+		w.mu.Lock()
+		segments := w.set.segments
+		if len(segments) <= w.maxSegments {
+			w.merging = false
+			w.mu.Unlock()
+			return
+		}
+		i := 0
+		for j := 1; j+1 < len(segments); j++ {
+			if segments[j].Docs+segments[j+1].Docs < segments[i].Docs+segments[i+1].Docs {
+				i = j
+			}
+		}
+		name := w.manifest.nextName()
+		w.mu.Unlock()
+
+		pair := segments[i : i+2]
+		merged := newSegment(segmentInfo{Name: name}, mergeSegments(pair))
+		// nothing is older than the first segment to hide
+		if i > 0 {
+			for _, seg := range pair {
+				for path := range seg.removes {
+					merged.removes[path] = true
+				}
+			}
+		}
+		start := time.Now()
+		if err := writeSegment(w.dir, merged); err != nil {
+			log.Printf("Merging segments: %s", err)
+			w.mu.Lock()
+			w.merging = false
+			w.mu.Unlock()
+			return
+		}
+
+		w.mu.Lock()
+		// flushes only append, so the pair is still at i
+		segments = w.set.segments
+		replaced := make([]*segment, 0, len(segments)-1)
+		replaced = append(replaced, segments[:i]...)
+		replaced = append(replaced, merged)
+		replaced = append(replaced, segments[i+2:]...)
+		err := w.commit(replaced)
+		w.mu.Unlock()
+		if err != nil {
+			log.Printf("Merging segments: %s", err)
+			removeSegmentFiles(w.dir, []segmentInfo{merged.segmentInfo})
+			w.mu.Lock()
+			w.merging = false
+			w.mu.Unlock()
+			return
+		}
+		removeSegmentFiles(w.dir, []segmentInfo{pair[0].segmentInfo, pair[1].segmentInfo})
+		log.Printf("Merged segments %s and %s into %s with %d documents in %s",
+			pair[0].Name, pair[1].Name, name, merged.Docs, time.Since(start))
+	}
+}
+
+func (w *segmentWriter) handleSearch(rw http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	limit := 10
+	if n := params.Get("n"); n != "" {
+		var err error
+		if limit, err = strconv.Atoi(n); err != nil || limit < 0 {
+			httpError(rw, http.StatusBadRequest, fmt.Errorf("invalid n %q", n))
+			return
+		}
+	}
+	results, total, timing := w.search(params.Get("q"), limit)
+	if limit == 0 {
+		results = results[:0]
+	}
+	response := searchResponse{Query: params.Get("q"), Total: total, Results: results}
+	if params.Get("timing") != "" {
+		response.Timing = timing
+	}
+	writeJson(rw, http.StatusOK, response)
+}
@@ -0,0 +1,263 @@
+package sego
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	xhtml "golang.org/x/net/html"
+)
+
+// selector is a group of CSS selectors like "main .entry, article > p",
+// supporting type, universal, ID, class and attribute selectors and the
+// descendant and child combinators
+type selector []complexSelector
+
+// complexSelector is compound selectors joined by combinators, last one
+// matching the element itself
+type complexSelector []compoundSelector
+
+type compoundSelector struct {
+	tag     string
+	id      string
+	classes []string
+	attrs   []attrSelector
+	// whether the element must be a child of the one the previous compound
+	// selector matches rather than any descendant
+	child bool
+}
+
+type attrSelector struct {
+	name string
+	// "" for presence, or one of = ~= ^= $= *=
+	op    string
+	value string
+}
+
+// the selectors parsed so far, by their text
+var selectorCache sync.Map
+
+// cachedSelector parses s once for all the pages it is used on
+func cachedSelector(s string) (selector, error) {
+	if sel, ok := selectorCache.Load(s); ok {
+		return sel.(selector), nil
+	}
+	sel, err := parseSelector(s)
+	if err != nil {
+		return nil, err
+	}
+	selectorCache.Store(s, sel)
+	return sel, nil
+}
+
+func parseSelector(s string) (selector, error) {
+	var group selector
+	for _, part := range splitSelector(s, ",") {
+		complex, err := parseComplexSelector(part)
+		if err != nil {
+			return nil, fmt.Errorf("selector %q: %w", s, err)
+		}
+		group = append(group, complex)
+	}
+	return group, nil
+}
+
+// splitSelector splits s at the separators outside attribute selectors,
+// whose values may contain them. " " splits at any whitespace, ">" keeps the
+// separators.
+func splitSelector(s string, separator string) []string {
+	parts := make([]string, 0)
+	start, depth := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '[':
+			depth++
+		case c == ']' && depth > 0:
+			depth--
+		case depth > 0:
+		case separator == " " && (c == ' ' || c == '\t' || c == '\n'):
+			parts = append(parts, s[start:i])
+			start = i + 1
+		case separator == ">" && c == '>':
+			parts = append(parts, s[start:i], ">")
+			start = i + 1
+		case separator == "," && c == ',':
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func parseComplexSelector(s string) (complexSelector, error) {
+	words := make([]string, 0)
+	for _, part := range splitSelector(s, " ") {
+		for _, word := range splitSelector(part, ">") {
+			if word != "" {
+				words = append(words, word)
+			}
+		}
+	}
+	var complex complexSelector
+	child := false
+	for _, word := range words {
+		if word == ">" {
+			if len(complex) == 0 || child {
+				return nil, fmt.Errorf("misplaced >")
+			}
+			child = true
+			continue
+		}
+		compound, err := parseCompoundSelector(word)
+		if err != nil {
+			return nil, err
+		}
+		compound.child = child
+		child = false
+		complex = append(complex, compound)
+	}
+	if len(complex) == 0 || child {
+		return nil, fmt.Errorf("empty selector")
+	}
+	return complex, nil
+}
+
+func parseCompoundSelector(s string) (compoundSelector, error) {
+	var c compoundSelector
+	name := func(s string) (string, string) {
+		n := strings.IndexAny(s, ".#[")
+		if n < 0 {
+			n = len(s)
+		}
+		return s[:n], s[n:]
+	}
+	c.tag, s = name(s)
+	c.tag = strings.ToLower(c.tag)
+	if c.tag == "*" {
+		c.tag = ""
+	}
+	for s != "" {
+		var value string
+		switch s[0] {
+		case '#':
+			if value, s = name(s[1:]); value == "" {
+				return c, fmt.Errorf("empty ID")
+			}
+			c.id = value
+		case '.':
+			if value, s = name(s[1:]); value == "" {
+				return c, fmt.Errorf("empty class")
+			}
+			c.classes = append(c.classes, value)
+		case '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return c, fmt.Errorf("unclosed [")
+			}
+			attr, err := parseAttrSelector(s[1:end])
+			if err != nil {
+				return c, err
+			}
+			c.attrs = append(c.attrs, attr)
+			s = s[end+1:]
+		default:
+			return c, fmt.Errorf("unexpected %q", s)
+		}
+	}
+	return c, nil
+}
+
+func parseAttrSelector(s string) (attrSelector, error) {
+	for _, op := range []string{"~=", "^=", "$=", "*=", "="} {
+		if name, value, ok := strings.Cut(s, op); ok {
+			value = strings.Trim(value, `"'`)
+			return attrSelector{name: strings.ToLower(strings.TrimSpace(name)), op: op, value: value}, nil
+		}
+	}
+	if s = strings.TrimSpace(s); s == "" {
+		return attrSelector{}, fmt.Errorf("empty attribute")
+	}
+	return attrSelector{name: strings.ToLower(s)}, nil
+}
+
+func (sel selector) matches(n *xhtml.Node) bool {
+	for _, complex := range sel {
+		if complex.matches(n, len(complex)-1) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether n matches complex up to its i-th compound selector
+func (complex complexSelector) matches(n *xhtml.Node, i int) bool {
+	if !complex[i].matches(n) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+	for parent := n.Parent; parent != nil && parent.Type == xhtml.ElementNode; parent = parent.Parent {
+		if complex.matches(parent, i-1) {
+			return true
+		}
+		if complex[i].child {
+			return false
+		}
+	}
+	return false
+}
+
+func (c compoundSelector) matches(n *xhtml.Node) bool {
+	if n.Type != xhtml.ElementNode || (c.tag != "" && n.Data != c.tag) {
+		return false
+	}
+	if c.id != "" && attr(n, "id") != c.id {
+		return false
+	}
+	classes := strings.Fields(attr(n, "class"))
+	for _, class := range c.classes {
+		if !contains(classes, class) {
+			return false
+		}
+	}
+	for _, a := range c.attrs {
+		if !a.matches(n) {
+			return false
+		}
+	}
+	return true
+}
+
+func (a attrSelector) matches(n *xhtml.Node) bool {
+	for _, attr := range n.Attr {
+		if attr.Key != a.name {
+			continue
+		}
+		switch a.op {
+		case "":
+			return true
+		case "=":
+			return attr.Val == a.value
+		case "~=":
+			return contains(strings.Fields(attr.Val), a.value)
+		case "^=":
+			return a.value != "" && strings.HasPrefix(attr.Val, a.value)
+		case "$=":
+			return a.value != "" && strings.HasSuffix(attr.Val, a.value)
+		case "*=":
+			return a.value != "" && strings.Contains(attr.Val, a.value)
+		}
+	}
+	return false
+}
+
+// attr is the value of the attribute key of n, "" if it has none
+func attr(n *xhtml.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
@@ -0,0 +1,68 @@
+package sego
+
+import (
+	"bytes"
+	"testing"
+
+	xhtml "golang.org/x/net/html"
+)
+
+// firstMatch is the id of the first element of page sel matches, "" if none
+// does
+func firstMatch(t *testing.T, page, sel string) string {
+	t.Helper()
+	parsed, err := parseSelector(sel)
+	if err != nil {
+		t.Fatalf("%q: %s", sel, err)
+	}
+	doc, err := xhtml.Parse(bytes.NewReader([]byte(page)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found string
+	var walk func(n *xhtml.Node)
+	walk = func(n *xhtml.Node) {
+		if found == "" && parsed.matches(n) {
+			found = attr(n, "id")
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(doc)
+	return found
+}
+
+func TestSelectors(t *testing.T) {
+	page := `<main id="main"><div id="outer" class="entry wide"><p id="p1">a</p>
+		<span><p id="p2" title="a > b, c">b</p></span></div>
+		<a id="link" href="https://x.org/?q=1">x</a></main>`
+	tests := []struct {
+		selector string
+		want     string
+	}{
+		{"main", "main"},
+		{".entry.wide", "outer"},
+		{"#p2", "p2"},
+		{"div > p", "p1"},
+		{"div>span>p", "p2"},
+		{"main > p", ""},
+		{`[title="a > b, c"]`, "p2"},
+		{`p[title="a > b, c"], .missing`, "p2"},
+		{`.missing, a[href^="https://x.org"]`, "link"},
+		{"span p[title*=b]", "p2"},
+	}
+	for _, test := range tests {
+		if got := firstMatch(t, page, test.selector); got != test.want {
+			t.Errorf("%q matches %q, want %q", test.selector, got, test.want)
+		}
+	}
+}
+
+func TestInvalidSelectors(t *testing.T) {
+	for _, s := range []string{"", "> p", "p >", "p > > a", "p,", "[a", "p.", "#", "p[]"} {
+		if _, err := parseSelector(s); err == nil {
+			t.Errorf("%q parsed", s)
+		}
+	}
+}
@@ -0,0 +1,352 @@
+package sego
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/pprof"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type server struct {
+	model *Model
+	// rank multipliers to use instead of those of the served index
+	boosts map[string]float64
+	// documents shown first for some queries
+	pins Pins
+	// documents hidden from results
+	blocklist *blocklistFile
+	// goroutines a search scores with, 0 means GOMAXPROCS
+	threads int
+	// most results a JSON search returns however it pages, 0 means no limit
+	maxResults int
+	// how the documents were indexed, to extract their text again for
+	// highlights
+	config *Config
+	// held for reading while searching when the model is updated live
+	mu *sync.RWMutex
+	// set when the model changes in place rather than being swapped, so
+	// searches can't let go of mu before they are done with it
+	live bool
+
+	cacheMu sync.Mutex
+	cache   *snapshotCache
+
+	// scrolls through results of the model they started on
+	cursors cursorStore
+
+	// set on shutdown so /readyz fails while running requests finish
+	draining atomic.Bool
+}
+
+type searchResponse struct {
+	Query   string        `json:"query"`
+	Total   int           `json:"total"`
+	Results SearchResults `json:"results"`
+	Timing  *SearchTiming `json:"timing,omitempty"`
+	// the top results grouped by similarity, when asked for
+	Clusters []Cluster `json:"clusters,omitempty"`
+	// passed as cursor for the next page of a scroll, empty on the last one
+	Cursor string `json:"cursor,omitempty"`
+}
+
+func writeJson(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Writing response: %s", err)
+	}
+}
+
+func httpError(w http.ResponseWriter, status int, err error) {
+	writeJson(w, status, map[string]string{"error": err.Error()})
+}
+
+func (s *server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		s.handleSearchJSON(w, r)
+		return
+	}
+	if r.URL.Query().Get("cursor") != "" {
+		s.handleScroll(w, r)
+		return
+	}
+	if wantsStream(r) {
+		s.handleSearchStream(w, r)
+		return
+	}
+	span := tracing.startRemote(r.Header.Get("traceparent"), "GET /search")
+	defer span.finish()
+	params := r.URL.Query()
+	query := params.Get("q")
+	limit := 10
+	if n := params.Get("n"); n != "" {
+		var err error
+		if limit, err = strconv.Atoi(n); err != nil || limit < 0 {
+			httpError(w, http.StatusBadRequest, fmt.Errorf("invalid n %q", n))
+			return
+		}
+	}
+
+	minRank := 0.0
+	if min := params.Get("min"); min != "" {
+		var err error
+		if minRank, err = strconv.ParseFloat(min, 64); err != nil {
+			httpError(w, http.StatusBadRequest, fmt.Errorf("invalid min %q", min))
+			return
+		}
+	}
+
+	clusters := 0
+	if k := params.Get("clusters"); k != "" {
+		var err error
+		if clusters, err = strconv.Atoi(k); err != nil || clusters < 0 {
+			httpError(w, http.StatusBadRequest, fmt.Errorf("invalid clusters %q", k))
+			return
+		}
+	}
+
+	fields, err := parseFields(params.Get("fields"))
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	scroll, err := parseScroll(params.Get("scroll"))
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err)
+		return
+	}
+	if scroll > 0 && limit == 0 {
+		httpError(w, http.StatusBadRequest, fmt.Errorf("scrolling needs n of at least 1"))
+		return
+	}
+
+	prf := 0
+	if k := params.Get("prf"); k != "" {
+		var err error
+		if prf, err = strconv.Atoi(k); err != nil || prf < 0 {
+			httpError(w, http.StatusBadRequest, fmt.Errorf("invalid prf %q", k))
+			return
+		}
+	}
+
+	// embedding the query may take a while, the index isn't locked meanwhile
+	semantic := 0.0
+	var queryVector []float32
+	if weight := params.Get("semantic"); weight != "" {
+		if semantic, err = strconv.ParseFloat(weight, 64); err != nil || semantic < 0 || semantic > 1 {
+			httpError(w, http.StatusBadRequest, fmt.Errorf("invalid semantic %q", weight))
+			return
+		}
+		s.mu.RLock()
+		embedding := s.model.Embedding
+		s.mu.RUnlock()
+		if queryVector, err = embedding.embedQuery(query); err != nil {
+			httpError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if params.Get("phonetic") != "" {
+		query = s.model.phoneticQuery(query)
+	}
+	searchStart := time.Now()
+	results, timing := s.model.searchTimed(query)
+	if prf > 0 {
+		query = s.model.expandQuery(query, results, prf, 10)
+		searchStart = time.Now()
+		results, timing = s.model.searchTimed(query)
+	}
+	traceSearch(span, query, searchStart, timing, len(results))
+	results = s.model.within(results, params["within"])
+	if err := s.model.normalizeRanks(results, query, params.Get("normalize")); err != nil {
+		httpError(w, http.StatusBadRequest, err)
+		return
+	}
+	if queryVector != nil {
+		s.model.hybridRank(results, queryVector, semantic)
+	}
+	if minRank != 0 {
+		results = results.cutoff(minRank)
+	}
+	if params.Get("expand_versions") == "" {
+		results = s.model.collapseVersions(results)
+	}
+	results, err = results.group(params.Get("group_by"))
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err)
+		return
+	}
+	if by := params.Get("diversify"); by != "" {
+		maxPer := 2
+		if n := params.Get("max_per"); n != "" {
+			if maxPer, err = strconv.Atoi(n); err != nil || maxPer < 1 {
+				httpError(w, http.StatusBadRequest, fmt.Errorf("invalid max_per %q", n))
+				return
+			}
+		}
+		if results, err = results.diversify(by, maxPer); err != nil {
+			httpError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	results = s.model.pin(results, s.pins.match(params.Get("q")))
+	results = s.blocklist.get().filter(results)
+	response := searchResponse{
+		Query: query,
+		Total: len(results),
+	}
+	if len(results) > limit {
+		if scroll > 0 {
+			response.Cursor, err = s.cursors.open(&cursor{
+				model:   s.model,
+				query:   query,
+				total:   len(results),
+				results: newCursorResults(results[limit:]),
+				size:    limit,
+				fields:  fields,
+				ttl:     scroll,
+			})
+			if err != nil {
+				httpError(w, http.StatusServiceUnavailable, err)
+				return
+			}
+		}
+		results = results[:limit]
+	}
+	response.Results = results
+	if wantsSnippets(fields) {
+		s.model.snippets(results, query, s.config)
+	}
+	if clusters > 0 {
+		response.Clusters = s.model.cluster(results, clusters)
+	}
+
+	if params.Get("timing") != "" {
+		response.Timing = timing
+		w.Header().Set("Server-Timing", fmt.Sprintf(
+			"tokenize;dur=%.3f, candidates;dur=%.3f, score;dur=%.3f, sort;dur=%.3f",
+			ms(timing.Tokenize), ms(timing.Candidates), ms(timing.Score), ms(timing.Sort)))
+	}
+
+	writeJson(w, http.StatusOK, response.respond(fields))
+}
+
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	indexPath := fs.String("index", "index-new.json", "index to serve")
+	addr := fs.String("addr", ":8080", "address to listen on")
+	enablePprof := fs.Bool("pprof", false, "expose profiles under /debug/pprof/")
+	primary := fs.String("replica-of", "", "serve a read-only copy of the index of this sego server")
+	pullEvery := fs.Duration("pull", 30*time.Second, "how often a replica checks the primary for a new index")
+	tenantsPath := fs.String("tenants", "", "JSON file of tenants with their API keys, indexes and rate limits to serve instead of -index")
+	boostsPath := fs.String("boosts", "", "JSON file of paths and rank multipliers to use instead of the index's")
+	pinsPath := fs.String("pins", "", "JSON file of query patterns and the paths to show first for them")
+	blockPath := fs.String("blocklist", "", "file of path or URL patterns to hide from results, read again when it changes")
+	lameDuck := fs.Duration("lame-duck", 5*time.Second, "how long to keep serving while reporting not ready on shutdown")
+	threads := fs.Int("query-threads", 0, "goroutines to score a query with (default GOMAXPROCS)")
+	strictAnalyzer := fs.Bool("strict-analyzer", false, "refuse to serve an index whose analyzers differ from those it was built with instead of warning")
+	config := newConfig()
+	fs.Func("config", "config file the index was built with, to extract documents for highlights like indexing did", config.load)
+	fs.Parse(args)
+
+	if *tenantsPath != "" {
+		router, err := loadTenants(*tenantsPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		mux := http.NewServeMux()
+		mux.HandleFunc("/search", router.handleSearch)
+		log.Printf("Serving %d tenants on %s", len(router.byKey), *addr)
+		log.Fatal(http.ListenAndServe(*addr, mux))
+	}
+
+	s := &server{mu: &sync.RWMutex{}, threads: *threads, config: config}
+	if *boostsPath != "" {
+		boosts, err := loadBoosts(*boostsPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		s.boosts = boosts
+	}
+	if *pinsPath != "" {
+		pins, err := loadPins(*pinsPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		s.pins = pins
+	}
+	if *blockPath != "" {
+		if _, err := loadBlocklist(*blockPath); err != nil {
+			log.Fatal(err)
+		}
+		s.blocklist = &blocklistFile{path: *blockPath}
+	}
+	// listen right away so liveness probes pass while a large index loads
+	go func() {
+		if *primary != "" {
+			etag, err := s.pull(&http.Client{Timeout: 5 * time.Minute}, *primary, "")
+			if err != nil {
+				log.Fatal(err)
+			}
+			go s.replicate(*primary, *pullEvery, etag)
+			return
+		}
+		model, err := newModelFromJson(*indexPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := model.checkAnalyzer(*strictAnalyzer); err != nil {
+			log.Fatal(err)
+		}
+		s.swap(model)
+		log.Printf("Loaded %s", *indexPath)
+	}()
+
+	if *primary != "" {
+		*indexPath = *primary
+	}
+	log.Printf("Serving %s on %s", *indexPath, *addr)
+	if err := s.listen(*addr, s.routes(*enablePprof), *lameDuck); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func (s *server) routes(enablePprof bool) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/search", s.loaded(s.handleSearch))
+	mux.HandleFunc("/search/batch", s.loaded(s.handleSearchBatch))
+	mux.HandleFunc("/snapshot", s.loaded(s.handleSnapshot))
+	mux.HandleFunc("/livez", s.handleLivez)
+	mux.HandleFunc("/readyz", s.handleReadyz)
+	if enablePprof {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+	return mux
+}
+
+// swap replaces the served model, searches running on the old one finish first
+func (s *server) swap(model *Model) {
+	if s.boosts != nil {
+		model.Boosts = s.boosts
+	}
+	model.threads = s.threads
+	s.mu.Lock()
+	s.model = model
+	s.mu.Unlock()
+	s.cacheMu.Lock()
+	s.cache = nil
+	s.cacheMu.Unlock()
+}
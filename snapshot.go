@@ -0,0 +1,231 @@
+package sego
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Snapshot writes a gzipped tar with the index as index.json, config as
+// config.json if given and, with docs, the documents that are files on disk
+// under docs/
+func (m *Model) Snapshot(w io.Writer, config []byte, docs bool) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+	add := func(name string, data []byte) error {
+		header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: now}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+
+	index, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err := add("index.json", index); err != nil {
+		return err
+	}
+	if config != nil {
+		if err := add("config.json", config); err != nil {
+			return err
+		}
+	}
+	if docs {
+		for _, p := range m.pagePaths() {
+			info, err := os.Stat(p)
+			if err != nil || !info.Mode().IsRegular() {
+				// crawled pages, messages and the like
+				continue
+			}
+			data, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			if err := add(snapshotName(p), data); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// snapshotName is where a snapshot keeps the file of the document at p
+func snapshotName(p string) string {
+	return path.Join("docs", filepath.ToSlash(p))
+}
+
+// pagePaths are the paths of the documents, of their pages for sections and
+// chunks, sorted
+func (m *Model) pagePaths() []string {
+	paths := make([]string, 0)
+	for _, p := range m.docPaths() {
+		page, _, _ := strings.Cut(p, "#")
+		if len(paths) == 0 || paths[len(paths)-1] != page {
+			paths = append(paths, page)
+		}
+	}
+	return paths
+}
+
+// Restore unpacks a snapshot into dir and loads its index. Documents whose
+// files it has are moved to where they were unpacked.
+func Restore(r io.Reader, dir string) (*Model, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	// unpacked files by their name in the snapshot
+	unpacked := make(map[string]string)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := filepath.FromSlash(header.Name)
+		if !filepath.IsLocal(name) {
+			return nil, fmt.Errorf("snapshot entry %q escapes the target directory", header.Name)
+		}
+		target := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return nil, err
+		}
+		file, err := os.Create(target)
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.Copy(file, tr)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+		if target, err = filepath.Abs(target); err != nil {
+			return nil, err
+		}
+		unpacked[path.Clean(header.Name)] = target
+	}
+
+	indexPath := filepath.Join(dir, "index.json")
+	model, err := newModelFromJson(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	moved := 0
+	for _, p := range model.pagePaths() {
+		if target, ok := unpacked[snapshotName(p)]; ok {
+			moved += model.move(p, target)
+		}
+	}
+	if moved > 0 {
+		if err := model.saveAsJson(indexPath); err != nil {
+			return nil, err
+		}
+	}
+	return model, nil
+}
+
+// loadSnapshot reads just the index of a snapshot
+func loadSnapshot(r io.Reader) (*Model, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("snapshot has no index.json")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Name == "index.json" {
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			return parseModel(data)
+		}
+	}
+}
+
+func runSnapshot(args []string) {
+	fs := flag.NewFlagSet("snapshot", flag.ExitOnError)
+	indexPath := fs.String("index", "index-new.json", "index to snapshot")
+	configPath := fs.String("config", "", "config file to include")
+	docs := fs.Bool("docs", false, "include the indexed files")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		log.Fatal("usage: sego snapshot [flags] <snapshot.tar.gz>")
+	}
+
+	model, err := newModelFromJson(*indexPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var config []byte
+	if *configPath != "" {
+		if config, err = os.ReadFile(*configPath); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	file, err := os.Create(fs.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := model.Snapshot(file, config, *docs); err != nil {
+		log.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Wrote %s", fs.Arg(0))
+}
+
+func runRestore(args []string) {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	dir := fs.String("o", ".", "directory to restore into")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		log.Fatal("usage: sego restore [-o dir] <snapshot.tar.gz>")
+	}
+
+	file, err := os.Open(fs.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+	model, err := Restore(file, *dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Restored %d documents into %s", len(model.docPaths()), *dir)
+}
@@ -0,0 +1,48 @@
+package sego
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRestoreDocuments(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "guide.md")
+	if err := os.WriteFile(src, []byte("shader guide"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	config := newConfig()
+	m := newModel()
+	if err := m.apply(&IngestMessage{Path: src, Content: "shader guide"}, config); err != nil {
+		t.Fatal(err)
+	}
+	var snapshot bytes.Buffer
+	if err := m.Snapshot(&snapshot, nil, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(src); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	restored, err := Restore(&snapshot, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := restored.docPaths()
+	if len(paths) != 1 || !strings.HasPrefix(paths[0], filepath.Join(dir, "docs")) {
+		t.Fatalf("restored documents at %v", paths)
+	}
+	if text := restored.documentText(paths[0], config); text != "shader guide" {
+		t.Errorf("restored document reads %q", text)
+	}
+	reloaded, err := newModelFromJson(filepath.Join(dir, "index.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reloaded.docPaths(); len(got) != 1 || got[0] != paths[0] {
+		t.Errorf("restored index has documents at %v", got)
+	}
+}
@@ -0,0 +1,702 @@
+package sego
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Stemmers for German, French, Spanish and Russian following the Snowball
+// algorithms at snowballstem.org. Words are lower case, regions are byte
+// offsets into them and suffixes are matched longest first.
+
+// stemWord is a word being stemmed with the vowels of its language
+type stemWord struct {
+	s      string
+	vowels string
+}
+
+func (w *stemWord) isVowel(r rune) bool {
+	return strings.ContainsRune(w.vowels, r)
+}
+
+// region is where the region after from starts: after the first non-vowel
+// following a vowel, the end of the word if there is none
+func (w *stemWord) region(from int) int {
+	prevVowel := false
+	for i, r := range w.s[from:] {
+		vowel := w.isVowel(r)
+		if prevVowel && !vowel {
+			return from + i + utf8.RuneLen(r)
+		}
+		prevVowel = vowel
+	}
+	return len(w.s)
+}
+
+// longest is the longest of suffixes the word ends with starting at limit or
+// after, "" if none does
+func (w *stemWord) longest(limit int, suffixes ...string) string {
+	found := ""
+	for _, suffix := range suffixes {
+		if len(suffix) > len(found) && strings.HasSuffix(w.s, suffix) && len(w.s)-len(suffix) >= limit {
+			found = suffix
+		}
+	}
+	return found
+}
+
+// in reports whether suffix, which the word ends with, starts at from or after
+func (w *stemWord) in(suffix string, from int) bool {
+	return len(w.s)-len(suffix) >= from
+}
+
+func (w *stemWord) ends(suffix string) bool {
+	return strings.HasSuffix(w.s, suffix)
+}
+
+// before reports whether the word has prefix right before suffix
+func (w *stemWord) before(suffix, prefix string) bool {
+	return strings.HasSuffix(w.s[:len(w.s)-len(suffix)], prefix)
+}
+
+// runeBefore is the rune right before suffix and where it starts, -1 if none
+func (w *stemWord) runeBefore(suffix string) (rune, int) {
+	rest := w.s[:len(w.s)-len(suffix)]
+	if rest == "" {
+		return 0, -1
+	}
+	r, n := utf8.DecodeLastRuneInString(rest)
+	return r, len(rest) - n
+}
+
+func (w *stemWord) cut(suffix string) {
+	w.s = w.s[:len(w.s)-len(suffix)]
+}
+
+func (w *stemWord) replace(suffix, with string) {
+	w.s = w.s[:len(w.s)-len(suffix)] + with
+}
+
+// runeOffset is the byte offset after the first n runes, the end if shorter
+func runeOffset(s string, n int) int {
+	for i := range s {
+		if n == 0 {
+			return i
+		}
+		n--
+	}
+	return len(s)
+}
+
+// stemCased stems token with stem whatever its case, keeping it
+func stemCased(token string, stem func(string) string) string {
+	lower := strings.ToLower(token)
+	stemmed := stem(lower)
+	if stemmed == lower {
+		return token
+	}
+	if token == strings.ToUpper(token) {
+		return strings.ToUpper(stemmed)
+	}
+	return stemmed
+}
+
+// germanStem stems a German word
+func germanStem(word string) string {
+	w := &stemWord{s: strings.ReplaceAll(word, "ß", "ss"), vowels: "aeiouyäöü"}
+	// u and y between vowels are consonants
+	runes := []rune(w.s)
+	for i := 1; i+1 < len(runes); i++ {
+		if (runes[i] == 'u' || runes[i] == 'y') && w.isVowel(runes[i-1]) && w.isVowel(runes[i+1]) {
+			runes[i] -= 'a' - 'A'
+		}
+	}
+	w.s = string(runes)
+
+	r1 := w.region(0)
+	if min := runeOffset(w.s, 3); r1 < min {
+		r1 = min
+	}
+	r2 := w.region(r1)
+
+	switch suffix := w.longest(0, "em", "ern", "er", "e", "en", "es", "s"); {
+	case suffix == "" || !w.in(suffix, r1):
+	case suffix == "s":
+		if r, _ := w.runeBefore(suffix); strings.ContainsRune("bdfghklmnrt", r) {
+			w.cut(suffix)
+		}
+	case suffix == "e" || suffix == "en" || suffix == "es":
+		w.cut(suffix)
+		if w.ends("niss") {
+			w.cut("s")
+		}
+	default:
+		w.cut(suffix)
+	}
+
+	switch suffix := w.longest(0, "en", "er", "est", "st"); {
+	case suffix == "" || !w.in(suffix, r1):
+	case suffix == "st":
+		if r, at := w.runeBefore(suffix); strings.ContainsRune("bdfghklmnt", r) && utf8.RuneCountInString(w.s[:at]) >= 3 {
+			w.cut(suffix)
+		}
+	default:
+		w.cut(suffix)
+	}
+
+	switch suffix := w.longest(0, "end", "ung", "ig", "ik", "isch", "lich", "heit", "keit"); {
+	case suffix == "" || !w.in(suffix, r2):
+	case suffix == "end" || suffix == "ung":
+		w.cut(suffix)
+		if w.ends("ig") && !w.before("ig", "e") && w.in("ig", r2) {
+			w.cut("ig")
+		}
+	case suffix == "ig" || suffix == "ik" || suffix == "isch":
+		if !w.before(suffix, "e") {
+			w.cut(suffix)
+		}
+	case suffix == "lich" || suffix == "heit":
+		w.cut(suffix)
+		if s := w.longest(r1, "er", "en"); s != "" {
+			w.cut(s)
+		}
+	case suffix == "keit":
+		w.cut(suffix)
+		if s := w.longest(r2, "lich", "ig"); s != "" {
+			w.cut(s)
+		}
+	}
+
+	return strings.NewReplacer("U", "u", "Y", "y", "ä", "a", "ö", "o", "ü", "u").Replace(w.s)
+}
+
+var (
+	frenchStep1 = []string{
+		"ance", "iqUe", "isme", "able", "iste", "eux", "ances", "iqUes", "ismes", "ables", "istes",
+		"atrice", "ateur", "ation", "atrices", "ateurs", "ations",
+		"logie", "logies", "usion", "ution", "usions", "utions", "ence", "ences",
+		"ement", "ements", "ité", "ités", "if", "ive", "ifs", "ives", "eaux", "aux",
+		"euse", "euses", "issement", "issements", "amment", "emment", "ment", "ments",
+	}
+	frenchIVerb = []string{
+		"îmes", "ît", "îtes", "i", "ie", "ies", "ir", "ira", "irai", "iraIent", "irais", "irait",
+		"iras", "irent", "irez", "iriez", "irions", "irons", "iront", "is", "issaIent", "issais",
+		"issait", "issant", "issante", "issantes", "issants", "isse", "issent", "isses", "issez",
+		"issiez", "issions", "issons", "it",
+	}
+	frenchVerb = []string{
+		"ions",
+		"é", "ée", "ées", "és", "èrent", "er", "era", "erai", "eraIent", "erais", "erait", "eras",
+		"erez", "eriez", "erions", "erons", "eront", "ez", "iez",
+		"âmes", "ât", "âtes", "a", "ai", "aIent", "ais", "ait", "ant", "ante", "antes", "ants",
+		"as", "asse", "assent", "asses", "assiez", "assions",
+	}
+	frenchVerbE = strings.Fields("é ée ées és èrent er era erai eraIent erais erait eras erez eriez erions erons eront ez iez")
+)
+
+// frenchStem stems a French word
+func frenchStem(word string) string {
+	w := &stemWord{s: word, vowels: "aeiouyâàëéêèïîôûù"}
+	// u and i between vowels, y next to one and u after q are consonants
+	runes := []rune(w.s)
+	for i, r := range runes {
+		prev := i > 0 && w.isVowel(runes[i-1])
+		next := i+1 < len(runes) && w.isVowel(runes[i+1])
+		switch {
+		case (r == 'u' || r == 'i') && prev && next, r == 'y' && (prev || next), r == 'u' && i > 0 && runes[i-1] == 'q':
+			runes[i] -= 'a' - 'A'
+		}
+	}
+	w.s = string(runes)
+
+	rv := len(w.s)
+	switch {
+	case len(runes) >= 3 && w.isVowel(runes[0]) && w.isVowel(runes[1]):
+		rv = runeOffset(w.s, 3)
+	case strings.HasPrefix(w.s, "par") || strings.HasPrefix(w.s, "col") || strings.HasPrefix(w.s, "tap"):
+		rv = 3
+	default:
+		for i := 1; i < len(runes); i++ {
+			if w.isVowel(runes[i]) {
+				rv = runeOffset(w.s, i+1)
+				break
+			}
+		}
+	}
+	r1 := w.region(0)
+	r2 := w.region(r1)
+
+	done := w.frenchStandard(rv, r1, r2)
+	if !done {
+		done = w.frenchVerb(rv, r2)
+	}
+	if done {
+		if w.ends("Y") {
+			w.replace("Y", "i")
+		} else if w.ends("ç") {
+			w.replace("ç", "c")
+		}
+	} else {
+		w.frenchResidual(rv, r2)
+	}
+
+	if s := w.longest(0, "enn", "onn", "ett", "ell", "eill"); s != "" {
+		_, n := utf8.DecodeLastRuneInString(w.s)
+		w.s = w.s[:len(w.s)-n]
+	}
+	// é or è before the final consonants loses its accent
+	end := len(w.s)
+	for end > 0 {
+		r, n := utf8.DecodeLastRuneInString(w.s[:end])
+		if w.isVowel(r) {
+			break
+		}
+		end -= n
+	}
+	if end < len(w.s) && end > 0 {
+		if r, n := utf8.DecodeLastRuneInString(w.s[:end]); r == 'é' || r == 'è' {
+			w.s = w.s[:end-n] + "e" + w.s[end:]
+		}
+	}
+	return strings.NewReplacer("I", "i", "U", "u", "Y", "y").Replace(w.s)
+}
+
+// frenchStandard removes a standard suffix, reporting whether it did so the
+// verb suffixes are left alone
+func (w *stemWord) frenchStandard(rv, r1, r2 int) bool {
+	suffix := w.longest(0, frenchStep1...)
+	switch suffix {
+	case "":
+		return false
+	case "ance", "iqUe", "isme", "able", "iste", "eux", "ances", "iqUes", "ismes", "ables", "istes":
+		if !w.in(suffix, r2) {
+			return false
+		}
+		w.cut(suffix)
+	case "atrice", "ateur", "ation", "atrices", "ateurs", "ations":
+		if !w.in(suffix, r2) {
+			return false
+		}
+		w.cut(suffix)
+		if w.ends("ic") {
+			if w.in("ic", r2) {
+				w.cut("ic")
+			} else {
+				w.replace("ic", "iqU")
+			}
+		}
+	case "logie", "logies":
+		if !w.in(suffix, r2) {
+			return false
+		}
+		w.replace(suffix, "log")
+	case "usion", "ution", "usions", "utions":
+		if !w.in(suffix, r2) {
+			return false
+		}
+		w.replace(suffix, "u")
+	case "ence", "ences":
+		if !w.in(suffix, r2) {
+			return false
+		}
+		w.replace(suffix, "ent")
+	case "ement", "ements":
+		if !w.in(suffix, rv) {
+			return false
+		}
+		w.cut(suffix)
+		switch s := w.longest(0, "iv", "eus", "abl", "iqU", "ièr", "Ièr"); s {
+		case "iv":
+			if w.in(s, r2) {
+				w.cut(s)
+				if w.ends("at") && w.in("at", r2) {
+					w.cut("at")
+				}
+			}
+		case "eus":
+			if w.in(s, r2) {
+				w.cut(s)
+			} else if w.in(s, r1) {
+				w.replace(s, "eux")
+			}
+		case "abl", "iqU":
+			if w.in(s, r2) {
+				w.cut(s)
+			}
+		case "ièr", "Ièr":
+			if w.in(s, rv) {
+				w.replace(s, "i")
+			}
+		}
+	case "ité", "ités":
+		if !w.in(suffix, r2) {
+			return false
+		}
+		w.cut(suffix)
+		switch s := w.longest(0, "abil", "ic", "iv"); s {
+		case "abil":
+			if w.in(s, r2) {
+				w.cut(s)
+			} else {
+				w.replace(s, "abl")
+			}
+		case "ic":
+			if w.in(s, r2) {
+				w.cut(s)
+			} else {
+				w.replace(s, "iqU")
+			}
+		case "iv":
+			if w.in(s, r2) {
+				w.cut(s)
+			}
+		}
+	case "if", "ive", "ifs", "ives":
+		if !w.in(suffix, r2) {
+			return false
+		}
+		w.cut(suffix)
+		if w.ends("at") && w.in("at", r2) {
+			w.cut("at")
+			if w.ends("ic") {
+				if w.in("ic", r2) {
+					w.cut("ic")
+				} else {
+					w.replace("ic", "iqU")
+				}
+			}
+		}
+	case "eaux":
+		w.replace(suffix, "eau")
+	case "aux":
+		if !w.in(suffix, r1) {
+			return false
+		}
+		w.replace(suffix, "al")
+	case "euse", "euses":
+		if w.in(suffix, r2) {
+			w.cut(suffix)
+		} else if w.in(suffix, r1) {
+			w.replace(suffix, "eux")
+		} else {
+			return false
+		}
+	case "issement", "issements":
+		if r, _ := w.runeBefore(suffix); !w.in(suffix, r1) || r == 0 || w.isVowel(r) {
+			return false
+		}
+		w.cut(suffix)
+	// the rest change the word but go on with the verb suffixes
+	case "amment":
+		if w.in(suffix, rv) {
+			w.replace(suffix, "ant")
+		}
+		return false
+	case "emment":
+		if w.in(suffix, rv) {
+			w.replace(suffix, "ent")
+		}
+		return false
+	case "ment", "ments":
+		if r, at := w.runeBefore(suffix); at >= rv && w.isVowel(r) {
+			w.cut(suffix)
+		}
+		return false
+	}
+	return true
+}
+
+// frenchVerb removes a verb suffix within RV, those beginning with i first
+func (w *stemWord) frenchVerb(rv, r2 int) bool {
+	if suffix := w.longest(rv, frenchIVerb...); suffix != "" {
+		if r, at := w.runeBefore(suffix); at >= rv && !w.isVowel(r) {
+			w.cut(suffix)
+			return true
+		}
+	}
+	suffix := w.longest(rv, frenchVerb...)
+	switch {
+	case suffix == "":
+		return false
+	case suffix == "ions":
+		if !w.in(suffix, r2) {
+			return false
+		}
+		w.cut(suffix)
+	case contains(frenchVerbE, suffix):
+		w.cut(suffix)
+	default:
+		w.cut(suffix)
+		if w.ends("e") && w.in("e", rv) {
+			w.cut("e")
+		}
+	}
+	return true
+}
+
+func (w *stemWord) frenchResidual(rv, r2 int) {
+	if w.ends("s") {
+		if r, _ := w.runeBefore("s"); !strings.ContainsRune("aiouès", r) {
+			w.cut("s")
+		}
+	}
+	switch suffix := w.longest(rv, "ion", "ier", "ière", "Ier", "Ière", "e", "ë"); suffix {
+	case "ion":
+		if r, at := w.runeBefore(suffix); w.in(suffix, r2) && at >= rv && (r == 's' || r == 't') {
+			w.cut(suffix)
+		}
+	case "ier", "ière", "Ier", "Ière":
+		w.replace(suffix, "i")
+	case "e":
+		w.cut(suffix)
+	case "ë":
+		if w.before(suffix, "gu") && w.in("gu"+suffix, rv) {
+			w.cut(suffix)
+		}
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	spanishPronouns = strings.Fields("me se sela selo selas selos la le lo las les los nos")
+	spanishStep1    = strings.Fields(`anza anzas ico ica icos icas ismo ismos able ables ible ibles
+		ista istas oso osa osos osas amiento amientos imiento imientos
+		adora ador ación adoras adores aciones ante antes ancia ancias
+		logía logías ución uciones encia encias amente mente idad idades iva ivo ivas ivos`)
+	spanishYVerb = strings.Fields("ya ye yan yen yeron yendo yo yó yas yes yais yamos")
+	spanishVerb  = strings.Fields(`en es éis emos
+		arían arías arán arás aríais aría aréis aríamos aremos ará aré
+		erían erías erán erás eríais ería eréis eríamos eremos erá eré
+		irían irías irán irás iríais iría iréis iríamos iremos irá iré
+		aba ada ida ía ara iera ad ed id ase iese aste iste an aban ían aran ieran asen iesen
+		aron ieron ado ido ando iendo ió ar er ir as abas adas idas ías aras ieras ases ieses
+		ís áis abais íais arais ierais aseis ieseis asteis isteis ados idos amos ábamos íamos
+		imos áramos iéramos iésemos ásemos`)
+)
+
+// spanishStem stems a Spanish word
+func spanishStem(word string) string {
+	w := &stemWord{s: word, vowels: "aeiouáéíóúü"}
+	runes := []rune(w.s)
+	rv := len(w.s)
+	if len(runes) >= 2 {
+		// after the next vowel if the second letter is a consonant, after
+		// the next consonant if the first two are vowels, otherwise after
+		// the third letter
+		from, vowel := 2, true
+		switch {
+		case !w.isVowel(runes[1]):
+		case w.isVowel(runes[0]):
+			vowel = false
+		default:
+			from = -1
+			rv = runeOffset(w.s, 3)
+		}
+		for i := from; i >= 0 && i < len(runes); i++ {
+			if w.isVowel(runes[i]) == vowel {
+				rv = runeOffset(w.s, i+1)
+				break
+			}
+		}
+	}
+	r1 := w.region(0)
+	r2 := w.region(r1)
+
+	if pronoun := w.longest(0, spanishPronouns...); pronoun != "" {
+		verb := &stemWord{s: w.s[:len(w.s)-len(pronoun)], vowels: w.vowels}
+		switch ending := verb.longest(0, "iéndo", "ándo", "ár", "ér", "ír", "ando", "iendo", "ar", "er", "ir", "yendo"); {
+		case ending == "" || !verb.in(ending, rv):
+		case ending == "iéndo" || ending == "ándo" || ending == "ár" || ending == "ér" || ending == "ír":
+			w.s = verb.s[:len(verb.s)-len(ending)] + unaccentSpanish(ending)
+		case ending == "yendo":
+			if verb.before(ending, "u") {
+				w.s = verb.s
+			}
+		default:
+			w.s = verb.s
+		}
+	}
+
+	if !w.spanishStandard(r1, r2) && !w.spanishVerb(rv) {
+		w.spanishVerbRest(rv)
+	}
+
+	switch suffix := w.longest(0, "os", "a", "o", "á", "í", "ó", "e", "é"); suffix {
+	case "":
+	case "e", "é":
+		if w.in(suffix, rv) {
+			w.cut(suffix)
+			if w.ends("u") && w.in("u", rv) && w.before("u", "g") {
+				w.cut("u")
+			}
+		}
+	default:
+		if w.in(suffix, rv) {
+			w.cut(suffix)
+		}
+	}
+	return unaccentSpanish(w.s)
+}
+
+func unaccentSpanish(s string) string {
+	return strings.NewReplacer("á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u").Replace(s)
+}
+
+func (w *stemWord) spanishStandard(r1, r2 int) bool {
+	suffix := w.longest(0, spanishStep1...)
+	switch suffix {
+	case "":
+		return false
+	case "amente":
+		if !w.in(suffix, r1) {
+			return false
+		}
+		w.cut(suffix)
+		if s := w.longest(0, "iv", "os", "ic", "ad"); s != "" && w.in(s, r2) {
+			w.cut(s)
+			if s == "iv" && w.ends("at") && w.in("at", r2) {
+				w.cut("at")
+			}
+		}
+		return true
+	}
+	if !w.in(suffix, r2) {
+		return false
+	}
+	switch suffix {
+	case "adora", "ador", "ación", "adoras", "adores", "aciones", "ante", "antes", "ancia", "ancias":
+		w.cut(suffix)
+		if w.ends("ic") && w.in("ic", r2) {
+			w.cut("ic")
+		}
+	case "logía", "logías":
+		w.replace(suffix, "log")
+	case "ución", "uciones":
+		w.replace(suffix, "u")
+	case "encia", "encias":
+		w.replace(suffix, "ente")
+	case "mente":
+		w.cut(suffix)
+		if s := w.longest(r2, "ante", "able", "ible"); s != "" {
+			w.cut(s)
+		}
+	case "idad", "idades":
+		w.cut(suffix)
+		if s := w.longest(r2, "abil", "ic", "iv"); s != "" {
+			w.cut(s)
+		}
+	case "iva", "ivo", "ivas", "ivos":
+		w.cut(suffix)
+		if w.ends("at") && w.in("at", r2) {
+			w.cut("at")
+		}
+	default:
+		w.cut(suffix)
+	}
+	return true
+}
+
+// spanishVerb removes a verb suffix beginning with y after a u
+func (w *stemWord) spanishVerb(rv int) bool {
+	if suffix := w.longest(rv, spanishYVerb...); suffix != "" && w.before(suffix, "u") {
+		w.cut(suffix)
+		return true
+	}
+	return false
+}
+
+func (w *stemWord) spanishVerbRest(rv int) {
+	switch suffix := w.longest(rv, spanishVerb...); suffix {
+	case "":
+	case "en", "es", "éis", "emos":
+		w.cut(suffix)
+		if w.ends("gu") {
+			w.cut("u")
+		}
+	default:
+		w.cut(suffix)
+	}
+}
+
+var (
+	russianGerund     = strings.Fields("в вши вшись ив ивши ившись ыв ывши ывшись")
+	russianAdjective  = strings.Fields("ее ие ые ое ими ыми ей ий ый ой ем им ым ом его ого ему ому их ых ую юю ая яя ою ею")
+	russianParticiple = strings.Fields("ем нн вш ющ щ ивш ывш ующ")
+	russianVerb       = strings.Fields(`ла на ете йте ли й л ем н ло но ет ют ны ть ешь нно
+		ила ыла ена ейте уйте ите или ыли ей уй ил ыл им ым ен ило ыло ено ят ует уют ит ыт ены ить ыть ишь ую ю`)
+	russianNoun = strings.Fields("а ев ов ие ье е иями ями ами еи ии и ией ей ой ий й иям ям ием ем ам ом о у ах иях ях ы ь ию ью ю ия ья я")
+	// endings of the groups above only removed after а or я
+	russianGerundAfterA     = strings.Fields("в вши вшись")
+	russianParticipleAfterA = strings.Fields("ем нн вш ющ щ")
+	russianVerbAfterA       = strings.Fields("ла на ете йте ли й л ем н ло но ет ют ны ть ешь нно")
+)
+
+// russianStem stems a Russian word
+func russianStem(word string) string {
+	w := &stemWord{s: strings.ReplaceAll(word, "ё", "е"), vowels: "аеиоуыэюя"}
+	rv := len(w.s)
+	for i, r := range w.s {
+		if w.isVowel(r) {
+			rv = i + utf8.RuneLen(r)
+			break
+		}
+	}
+	r2 := w.region(w.region(0))
+	// every suffix has to be within RV
+	prefix := w.s[:rv]
+	w.s = w.s[rv:]
+	r2 -= rv
+
+	if !w.russianEnding(russianGerund, russianGerundAfterA) {
+		if s := w.longest(0, "ся", "сь"); s != "" {
+			w.cut(s)
+		}
+		if w.russianEnding(russianAdjective, nil) {
+			w.russianEnding(russianParticiple, russianParticipleAfterA)
+		} else if !w.russianEnding(russianVerb, russianVerbAfterA) {
+			w.russianEnding(russianNoun, nil)
+		}
+	}
+	if w.ends("и") {
+		w.cut("и")
+	}
+	if s := w.longest(r2, "ост", "ость"); s != "" {
+		w.cut(s)
+	}
+	switch s := w.longest(0, "ейш", "ейше", "н", "ь"); s {
+	case "ейш", "ейше":
+		w.cut(s)
+		if w.ends("нн") {
+			w.cut("н")
+		}
+	case "н":
+		if w.ends("нн") {
+			w.cut("н")
+		}
+	case "ь":
+		w.cut(s)
+	}
+	return prefix + w.s
+}
+
+// russianEnding removes the longest of endings, those also in afterA only
+// after а or я
+func (w *stemWord) russianEnding(endings, afterA []string) bool {
+	s := w.longest(0, endings...)
+	if s == "" {
+		return false
+	}
+	if contains(afterA, s) && !w.before(s, "а") && !w.before(s, "я") {
+		return false
+	}
+	w.cut(s)
+	return true
+}
@@ -0,0 +1,49 @@
+package sego
+
+import "testing"
+
+func TestStemmers(t *testing.T) {
+	tests := []struct {
+		lang  string
+		stem  func(string) string
+		words map[string]string
+	}{
+		{"en", porterStem, map[string]string{
+			"running": "run", "caresses": "caress", "ponies": "poni", "relational": "relat",
+		}},
+		{"de", germanStem, map[string]string{
+			"katzen": "katz", "häuser": "haus", "aufeinanderfolgenden": "aufeinanderfolg", "kategorie": "kategori",
+		}},
+		{"fr", frenchStem, map[string]string{
+			"continuellement": "continuel", "continuation": "continu", "continuait": "continu", "majestueusement": "majestu",
+		}},
+		{"es", spanishStem, map[string]string{
+			"chicas": "chic", "cantando": "cant", "niños": "niñ", "torneos": "torne",
+		}},
+		{"ru", russianStem, map[string]string{
+			"книги": "книг", "красивая": "красив", "бегущий": "бегущ", "вечерами": "вечер",
+		}},
+	}
+	for _, test := range tests {
+		for word, want := range test.words {
+			if got := test.stem(word); got != want {
+				t.Errorf("%s: %s stems to %s, want %s", test.lang, word, got, want)
+			}
+		}
+	}
+}
+
+func TestStemCased(t *testing.T) {
+	tests := map[string]string{
+		"KATZEN": "KATZ",
+		"katzen": "katz",
+		"Katzen": "katz",
+		"KATZ":   "KATZ",
+		"Katz":   "Katz",
+	}
+	for token, want := range tests {
+		if got := stemCased(token, germanStem); got != want {
+			t.Errorf("%s stems to %s, want %s", token, got, want)
+		}
+	}
+}
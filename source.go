@@ -0,0 +1,308 @@
+package sego
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Source is a collection of documents to index, names are what the documents
+// are indexed under
+type Source interface {
+	// List returns the names of the documents to index and the ones that were
+	// left out with the reason why
+	List() ([]string, map[string]string, error)
+	Open(name string) (io.ReadCloser, error)
+	Stat(name string) (fs.FileInfo, error)
+	// Contains reports whether name would belong to the source, whether or not
+	// it is still there
+	Contains(name string) bool
+}
+
+// openSource picks the source for a command line argument: an http(s) URL,
+// an s3:// bucket, a zip or tar archive or else a directory
+func openSource(arg string, config *Config) (Source, error) {
+	lower := strings.ToLower(arg)
+	switch {
+	case strings.HasPrefix(lower, "s3://"):
+		return newS3Source(arg)
+	case strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://"):
+		return newHTTPSource([]string{arg}), nil
+	case strings.HasSuffix(lower, ".zip"):
+		return newZipSource(arg)
+	case strings.HasSuffix(lower, ".tar") || strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz"):
+		return newTarSource(arg, config.MaxFileSize)
+	}
+	return newDirSource(arg, config), nil
+}
+
+// closeSource releases what a source holds open, like the file of a zip
+// archive
+func closeSource(source Source) {
+	if c, ok := source.(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			log.Printf("Closing source: %s", err)
+		}
+	}
+}
+
+// dirSource is the files below a directory
+type dirSource struct {
+	root   string
+	config *Config
+}
+
+func newDirSource(root string, config *Config) *dirSource {
+	return &dirSource{root: root, config: config}
+}
+
+func (s *dirSource) List() ([]string, map[string]string, error) {
+	return readDir(s.root, s.config)
+}
+
+func (s *dirSource) Open(name string) (io.ReadCloser, error) {
+	return os.Open(name)
+}
+
+func (s *dirSource) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (s *dirSource) Contains(name string) bool {
+	return strings.HasPrefix(name, strings.TrimSuffix(s.root, "/")+"/")
+}
+
+// httpSource is a list of URLs
+type httpSource struct {
+	urls   []string
+	client *http.Client
+}
+
+func newHTTPSource(urls []string) *httpSource {
+	return &httpSource{urls: urls, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+func (s *httpSource) List() ([]string, map[string]string, error) {
+	return s.urls, nil, nil
+}
+
+func (s *httpSource) Open(name string) (io.ReadCloser, error) {
+	resp, err := s.client.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %s", name, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+func (s *httpSource) Contains(name string) bool {
+	for _, u := range s.urls {
+		if u == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *httpSource) Stat(name string) (fs.FileInfo, error) {
+	resp, err := s.client.Head(name)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", name, resp.Status)
+	}
+	modified, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	// the size is -1 when the server doesn't say
+	return &fileInfo{name: name, size: resp.ContentLength, modTime: modified}, nil
+}
+
+// fileInfo describes documents that don't live on disk
+type fileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+func (fi *fileInfo) Name() string       { return fi.name }
+func (fi *fileInfo) Size() int64        { return fi.size }
+func (fi *fileInfo) Mode() fs.FileMode  { return 0444 }
+func (fi *fileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *fileInfo) IsDir() bool        { return false }
+func (fi *fileInfo) Sys() any           { return nil }
+
+// archive members are named archive#member
+func archiveName(archive, member string) string {
+	return archive + "#" + member
+}
+
+func archiveMember(archive, name string) (string, error) {
+	member, ok := strings.CutPrefix(name, archive+"#")
+	if !ok {
+		return "", fmt.Errorf("%s is not in %s", name, archive)
+	}
+	return member, nil
+}
+
+// zipSource is the files in a zip archive
+type zipSource struct {
+	path   string
+	reader *zip.ReadCloser
+	files  map[string]*zip.File
+}
+
+func newZipSource(path string) (*zipSource, error) {
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &zipSource{path: path, reader: reader, files: make(map[string]*zip.File)}
+	for _, f := range reader.File {
+		if f.Mode().IsRegular() {
+			s.files[f.Name] = f
+		}
+	}
+	return s, nil
+}
+
+func (s *zipSource) Close() error {
+	return s.reader.Close()
+}
+
+func (s *zipSource) List() ([]string, map[string]string, error) {
+	names := make([]string, 0, len(s.files))
+	for _, f := range s.reader.File {
+		if _, ok := s.files[f.Name]; ok {
+			names = append(names, archiveName(s.path, f.Name))
+		}
+	}
+	return names, nil, nil
+}
+
+func (s *zipSource) file(name string) (*zip.File, error) {
+	member, err := archiveMember(s.path, name)
+	if err != nil {
+		return nil, err
+	}
+	f, ok := s.files[member]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", name, fs.ErrNotExist)
+	}
+	return f, nil
+}
+
+func (s *zipSource) Contains(name string) bool {
+	_, err := archiveMember(s.path, name)
+	return err == nil
+}
+
+func (s *zipSource) Open(name string) (io.ReadCloser, error) {
+	f, err := s.file(name)
+	if err != nil {
+		return nil, err
+	}
+	return f.Open()
+}
+
+func (s *zipSource) Stat(name string) (fs.FileInfo, error) {
+	f, err := s.file(name)
+	if err != nil {
+		return nil, err
+	}
+	return f.FileInfo(), nil
+}
+
+// tarSource is the files in a tar archive, optionally gzipped. Tar archives
+// can't be read out of order so the files are kept in memory, each cut at
+// limit bytes unless limit is 0.
+type tarSource struct {
+	path    string
+	names   []string
+	infos   map[string]fs.FileInfo
+	content map[string][]byte
+}
+
+func newTarSource(path string, limit int64) (*tarSource, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if lower := strings.ToLower(path); strings.HasSuffix(lower, "gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	s := &tarSource{
+		path:    path,
+		names:   make([]string, 0),
+		infos:   make(map[string]fs.FileInfo),
+		content: make(map[string][]byte),
+	}
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		content, err := readLimit(tr, limit)
+		if err != nil {
+			return nil, err
+		}
+		name := archiveName(path, header.Name)
+		s.names = append(s.names, name)
+		s.infos[name] = header.FileInfo()
+		s.content[name] = content
+	}
+	return s, nil
+}
+
+func (s *tarSource) List() ([]string, map[string]string, error) {
+	return s.names, nil, nil
+}
+
+func (s *tarSource) Contains(name string) bool {
+	_, err := archiveMember(s.path, name)
+	return err == nil
+}
+
+func (s *tarSource) Open(name string) (io.ReadCloser, error) {
+	content, ok := s.content[name]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", name, fs.ErrNotExist)
+	}
+	return io.NopCloser(bytes.NewReader(content)), nil
+}
+
+func (s *tarSource) Stat(name string) (fs.FileInfo, error) {
+	info, ok := s.infos[name]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", name, fs.ErrNotExist)
+	}
+	return info, nil
+}
@@ -0,0 +1,69 @@
+package sego
+
+import (
+	"archive/zip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIndexSkipsUnreadable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gone" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("vertex shader"))
+	}))
+	defer server.Close()
+
+	config := newConfig()
+	m := newModel()
+	source := newHTTPSource([]string{server.URL + "/gone", server.URL + "/page"})
+	if err := m.index(source, config); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.document(server.URL + "/page"); !ok {
+		t.Error("page after the missing one wasn't indexed")
+	}
+	if _, ok := m.Stats.SkippedFiles[server.URL+"/gone"]; !ok {
+		t.Error("missing page wasn't reported as skipped")
+	}
+}
+
+func TestCloseZipSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "docs.zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := zip.NewWriter(f)
+	member, err := w.Create("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	member.Write([]byte("vertex shader"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	source, err := openSource(path, newConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := source.Open(archiveName(path, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+	closeSource(source)
+	if r, err := source.Open(archiveName(path, "a.txt")); err == nil {
+		if _, err := io.ReadAll(r); err == nil {
+			t.Error("archive is still open")
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package sego
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// spill holds the term tables of documents moved to disk during indexing
+// because they took more memory than allowed, they are merged back into the
+// index file when it is saved
+type spill struct {
+	dir      string
+	segments []string
+	// documents indexed since the last segment was written
+	fresh []string
+}
+
+// termTableSize approximates the memory a document's term table takes
+func termTableSize(id string, tf TermFreq) int64 {
+	size := int64(len(id) + 64)
+	for term := range tf {
+		// string header, count and map overhead
+		size += int64(len(term) + 40)
+	}
+	return size
+}
+
+// estimateMemory approximates the memory of the term tables and document
+// frequencies
+func (m *Model) estimateMemory() int64 {
+	var size int64
+	for id, tf := range m.TF {
+		size += termTableSize(id, tf)
+	}
+	for term := range m.DF {
+		size += int64(len(term) + 40)
+	}
+	return size
+}
+
+// trackMemory accounts for the term table of id changing from old to tf
+func (m *Model) trackMemory(id string, old, tf TermFreq) {
+	if m.memory == 0 {
+		m.memory = m.estimateMemory()
+	}
+	m.memory += termTableSize(id, tf)
+	if old != nil {
+		m.memory -= termTableSize(id, old)
+	}
+	for term := range tf {
+		if _, had := old[term]; !had && m.DF[term] == 1 {
+			m.memory += int64(len(term) + 40)
+		}
+	}
+	if m.Stats != nil && m.memory > m.Stats.Memory {
+		m.Stats.Memory = m.memory
+	}
+	if m.spill != nil {
+		m.spill.fresh = append(m.spill.fresh, id)
+	}
+}
+
+// spillSegment writes the term tables of the documents indexed since the
+// last segment to disk and drops them from memory
+func (m *Model) spillSegment() error {
+	if len(m.spill.fresh) == 0 {
+		return nil
+	}
+	if m.spill.dir == "" {
+		dir, err := os.MkdirTemp("", "sego-spill-")
+		if err != nil {
+			return err
+		}
+		m.spill.dir = dir
+	}
+
+	segment := make(map[string]TermFreq, len(m.spill.fresh))
+	for _, id := range m.spill.fresh {
+		if tf, ok := m.TF[id]; ok {
+			segment[id] = tf
+			m.memory -= termTableSize(id, tf)
+			delete(m.TF, id)
+		}
+	}
+	m.spill.fresh = nil
+	data, err := json.Marshal(segment)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(m.spill.dir, fmt.Sprintf("segment-%d.json", len(m.spill.segments)))
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return err
+	}
+	m.spill.segments = append(m.spill.segments, path)
+	m.Stats.Spilled++
+	log.Printf("Spilled %d documents to %s, about %s left in memory", len(segment), path, formatSize(m.memory))
+	return nil
+}
+
+// saveMerged writes the index like saveAsJson, merging the spilled segments
+// into its term tables one at a time
+func (m *Model) saveMerged(path string) error {
+	tf := m.TF
+	m.TF = nil
+	rest, err := json.MarshalIndent(m, "", "  ")
+	m.TF = tf
+	if err != nil {
+		return err
+	}
+	prefix := []byte("{\n  \"tf\": null,")
+	if !bytes.HasPrefix(rest, prefix) {
+		return fmt.Errorf("saving %s: tf isn't the first field of the index", path)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	w.WriteString("{\n  \"tf\": {")
+	first := true
+	write := func(table TermFreqTable) error {
+		ids := make([]string, 0, len(table))
+		for id := range table {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			key, _ := json.Marshal(id)
+			value, err := json.MarshalIndent(table[id], "    ", "  ")
+			if err != nil {
+				return err
+			}
+			if !first {
+				w.WriteByte(',')
+			}
+			first = false
+			fmt.Fprintf(w, "\n    %s: %s", key, value)
+		}
+		return nil
+	}
+
+	err = write(m.TF)
+	for _, segment := range m.spill.segments {
+		if err != nil {
+			break
+		}
+		var data []byte
+		if data, err = os.ReadFile(segment); err != nil {
+			break
+		}
+		var table TermFreqTable
+		if err = json.Unmarshal(data, &table); err != nil {
+			break
+		}
+		err = write(table)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.WriteString("\n  },")
+	w.Write(rest[len(prefix):])
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.RemoveAll(m.spill.dir)
+}
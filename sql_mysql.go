@@ -0,0 +1,5 @@
+//go:build mysql
+
+package sego
+
+import _ "github.com/go-sql-driver/mysql"
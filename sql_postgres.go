@@ -0,0 +1,5 @@
+//go:build postgres
+
+package sego
+
+import _ "github.com/lib/pq"
@@ -0,0 +1,162 @@
+package sego
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// SQLOptions say which rows of a database to index and how their columns map
+// to documents. The driver has to be compiled in, see sql_*.go.
+type SQLOptions struct {
+	Driver string `json:"driver"`
+	DSN    string `json:"dsn"`
+	// e.g. "SELECT id, title, body FROM articles"
+	Query string `json:"query"`
+	// documents are keyed by prefix + id, default "sql:"
+	Prefix *string `json:"prefix"`
+	// column with the document id, default the first one
+	ID string `json:"id"`
+	// columns with the text to index, default all but the id and metadata columns
+	Text []string `json:"text"`
+	// metadata columns
+	Title     string `json:"title"`
+	Author    string `json:"author"`
+	URL       string `json:"url"`
+	Lang      string `json:"lang"`
+	Tags      string `json:"tags"`
+	Published string `json:"published"`
+}
+
+func sqlString(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	}
+	return fmt.Sprint(value)
+}
+
+func sqlTime(value any) (time.Time, bool) {
+	if t, ok := value.(time.Time); ok {
+		return t, true
+	}
+	return parseDate(sqlString(value))
+}
+
+// indexSQL adds every row the configured query returns as a document
+func (m *Model) indexSQL(opts *SQLOptions, config *Config) error {
+	compiledIn := false
+	for _, driver := range sql.Drivers() {
+		compiledIn = compiledIn || driver == opts.Driver
+	}
+	if !compiledIn {
+		return fmt.Errorf("no SQL driver %q, build sego with -tags %s", opts.Driver, opts.Driver)
+	}
+	db, err := sql.Open(opts.Driver, opts.DSN)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(opts.Query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	index := make(map[string]int)
+	for i, column := range columns {
+		index[column] = i
+	}
+	idColumn := opts.ID
+	if idColumn == "" {
+		idColumn = columns[0]
+	}
+	metadata := map[string]bool{idColumn: true}
+	for _, column := range []string{opts.Title, opts.Author, opts.URL, opts.Lang, opts.Tags, opts.Published} {
+		if column == "" {
+			continue
+		}
+		if _, ok := index[column]; !ok {
+			return fmt.Errorf("query has no column %q", column)
+		}
+		metadata[column] = true
+	}
+	if _, ok := index[idColumn]; !ok {
+		return fmt.Errorf("query has no column %q", idColumn)
+	}
+	text := opts.Text
+	if len(text) == 0 {
+		for _, column := range columns {
+			if !metadata[column] {
+				text = append(text, column)
+			}
+		}
+	}
+	for _, column := range text {
+		if _, ok := index[column]; !ok {
+			return fmt.Errorf("query has no column %q", column)
+		}
+	}
+	prefix := "sql:"
+	if opts.Prefix != nil {
+		prefix = *opts.Prefix
+	}
+
+	m.prepareIndexing(config)
+	values := make([]any, len(columns))
+	pointers := make([]any, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return err
+		}
+		column := func(name string) string {
+			if name == "" {
+				return ""
+			}
+			return sqlString(values[index[name]])
+		}
+
+		key := prefix + column(idColumn)
+		parts := make([]string, 0, len(text))
+		for _, name := range text {
+			parts = append(parts, column(name))
+		}
+		m.Stats.Files++
+		log.Printf("Indexing: %s", key)
+		if err := m.indexDocument(key, []byte(strings.Join(parts, "\n")), "", config, m.Stats); err != nil {
+			return err
+		}
+
+		doc, ok := m.document(key)
+		if !ok {
+			continue
+		}
+		doc.merge(&Document{
+			Title:  column(opts.Title),
+			Author: column(opts.Author),
+			URL:    column(opts.URL),
+			Lang:   column(opts.Lang),
+			Tags:   splitTags(column(opts.Tags)),
+		})
+		if opts.Published != "" {
+			if published, ok := sqlTime(values[index[opts.Published]]); ok {
+				doc.Published = &published
+			}
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,125 @@
+package sego
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// IndexStats describes the last indexing run
+type IndexStats struct {
+	Files     int `json:"files"`
+	Indexed   int `json:"indexed"`
+	Skipped   int `json:"skipped"`
+	Truncated int `json:"truncated"`
+	Tokens    int `json:"tokens"`
+	// recrawled pages the server said hadn't changed
+	Unchanged int `json:"unchanged,omitempty"`
+	// approximate peak memory of the term tables in bytes, and how many
+	// segments of them were spilled to disk to stay under the limit
+	Memory  int64 `json:"memory,omitempty"`
+	Spilled int   `json:"spilled,omitempty"`
+	// path => why it was skipped or truncated
+	SkippedFiles   map[string]string `json:"skipped_files,omitempty"`
+	TruncatedFiles map[string]string `json:"truncated_files,omitempty"`
+}
+
+func newIndexStats() *IndexStats {
+	return &IndexStats{
+		SkippedFiles:   make(map[string]string),
+		TruncatedFiles: make(map[string]string),
+	}
+}
+
+func (s *IndexStats) skip(path, reason string) {
+	log.Printf("Skipping: %s (%s)", path, reason)
+	s.Skipped++
+	s.SkippedFiles[path] = reason
+}
+
+func (s *IndexStats) truncate(path, reason string) {
+	log.Printf("Truncating: %s (%s)", path, reason)
+	s.Truncated++
+	s.TruncatedFiles[path] = reason
+}
+
+func (s *IndexStats) String() string {
+	str := fmt.Sprintf("%d files, %d indexed, %d skipped, %d truncated, %d tokens",
+		s.Files, s.Indexed, s.Skipped, s.Truncated, s.Tokens)
+	if s.Unchanged > 0 {
+		str += fmt.Sprintf(", %d unchanged", s.Unchanged)
+	}
+	if s.Memory > 0 {
+		str += fmt.Sprintf(", ~%s in memory", formatSize(s.Memory))
+	}
+	if s.Spilled > 0 {
+		str += fmt.Sprintf(", %d segments spilled", s.Spilled)
+	}
+	return str
+}
+
+// parseSize parses sizes like "512", "64K", "10MB" or "1G"
+func parseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	s = strings.TrimSuffix(s, "B")
+	multiplier := int64(1)
+	for suffix, m := range map[string]int64{"K": 1 << 10, "M": 1 << 20, "G": 1 << 30} {
+		if strings.HasSuffix(s, suffix) {
+			multiplier = m
+			s = strings.TrimSuffix(s, suffix)
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * multiplier, nil
+}
+
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1fGB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1fMB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1fKB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%dB", n)
+}
+
+// dryRunReport prints what happened to every file during indexing and how big
+// the index would be on disk
+func (m *Model) dryRunReport(w io.Writer) error {
+	paths := make([]string, 0, len(m.TF)+len(m.Stats.SkippedFiles))
+	for id := range m.TF {
+		paths = append(paths, m.docPath(id))
+	}
+	for path := range m.Stats.SkippedFiles {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		if reason, ok := m.Stats.SkippedFiles[path]; ok {
+			fmt.Fprintf(w, "skip      %s (%s)\n", path, reason)
+		} else if reason, ok := m.Stats.TruncatedFiles[path]; ok {
+			fmt.Fprintf(w, "truncate  %s (%s)\n", path, reason)
+		} else {
+			fmt.Fprintf(w, "index     %s\n", path)
+		}
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "%d files would be indexed (%d truncated), %d skipped, %d terms, index size about %s\n",
+		m.Stats.Indexed, m.Stats.Truncated, m.Stats.Skipped, len(m.DF), formatSize(int64(len(data))))
+	return nil
+}
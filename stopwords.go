@@ -0,0 +1,77 @@
+package sego
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+// suggestStopwords returns the terms found in more than fraction of the
+// documents, most common first
+func (m *Model) suggestStopwords(fraction float64) []TermStats {
+	n := len(m.docIDs())
+	candidates := make([]TermStats, 0)
+	for _, t := range m.termStats() {
+		if float64(t.DF) > fraction*float64(n) {
+			candidates = append(candidates, t)
+		}
+	}
+	sortTermStats(candidates, "df")
+	return candidates
+}
+
+// dropStopwords leaves out query terms found in more than StopwordFraction of
+// the n documents, unless that would leave nothing to search for
+func (m *Model) dropStopwords(tokens []string, n int) []string {
+	if m.StopwordFraction <= 0 {
+		return tokens
+	}
+	kept := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		if float64(m.docFreq(token)) <= m.StopwordFraction*float64(n) {
+			kept = append(kept, token)
+		}
+	}
+	if len(kept) == 0 {
+		return tokens
+	}
+	return kept
+}
+
+func runStopwords(args []string) {
+	fs := flag.NewFlagSet("stopwords", flag.ExitOnError)
+	indexPath := fs.String("index", "index-new.json", "index to look at")
+	suggest := fs.Bool("suggest", false, "list terms common enough to be stopwords")
+	fraction := fs.Float64("fraction", 0.5, "share of documents a term has to be in to count as a stopword")
+	apply := fs.Bool("apply", false, "make searches of the index drop such terms from queries")
+	off := fs.Bool("off", false, "stop dropping common terms from queries")
+	fs.Parse(args)
+	if !*suggest && !*apply && !*off {
+		log.Fatal("usage: sego stopwords [-index index.json] [-fraction 0.5] -suggest|-apply|-off")
+	}
+	if *fraction <= 0 || *fraction > 1 {
+		log.Fatalf("fraction has to be in (0, 1], got %g", *fraction)
+	}
+
+	model, err := newModelFromJson(*indexPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *suggest {
+		n := len(model.docIDs())
+		for _, t := range model.suggestStopwords(*fraction) {
+			fmt.Fprintf(os.Stdout, "%s\t%d/%d documents\n", t.Term, t.DF, n)
+		}
+	}
+	if *apply || *off {
+		model.StopwordFraction = *fraction
+		if *off {
+			model.StopwordFraction = 0
+		}
+		if err := model.saveAsJson(*indexPath); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package sego
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// searchStream calls emit with the results of query best first until it
+// returns false. Shards are scored and sorted side by side and merged as emit
+// takes results, so only as many as it takes are merged.
+func (m *Model) searchStream(query string, emit func(SearchResult) bool) {
+	plan := m.plan(query, nil, &SearchTiming{})
+	shards := m.shardCount(len(plan.docs))
+	lists := make([]SearchResults, shards)
+	var wg sync.WaitGroup
+	for i := range lists {
+		lo, hi := i*len(plan.docs)/shards, (i+1)*len(plan.docs)/shards
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			lists[i] = m.score(plan, lo, hi)
+			sort.Sort(lists[i])
+		}(i)
+	}
+	wg.Wait()
+	if m.ChunkSize == 0 {
+		mergeEach(lists, emit)
+		return
+	}
+	seen := make(map[string]bool)
+	mergeEach(lists, func(r SearchResult) bool {
+		return !m.foldChunk(&r, seen) || emit(r)
+	})
+}
+
+// wantsStream reports whether a request accepts results as newline delimited
+// JSON
+func wantsStream(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, t := range strings.Split(accept, ",") {
+			if t, _, _ := strings.Cut(t, ";"); strings.TrimSpace(t) == "application/x-ndjson" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// handleSearchStream answers GET /search with one JSON result per line.
+// Results are all of them unless n is given and aren't collapsed, grouped,
+// diversified or pinned, the options needing the whole list.
+func (s *server) handleSearchStream(w http.ResponseWriter, r *http.Request) {
+	span := tracing.startRemote(r.Header.Get("traceparent"), "GET /search")
+	defer span.finish()
+	params := r.URL.Query()
+	limit := 0
+	if n := params.Get("n"); n != "" {
+		var err error
+		if limit, err = strconv.Atoi(n); err != nil || limit < 0 {
+			httpError(w, http.StatusBadRequest, fmt.Errorf("invalid n %q", n))
+			return
+		}
+		if limit == 0 {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			return
+		}
+	}
+	minRank := 0.0
+	if min := params.Get("min"); min != "" {
+		var err error
+		if minRank, err = strconv.ParseFloat(min, 64); err != nil {
+			httpError(w, http.StatusBadRequest, fmt.Errorf("invalid min %q", min))
+			return
+		}
+	}
+	fields, err := parseFields(params.Get("fields"))
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// a served model is never changed, only swapped, so it is searched
+	// without the lock and a slow client doesn't hold up reloads. One updated
+	// live keeps the lock until the last result is written.
+	s.mu.RLock()
+	model := s.model
+	if s.live {
+		defer s.mu.RUnlock()
+	} else {
+		s.mu.RUnlock()
+	}
+
+	query := params.Get("q")
+	if params.Get("phonetic") != "" {
+		query = model.phoneticQuery(query)
+	}
+	blocklist := s.blocklist.get()
+	snippets := wantsSnippets(fields)
+	var terms map[string]bool
+	if snippets {
+		terms = make(map[string]bool)
+		for _, term := range tokenize(parseQuery(query).Text, model.Lexer) {
+			terms[term] = true
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	written := 0
+	model.searchStream(query, func(result SearchResult) bool {
+		if minRank != 0 && result.Rank < minRank {
+			return false
+		}
+		if blocklist.blocked(result.Path) {
+			return true
+		}
+		if snippets {
+			result.Highlight = model.highlight(model.documentText(result.Path, s.config), terms, &HighlightOptions{})
+		}
+		var line any = result
+		if fields != nil {
+			line = SearchResults{result}.project(fields)[0]
+		}
+		if err := enc.Encode(line); err != nil {
+			log.Printf("Writing response: %s", err)
+			return false
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		written++
+		return limit == 0 || written < limit
+	})
+}
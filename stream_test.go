@@ -0,0 +1,61 @@
+package sego
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// lockProbe records whether the server's lock was free whenever a line was
+// written, and how often lines were flushed
+type lockProbe struct {
+	*httptest.ResponseRecorder
+	mu      *sync.RWMutex
+	writes  int
+	locked  int
+	flushes int
+}
+
+func (p *lockProbe) Flush() {
+	p.flushes++
+	p.ResponseRecorder.Flush()
+}
+
+func (p *lockProbe) Write(b []byte) (int, error) {
+	p.writes++
+	if p.mu.TryLock() {
+		p.mu.Unlock()
+	} else {
+		p.locked++
+	}
+	return p.ResponseRecorder.Write(b)
+}
+
+func TestSearchStream(t *testing.T) {
+	config := newConfig()
+	m := newModel()
+	for i := 0; i < 5; i++ {
+		if err := m.apply(&IngestMessage{Path: fmt.Sprintf("%d.txt", i), Content: "shader page"}, config); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, live := range []bool{false, true} {
+		s := &server{model: m, config: config, mu: &sync.RWMutex{}, live: live}
+		w := &lockProbe{ResponseRecorder: httptest.NewRecorder(), mu: s.mu}
+		r := httptest.NewRequest(http.MethodGet, "/search?q=shader&n=3", nil)
+		r.Header.Set("Accept", "application/x-ndjson")
+		s.handleSearch(w, r)
+		if lines := strings.Count(w.Body.String(), "\n"); lines != 3 || w.writes != 3 || w.flushes != 3 {
+			t.Fatalf("live %t: %d lines in %d writes and %d flushes", live, lines, w.writes, w.flushes)
+		}
+		if !live && w.locked > 0 {
+			t.Errorf("%d lines written holding the lock", w.locked)
+		}
+		if live && w.locked != w.writes {
+			t.Errorf("live index written without the lock")
+		}
+	}
+}
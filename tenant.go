@@ -0,0 +1,138 @@
+package sego
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tenant is a site served by a shared sego, found by its API key
+type Tenant struct {
+	Name  string `json:"name"`
+	Key   string `json:"key"`
+	Index string `json:"index"`
+	// config file the index was built with, to extract documents again for
+	// highlights like it did
+	Config string `json:"config,omitempty"`
+	// searches per second and how many may come at once, 0 means unlimited
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+	// most results one search returns, 0 means no limit
+	MaxResults int `json:"max_results"`
+}
+
+// tokenBucket allows rate requests per second on average and burst at once
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	b := math.Max(float64(burst), 1)
+	return &tokenBucket{rate: rate, burst: b, tokens: b, last: time.Now()}
+}
+
+// take reports whether a request may go ahead, or else how long until it may
+func (b *tokenBucket) take() (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+type tenant struct {
+	*Tenant
+	server *server
+	// nil if unlimited
+	bucket *tokenBucket
+}
+
+// tenantRouter sends every search to the index of the tenant its key belongs to
+type tenantRouter struct {
+	byKey map[string]*tenant
+}
+
+func loadTenants(path string) (*tenantRouter, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tenants []*Tenant
+	if err := json.Unmarshal(data, &tenants); err != nil {
+		return nil, err
+	}
+
+	router := &tenantRouter{byKey: make(map[string]*tenant)}
+	for _, t := range tenants {
+		if t.Key == "" || t.Index == "" {
+			return nil, fmt.Errorf("tenant %s needs a key and an index", t.Name)
+		}
+		if _, ok := router.byKey[t.Key]; ok {
+			return nil, fmt.Errorf("tenant %s: key used twice", t.Name)
+		}
+		model, err := newModelFromJson(t.Index)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", t.Name, err)
+		}
+		config := newConfig()
+		if t.Config != "" {
+			if err := config.load(t.Config); err != nil {
+				return nil, fmt.Errorf("tenant %s: %w", t.Name, err)
+			}
+		}
+		tt := &tenant{Tenant: t, server: &server{model: model, config: config, maxResults: t.MaxResults, mu: &sync.RWMutex{}}}
+		if t.Rate > 0 {
+			tt.bucket = newTokenBucket(t.Rate, t.Burst)
+		}
+		router.byKey[t.Key] = tt
+		log.Printf("Tenant %s: %s", t.Name, t.Index)
+	}
+	return router, nil
+}
+
+// apiKey is taken from an "Authorization: Bearer" or X-API-Key header
+func apiKey(r *http.Request) string {
+	if key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(key)
+	}
+	return r.Header.Get("X-API-Key")
+}
+
+func (router *tenantRouter) handleSearch(w http.ResponseWriter, r *http.Request) {
+	t, ok := router.byKey[apiKey(r)]
+	if !ok {
+		httpError(w, http.StatusUnauthorized, fmt.Errorf("missing or unknown API key"))
+		return
+	}
+	if t.bucket != nil {
+		if ok, wait := t.bucket.take(); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			httpError(w, http.StatusTooManyRequests, fmt.Errorf("rate limit of %g searches per second exceeded", t.Rate))
+			return
+		}
+	}
+	if t.MaxResults > 0 {
+		params := r.URL.Query()
+		if n, err := strconv.Atoi(params.Get("n")); err != nil || n > t.MaxResults {
+			params.Set("n", strconv.Itoa(t.MaxResults))
+			r.URL.RawQuery = params.Encode()
+		}
+	}
+	t.server.handleSearch(w, r)
+}
@@ -0,0 +1,58 @@
+package sego
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTenantMaxResultsPost(t *testing.T) {
+	dir := t.TempDir()
+	config := newConfig()
+	m := newModel()
+	for i := 0; i < 10; i++ {
+		if err := m.apply(&IngestMessage{Path: fmt.Sprintf("%d.txt", i), Content: "shader page"}, config); err != nil {
+			t.Fatal(err)
+		}
+	}
+	index := filepath.Join(dir, "index.json")
+	if err := m.saveAsJson(index); err != nil {
+		t.Fatal(err)
+	}
+	tenants := filepath.Join(dir, "tenants.json")
+	data := fmt.Sprintf(`[{"name": "a", "key": "secret", "index": %q, "max_results": 3}]`, index)
+	if err := os.WriteFile(tenants, []byte(data), 0666); err != nil {
+		t.Fatal(err)
+	}
+	router, err := loadTenants(tenants)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		body string
+		want int
+	}{
+		{`{"must": [{"term": "shader"}], "size": 100}`, 3},
+		{`{"must": [{"term": "shader"}], "from": 2, "size": 5}`, 1},
+		{`{"must": [{"term": "shader"}], "from": 5}`, 0},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/search", strings.NewReader(test.body))
+		r.Header.Set("X-API-Key", "secret")
+		w := httptest.NewRecorder()
+		router.handleSearch(w, r)
+		var response searchResponse
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		if w.Code != http.StatusOK || len(response.Results) != test.want {
+			t.Errorf("%s: answered %d with %d results, want %d", test.body, w.Code, len(response.Results), test.want)
+		}
+	}
+}
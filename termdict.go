@@ -0,0 +1,191 @@
+package sego
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Document frequencies are saved as a term dictionary: the terms sorted and
+// front coded, each as how many leading bytes it shares with the one before,
+// the rest of it and its document frequency, as varints and base64 encoded.
+// Every termBlockSize-th term is stored whole so a block can be decoded on its
+// own. Indexes saved before are plain JSON objects and read as well.
+
+const termBlockSize = 32
+
+// most terms a prefix in a query expands to, the most common are kept
+const maxPrefixTerms = 50
+
+var errCorruptDictionary = errors.New("corrupt term dictionary")
+
+func (d DocFreq) MarshalJSON() ([]byte, error) {
+	terms := make([]string, 0, len(d))
+	for term := range d {
+		terms = append(terms, term)
+	}
+	sort.Strings(terms)
+
+	buf := make([]byte, 0, 8*len(terms))
+	prev := ""
+	for i, term := range terms {
+		shared := 0
+		if i%termBlockSize != 0 {
+			shared = commonPrefix(prev, term)
+		}
+		buf = binary.AppendUvarint(buf, uint64(shared))
+		buf = binary.AppendUvarint(buf, uint64(len(term)-shared))
+		buf = append(buf, term[shared:]...)
+		buf = binary.AppendVarint(buf, int64(d[term]))
+		prev = term
+	}
+	return json.Marshal(base64.StdEncoding.EncodeToString(buf))
+}
+
+func (d *DocFreq) UnmarshalJSON(data []byte) error {
+	if len(data) == 0 || data[0] != '"' {
+		var plain map[string]int
+		if err := json.Unmarshal(data, &plain); err != nil {
+			return err
+		}
+		*d = plain
+		return nil
+	}
+	var encoded string
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	buf, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return err
+	}
+
+	dict := make(DocFreq)
+	term := make([]byte, 0, 32)
+	for len(buf) > 0 {
+		shared, n := binary.Uvarint(buf)
+		if n <= 0 || shared > uint64(len(term)) {
+			return errCorruptDictionary
+		}
+		buf = buf[n:]
+		length, n := binary.Uvarint(buf)
+		if n <= 0 || length > uint64(len(buf)-n) {
+			return errCorruptDictionary
+		}
+		buf = buf[n:]
+		term = append(term[:shared], buf[:length]...)
+		buf = buf[length:]
+		df, n := binary.Varint(buf)
+		if n <= 0 {
+			return errCorruptDictionary
+		}
+		buf = buf[n:]
+		dict[string(term)] = int(df)
+	}
+	*d = dict
+	return nil
+}
+
+func commonPrefix(a, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+// termList is the vocabulary of a loaded index in order, sorted when a
+// prefix is first expanded
+type termList struct {
+	once sync.Once
+	// version of the model it was loaded as, once changed it is out of date
+	version uint64
+	sorted  []string
+}
+
+// termsWithPrefix returns the terms starting with prefix, by binary search in
+// the sorted vocabulary of a loaded index, or by going through all of them
+// once it was changed
+func (m *Model) termsWithPrefix(prefix string) []string {
+	matches := make([]string, 0)
+	if m.terms == nil || m.terms.version != m.version {
+		for term, df := range m.DF {
+			if df > 0 && strings.HasPrefix(term, prefix) {
+				matches = append(matches, term)
+			}
+		}
+		sort.Strings(matches)
+		return matches
+	}
+
+	m.terms.once.Do(func() {
+		m.terms.sorted = make([]string, 0, len(m.DF))
+		for term := range m.DF {
+			m.terms.sorted = append(m.terms.sorted, term)
+		}
+		sort.Strings(m.terms.sorted)
+	})
+	sorted := m.terms.sorted
+	for i := sort.SearchStrings(sorted, prefix); i < len(sorted) && strings.HasPrefix(sorted[i], prefix); i++ {
+		if m.DF[sorted[i]] > 0 {
+			matches = append(matches, sorted[i])
+		}
+	}
+	return matches
+}
+
+// expandPrefixes returns the terms the prefixes of a query stand for, the
+// maxPrefixTerms in most documents for each. Prefixes are analyzed like the
+// rest of the query so they are in the case of the terms, but not stemmed.
+func (m *Model) expandPrefixes(prefixes []string) []string {
+	opts := m.Lexer.prefixOptions()
+	terms := make([]string, 0)
+	for _, prefix := range prefixes {
+		tokens := tokenize(prefix, opts)
+		if len(tokens) != 1 {
+			continue
+		}
+		matches := m.termsWithPrefix(tokens[0])
+		if len(matches) > maxPrefixTerms {
+			sort.SliceStable(matches, func(i, j int) bool {
+				return m.DF[matches[i]] > m.DF[matches[j]]
+			})
+			matches = matches[:maxPrefixTerms]
+		}
+		terms = append(terms, matches...)
+	}
+	return terms
+}
+
+// filters that only change how the characters of a term are written, which
+// prefixes go through too
+var prefixFilters = map[string]bool{"lowercase": true, "uppercase": true, "ascii-fold": true, "translit": true}
+
+// prefixOptions analyze the prefixes of queries: they aren't whole words, so
+// the built-in filters stemming, dropping or replacing words leave them alone
+func (o LexerOptions) prefixOptions() LexerOptions {
+	keep := func(filters []string) []string {
+		kept := make([]string, 0, len(filters))
+		for _, filter := range filters {
+			name, _, _ := strings.Cut(filter, ":")
+			if _, builtin := builtinFilters[name]; !builtin || prefixFilters[name] {
+				kept = append(kept, filter)
+			}
+		}
+		return kept
+	}
+	o.Filters = keep(o.langFilters())
+	o.Lang = ""
+	if o.TypeFilters != nil {
+		typeFilters := make(map[TokenType][]string, len(o.TypeFilters))
+		for typ, filters := range o.TypeFilters {
+			typeFilters[typ] = keep(filters)
+		}
+		o.TypeFilters = typeFilters
+	}
+	return o
+}
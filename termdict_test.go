@@ -0,0 +1,142 @@
+package sego
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestDocFreqRoundTrip(t *testing.T) {
+	big := make(DocFreq)
+	for i := 0; i < 3*termBlockSize+5; i++ {
+		big[fmt.Sprintf("SHADER%03d", i)] = i + 1
+	}
+	dicts := []DocFreq{
+		{},
+		{"A": 1},
+		{"SHADE": 2, "SHADER": 3, "SHADOW": 1, "ZEBRA": 0, "ÜBER": 4},
+		// a term that is a prefix of the next and terms sharing nothing
+		{"": 1, "X": 2, "XY": 3, "Y": 4},
+		big,
+	}
+	for _, dict := range dicts {
+		data, err := json.Marshal(dict)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got DocFreq
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, dict) {
+			t.Errorf("round trip of %d terms differs", len(dict))
+		}
+	}
+}
+
+func TestDocFreqPlainJSON(t *testing.T) {
+	var got DocFreq
+	if err := json.Unmarshal([]byte(`{"SHADER": 2}`), &got); err != nil {
+		t.Fatal(err)
+	}
+	if want := (DocFreq{"SHADER": 2}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestDocFreqCorrupt(t *testing.T) {
+	// shared prefix, length of the rest, the rest and the document frequency
+	entry := func(shared, length uint64, rest string, df int64) []byte {
+		buf := binary.AppendUvarint(nil, shared)
+		buf = binary.AppendUvarint(buf, length)
+		buf = append(buf, rest...)
+		return binary.AppendVarint(buf, df)
+	}
+	payloads := map[string][]byte{
+		"shares more than the term before": entry(2, 1, "a", 1),
+		"longer than the payload":          entry(0, 10, "abc", 1),
+		"missing frequency":                entry(0, 3, "abc", 1)[:4],
+		"truncated varint":                 {0x80},
+		"second shares too much":           append(entry(0, 1, "a", 1), entry(3, 1, "b", 1)...),
+	}
+	for name, payload := range payloads {
+		data, _ := json.Marshal(base64.StdEncoding.EncodeToString(payload))
+		var dict DocFreq
+		if err := json.Unmarshal(data, &dict); err == nil {
+			t.Errorf("%s: read as %v", name, dict)
+		}
+	}
+	data, _ := json.Marshal("not base64!")
+	var dict DocFreq
+	if err := json.Unmarshal(data, &dict); err == nil {
+		t.Error("invalid base64 read")
+	}
+}
+
+func TestTermsWithPrefix(t *testing.T) {
+	m := newModel()
+	m.DF = DocFreq{"SHADE": 1, "SHADER": 3, "SHADOW": 2, "SHAPE": 1, "GONE": 0, "SHA": 0}
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := parseModel(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, model := range []*Model{m, loaded} {
+		tests := []struct {
+			prefix string
+			want   []string
+		}{
+			{"SHAD", []string{"SHADE", "SHADER", "SHADOW"}},
+			{"SHA", []string{"SHADE", "SHADER", "SHADOW", "SHAPE"}},
+			{"SHADER", []string{"SHADER"}},
+			{"GO", []string{}},
+			{"X", []string{}},
+		}
+		for _, test := range tests {
+			if got := model.termsWithPrefix(test.prefix); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("%s expands to %v, want %v", test.prefix, got, test.want)
+			}
+		}
+	}
+}
+
+func TestExpandPrefixesUnstemmed(t *testing.T) {
+	m := newModel()
+	m.Lexer.Filters = []string{"lowercase", "stem", "length:5"}
+	m.DF = DocFreq{"generator": 1, "generated": 2, "general": 3, "gen": 1}
+	got := m.expandPrefixes([]string{"Generat", "gen"})
+	sort.Strings(got[:2])
+	if want := []string{"generated", "generator", "gen", "general", "generated", "generator"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expanded to %v, want %v", got, want)
+	}
+}
+
+func TestFilterPrefix(t *testing.T) {
+	m := newModel()
+	config := newConfig()
+	for path, tags := range map[string][]string{
+		"a.txt": {"gl4", "shaders"},
+		"b.txt": {"gles"},
+		"c.txt": {"vulkan"},
+	} {
+		if err := m.apply(&IngestMessage{Path: path, Content: "shader", Meta: &Document{Tags: tags}}, config); err != nil {
+			t.Fatal(err)
+		}
+	}
+	results, _ := m.searchTimed("shader tag:gl*")
+	got := make([]string, 0)
+	for _, r := range results {
+		got = append(got, r.Path)
+	}
+	sort.Strings(got)
+	if want := []string{"a.txt", "b.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("tag:gl* found %v, want %v", got, want)
+	}
+}
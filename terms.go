@@ -0,0 +1,123 @@
+package sego
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type TermStats struct {
+	Term string `json:"term"`
+	// documents containing the term
+	DF int `json:"df"`
+	// occurrences over all documents
+	TF int `json:"tf"`
+}
+
+// termStats collects DF and total TF of every term of the documents that
+// weren't deleted
+func (m *Model) termStats() []TermStats {
+	byTerm := make(map[string]*TermStats)
+	for _, id := range m.docIDs() {
+		for term, n := range m.TF[id] {
+			s, ok := byTerm[term]
+			if !ok {
+				s = &TermStats{Term: term}
+				byTerm[term] = s
+			}
+			s.DF++
+			s.TF += n
+		}
+	}
+
+	result := make([]TermStats, 0, len(byTerm))
+	for _, s := range byTerm {
+		result = append(result, *s)
+	}
+	return result
+}
+
+func sortTermStats(terms []TermStats, by string) error {
+	var less func(a, b TermStats) bool
+	switch by {
+	case "df":
+		less = func(a, b TermStats) bool { return a.DF > b.DF }
+	case "tf":
+		less = func(a, b TermStats) bool { return a.TF > b.TF }
+	case "term":
+		less = func(a, b TermStats) bool { return false }
+	default:
+		return fmt.Errorf("can't sort by %q", by)
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if less(terms[i], terms[j]) {
+			return true
+		}
+		if less(terms[j], terms[i]) {
+			return false
+		}
+		return terms[i].Term < terms[j].Term
+	})
+	return nil
+}
+
+func writeTermsCSV(w io.Writer, terms []TermStats) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"term", "df", "tf"})
+	for _, t := range terms {
+		out.Write([]string{t.Term, strconv.Itoa(t.DF), strconv.Itoa(t.TF)})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func runTerms(args []string) {
+	fs := flag.NewFlagSet("terms", flag.ExitOnError)
+	indexPath := fs.String("index", "index-new.json", "index to read the vocabulary from")
+	minDF := fs.Int("min-df", 1, "leave out terms in fewer documents")
+	maxDF := fs.Int("max-df", 0, "leave out terms in more documents (0 means no limit)")
+	sortBy := fs.String("sort", "df", "sort by df, tf or term")
+	format := fs.String("format", "csv", "csv or json")
+	limit := fs.Int("n", 0, "only print the first n terms (0 means all)")
+	prefix := fs.String("prefix", "", "only print terms starting with this")
+	fs.Parse(args)
+
+	model, err := newModelFromJson(*indexPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	terms := make([]TermStats, 0)
+	for _, t := range model.termStats() {
+		if t.DF >= *minDF && (*maxDF == 0 || t.DF <= *maxDF) && strings.HasPrefix(t.Term, *prefix) {
+			terms = append(terms, t)
+		}
+	}
+	if err := sortTermStats(terms, *sortBy); err != nil {
+		log.Fatal(err)
+	}
+	if *limit > 0 && len(terms) > *limit {
+		terms = terms[:*limit]
+	}
+
+	switch *format {
+	case "csv":
+		err = writeTermsCSV(os.Stdout, terms)
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(terms)
+	default:
+		err = fmt.Errorf("unknown format %q", *format)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,83 @@
+package sego
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// TokenType is what kind of text a token is, analyzers can filter tokens by it
+type TokenType string
+
+const (
+	TokenWord   TokenType = "WORD"
+	TokenNumber TokenType = "NUMBER"
+	// characters that aren't letters or digits, like "." or "#"
+	TokenPunct TokenType = "PUNCT"
+	// identifiers like "GL_ARRAY_BUFFER", "glBindBuffer" or "vec3"
+	TokenCode TokenType = "CODE"
+	// URLs, email addresses and host names
+	TokenURL TokenType = "URL"
+)
+
+var tokenTypes = []TokenType{TokenWord, TokenNumber, TokenPunct, TokenCode, TokenURL}
+
+// numbers with a decimal point and thousands separators, like "-1,000.5"
+var numberRe = regexp.MustCompile(`^[+-]?(?:\p{Nd}{1,3}(?:,\p{Nd}{3})+|\p{Nd}+)(?:\.\p{Nd}+)?$`)
+
+// tokenType tells what token is from the text it was cut from, before its
+// case is changed
+func tokenType(token []rune) TokenType {
+	letters, digits, others := 0, 0, 0
+	camel := false
+	for i, r := range token {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsMark(r):
+			letters++
+			if i > 0 && unicode.IsUpper(r) && unicode.IsLower(token[i-1]) {
+				camel = true
+			}
+		case unicode.IsNumber(r):
+			digits++
+		default:
+			others++
+		}
+	}
+	s := string(token)
+	switch {
+	case letters == 0 && digits == 0:
+		return TokenPunct
+	case letters == 0 && (others == 0 || numberRe.MatchString(s)):
+		return TokenNumber
+	case strings.Contains(s, "://") || strings.Contains(s, "@") || strings.Contains(s, "."):
+		return TokenURL
+	case camel || strings.Contains(s, "_") || (letters > 0 && digits > 0):
+		return TokenCode
+	}
+	return TokenWord
+}
+
+func parseTokenType(s string) (TokenType, error) {
+	for _, t := range tokenTypes {
+		if strings.EqualFold(s, string(t)) {
+			return t, nil
+		}
+	}
+	return "", fmt.Errorf("unknown token type %q, use one of WORD, NUMBER, PUNCT, CODE or URL", s)
+}
+
+// dropTokens drops all tokens, for dropping a type of them like "PUNCT=drop"
+func dropTokens(tokens []string) []string {
+	return tokens[:0]
+}
+
+// filterTyped runs token, of type typ, through the filters for its type. The
+// keywords are left alone like by the other filters.
+func (o LexerOptions) filterTyped(token string, typ TokenType, keywords map[string]bool) []string {
+	filters := o.TypeFilters[typ]
+	if len(filters) == 0 || keywords[token] {
+		return []string{token}
+	}
+	return applyFilters([]string{token}, filters)
+}
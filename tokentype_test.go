@@ -0,0 +1,29 @@
+package sego
+
+import "testing"
+
+func TestTokenType(t *testing.T) {
+	tests := map[string]TokenType{
+		"shader":          TokenWord,
+		"Straße":          TokenWord,
+		"42":              TokenNumber,
+		"3.14":            TokenNumber,
+		"1,000":           TokenNumber,
+		"-1,000,000.25":   TokenNumber,
+		"١٢٣":             TokenNumber,
+		"192.168.0.1":     TokenURL,
+		"example.com":     TokenURL,
+		"me@example.com":  TokenURL,
+		"https://docs.gl": TokenURL,
+		"glBindBuffer":    TokenCode,
+		"GL_ARRAY_BUFFER": TokenCode,
+		"vec3":            TokenCode,
+		"#":               TokenPunct,
+		"...":             TokenPunct,
+	}
+	for token, want := range tests {
+		if got := tokenType([]rune(token)); got != want {
+			t.Errorf("%q is %s, want %s", token, got, want)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package sego
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tracer exports spans to an OpenTelemetry collector with OTLP over HTTP as
+// JSON. It is configured by the standard OTEL_EXPORTER_OTLP_ENDPOINT,
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT, OTEL_EXPORTER_OTLP_HEADERS and
+// OTEL_SERVICE_NAME variables, without an endpoint tracing is off.
+type tracer struct {
+	url     string
+	headers map[string]string
+	service string
+	client  *http.Client
+
+	mu      sync.Mutex
+	pending []*span
+}
+
+// spans are only recorded when an endpoint is configured, a nil tracer and
+// nil spans do nothing
+var tracing = newTracerFromEnv()
+
+func newTracerFromEnv() *tracer {
+	url := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if url == "" {
+		endpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+		if endpoint == "" {
+			return nil
+		}
+		url = strings.TrimSuffix(endpoint, "/") + "/v1/traces"
+	}
+	t := &tracer{
+		url:     url,
+		headers: make(map[string]string),
+		service: os.Getenv("OTEL_SERVICE_NAME"),
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+	if t.service == "" {
+		t.service = "sego"
+	}
+	for _, header := range strings.Split(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"), ",") {
+		if key, value, ok := strings.Cut(header, "="); ok {
+			t.headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	go func() {
+		for range time.Tick(5 * time.Second) {
+			t.flush()
+		}
+	}()
+	return t
+}
+
+type span struct {
+	tracer  *tracer
+	traceID [16]byte
+	id      [8]byte
+	parent  [8]byte
+	name    string
+	start   time.Time
+	end     time.Time
+	attrs   map[string]any
+	err     string
+}
+
+// start begins a span, a root one if parent is nil
+func (t *tracer) start(parent *span, name string) *span {
+	if t == nil {
+		return nil
+	}
+	s := &span{tracer: t, name: name, start: time.Now(), attrs: make(map[string]any)}
+	if parent != nil {
+		s.traceID = parent.traceID
+		s.parent = parent.id
+	} else {
+		rand.Read(s.traceID[:])
+	}
+	rand.Read(s.id[:])
+	return s
+}
+
+// startRemote begins a span continuing the trace of a W3C traceparent header
+// like "00-<trace id>-<parent id>-01", a root one if the header is invalid
+func (t *tracer) startRemote(traceparent, name string) *span {
+	s := t.start(nil, name)
+	if s == nil {
+		return nil
+	}
+	parts := strings.Split(traceparent, "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return s
+	}
+	traceID, err1 := hex.DecodeString(parts[1])
+	parentID, err2 := hex.DecodeString(parts[2])
+	if err1 == nil && err2 == nil {
+		copy(s.traceID[:], traceID)
+		copy(s.parent[:], parentID)
+	}
+	return s
+}
+
+func (s *span) child(name string) *span {
+	if s == nil {
+		return nil
+	}
+	return s.tracer.start(s, name)
+}
+
+// phase records a finished child span of d starting at start and returns
+// when it ended, for phases that were timed already
+func (s *span) phase(name string, start time.Time, d time.Duration) time.Time {
+	end := start.Add(d)
+	if c := s.child(name); c != nil {
+		c.start = start
+		c.finishAt(end)
+	}
+	return end
+}
+
+func (s *span) set(key string, value any) {
+	if s != nil {
+		s.attrs[key] = value
+	}
+}
+
+func (s *span) fail(err error) {
+	if s != nil && err != nil {
+		s.err = err.Error()
+	}
+}
+
+func (s *span) finish() {
+	if s != nil {
+		s.finishAt(time.Now())
+	}
+}
+
+func (s *span) finishAt(end time.Time) {
+	s.end = end
+	t := s.tracer
+	t.mu.Lock()
+	t.pending = append(t.pending, s)
+	full := len(t.pending) >= 512
+	t.mu.Unlock()
+	if full {
+		go t.flush()
+	}
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+func otlpAttributes(attrs map[string]any) []otlpAttribute {
+	result := make([]otlpAttribute, 0, len(attrs))
+	for key, value := range attrs {
+		var v otlpValue
+		switch x := value.(type) {
+		case int:
+			n := strconv.Itoa(x)
+			v.IntValue = &n
+		case float64:
+			v.DoubleValue = &x
+		case bool:
+			v.BoolValue = &x
+		default:
+			s := fmt.Sprint(x)
+			v.StringValue = &s
+		}
+		result = append(result, otlpAttribute{Key: key, Value: v})
+	}
+	return result
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+func (s *span) otlp() otlpSpan {
+	o := otlpSpan{
+		TraceID:           hex.EncodeToString(s.traceID[:]),
+		SpanID:            hex.EncodeToString(s.id[:]),
+		Name:              s.name,
+		Kind:              1,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+		Attributes:        otlpAttributes(s.attrs),
+	}
+	if s.parent != [8]byte{} {
+		o.ParentSpanID = hex.EncodeToString(s.parent[:])
+	}
+	if s.err != "" {
+		// STATUS_CODE_ERROR
+		o.Status = otlpStatus{Code: 2, Message: s.err}
+	}
+	return o
+}
+
+// flush exports the finished spans, commands call it before exiting
+func (t *tracer) flush() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	spans := t.pending
+	t.pending = nil
+	t.mu.Unlock()
+	if len(spans) == 0 {
+		return
+	}
+
+	otlpSpans := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		otlpSpans = append(otlpSpans, s.otlp())
+	}
+	body := map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{
+				"attributes": otlpAttributes(map[string]any{"service.name": t.service}),
+			},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]string{"name": "sego"},
+				"spans": otlpSpans,
+			}},
+		}},
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		log.Printf("Exporting spans: %s", err)
+		return
+	}
+	req, err := http.NewRequest(http.MethodPost, t.url, bytes.NewReader(data))
+	if err != nil {
+		log.Printf("Exporting spans: %s", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range t.headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		log.Printf("Exporting spans: %s", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("Exporting spans to %s: %s", t.url, resp.Status)
+	}
+}
+
+// traceSearch adds a child span to s for each phase of a search that began at
+// start
+func traceSearch(s *span, query string, start time.Time, timing *SearchTiming, results int) {
+	if s == nil {
+		return
+	}
+	s.set("sego.query", query)
+	s.set("sego.results", results)
+	t := s.phase("tokenize", start, timing.Tokenize)
+	t = s.phase("candidates", t, timing.Candidates)
+	t = s.phase("score", t, timing.Score)
+	s.phase("sort", t, timing.Sort)
+}
@@ -0,0 +1,63 @@
+package sego
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// latinLetters spells Cyrillic and Greek letters in Latin the way they are
+// commonly transliterated, like "щ" as "shch" and "θ" as "th"
+var latinLetters = map[rune]string{
+	// Cyrillic, Russian first
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e",
+	'ж': "zh", 'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m",
+	'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+	'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+	'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+	'і': "i", 'ї': "yi", 'є': "ye", 'ґ': "g", 'ў': "u",
+	'ђ': "dj", 'ј': "j", 'љ': "lj", 'њ': "nj", 'ћ': "c", 'џ': "dz", 'ѓ': "gj", 'ќ': "kj", 'ѕ': "dz",
+	// Greek
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i",
+	'θ': "th", 'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x",
+	'ο': "o", 'π': "p", 'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y",
+	'φ': "f", 'χ': "ch", 'ψ': "ps", 'ω': "o",
+}
+
+// transliterate spells Cyrillic and Greek tokens in Latin letters whatever
+// their case, keeping it, "Щука" => "Shchuka", so queries in either script
+// match both
+func transliterate(token string) string {
+	var b strings.Builder
+	changed := false
+	for _, r := range norm.NFC.String(token) {
+		lower := unicode.ToLower(r)
+		s, ok := latinLetters[lower]
+		if !ok {
+			// accented letters like "ά" are spelled as the one without
+			if base := []rune(norm.NFD.String(string(lower))); len(base) > 1 && unicode.Is(unicode.Mn, base[1]) {
+				s, ok = latinLetters[base[0]]
+			}
+		}
+		switch {
+		case !ok:
+			b.WriteRune(r)
+			continue
+		case s != "" && unicode.IsUpper(r):
+			first := []rune(s)
+			first[0] = unicode.ToUpper(first[0])
+			s = string(first)
+		}
+		b.WriteString(s)
+		changed = true
+	}
+	if !changed {
+		return token
+	}
+	latin := b.String()
+	if token == strings.ToUpper(token) {
+		return strings.ToUpper(latin)
+	}
+	return latin
+}
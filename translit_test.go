@@ -0,0 +1,23 @@
+package sego
+
+import "testing"
+
+func TestTransliterate(t *testing.T) {
+	tests := map[string]string{
+		"москва":  "moskva",
+		"Москва":  "Moskva",
+		"МОСКВА":  "MOSKVA",
+		"Щука":    "Shchuka",
+		"iPhoneЖ": "iPhoneZh",
+		"Αθήνα":   "Athina",
+		"ΑΘΗΝΑ":   "ATHINA",
+		"shader":  "shader",
+		"Shader":  "Shader",
+		"объект":  "obekt",
+	}
+	for token, want := range tests {
+		if got := transliterate(token); got != want {
+			t.Errorf("%s transliterates to %s, want %s", token, got, want)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package sego
+
+import (
+	"math"
+	"sort"
+)
+
+// VectorIndex is an inverted file index over the document embeddings: every
+// vector is filed under its nearest centroid and a query only looks at the
+// vectors under the centroids nearest to it
+type VectorIndex struct {
+	Centroids [][]float32 `json:"centroids"`
+	Lists     [][]string  `json:"lists"`
+}
+
+// below this many vectors comparing the query with all of them is fast enough
+const minIndexedVectors = 1000
+
+// indexVectors (re)builds the vector index, or drops it when there are too
+// few vectors to need one
+func (m *Model) indexVectors() {
+	if len(m.Vectors) < minIndexedVectors || m.Embedding == nil {
+		m.VectorIndex = nil
+		return
+	}
+	k := m.Embedding.Lists
+	if k <= 0 {
+		k = int(math.Sqrt(float64(len(m.Vectors))))
+	}
+	m.VectorIndex = buildVectorIndex(m.Vectors, k)
+}
+
+// buildVectorIndex finds k centroids by spherical k-means. The ids are sorted
+// and the seeds spread evenly over them so the same vectors always give the
+// same index.
+func buildVectorIndex(vectors map[string][]float32, k int) *VectorIndex {
+	ids := make([]string, 0, len(vectors))
+	for id := range vectors {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	if k > len(ids) {
+		k = len(ids)
+	}
+
+	ix := &VectorIndex{Centroids: make([][]float32, k)}
+	for i := range ix.Centroids {
+		seed := vectors[ids[i*len(ids)/k]]
+		ix.Centroids[i] = append([]float32(nil), seed...)
+	}
+
+	assignment := make([]int, len(ids))
+	for iteration := 0; iteration < 10; iteration++ {
+		changed := false
+		for i, id := range ids {
+			if c := ix.closest(vectors[id]); c != assignment[i] {
+				assignment[i] = c
+				changed = true
+			}
+		}
+		if !changed && iteration > 0 {
+			break
+		}
+
+		sums := make([][]float64, k)
+		for i, id := range ids {
+			v := vectors[id]
+			c := assignment[i]
+			if sums[c] == nil {
+				sums[c] = make([]float64, len(v))
+			}
+			for j := range v {
+				if j < len(sums[c]) {
+					sums[c][j] += float64(v[j])
+				}
+			}
+		}
+		for c, sum := range sums {
+			// a centroid nothing was assigned to stays where it is
+			if sum == nil {
+				continue
+			}
+			centroid := make([]float32, len(sum))
+			for j := range sum {
+				centroid[j] = float32(sum[j])
+			}
+			normalizeVector(centroid)
+			ix.Centroids[c] = centroid
+		}
+	}
+
+	ix.Lists = make([][]string, k)
+	for i, id := range ids {
+		ix.Lists[assignment[i]] = append(ix.Lists[assignment[i]], id)
+	}
+	return ix
+}
+
+func (ix *VectorIndex) closest(v []float32) int {
+	best, bestSimilarity := 0, math.Inf(-1)
+	for i, c := range ix.Centroids {
+		if similarity := dotProduct(v, c); similarity > bestSimilarity {
+			best, bestSimilarity = i, similarity
+		}
+	}
+	return best
+}
+
+// nearest returns the indexes of the n centroids most similar to v
+func (ix *VectorIndex) nearest(v []float32, n int) []int {
+	order := make([]int, len(ix.Centroids))
+	similarity := make([]float64, len(ix.Centroids))
+	for i, c := range ix.Centroids {
+		order[i] = i
+		similarity[i] = dotProduct(v, c)
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return similarity[order[i]] > similarity[order[j]]
+	})
+	if n > len(order) {
+		n = len(order)
+	}
+	return order[:n]
+}
+
+// add files a vector added after the index was built
+func (ix *VectorIndex) add(id string, v []float32) {
+	if len(ix.Centroids) == 0 {
+		return
+	}
+	c := ix.closest(v)
+	ix.Lists[c] = append(ix.Lists[c], id)
+}
+
+// similarities compares v with the vectors filed under the probes centroids
+// nearest to it, by id. Ids may be listed twice or no longer have a vector
+// after a document changed.
+func (ix *VectorIndex) similarities(v []float32, probes int, vectors map[string][]float32) map[string]float64 {
+	result := make(map[string]float64)
+	for _, c := range ix.nearest(v, probes) {
+		for _, id := range ix.Lists[c] {
+			if _, done := result[id]; done {
+				continue
+			}
+			if vector, ok := vectors[id]; ok {
+				result[id] = dotProduct(v, vector)
+			}
+		}
+	}
+	return result
+}
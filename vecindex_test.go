@@ -0,0 +1,40 @@
+package sego
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestVectorIndexSimilarities(t *testing.T) {
+	vectors := make(map[string][]float32)
+	for i := 0; i < 20; i++ {
+		// two groups pointing along either axis
+		v := []float32{1, float32(i%10) / 100}
+		if i >= 10 {
+			v = []float32{float32(i%10) / 100, 1}
+		}
+		normalizeVector(v)
+		vectors[fmt.Sprint(i)] = v
+	}
+	ix := buildVectorIndex(vectors, 2)
+
+	query := []float32{1, 0}
+	similarities := ix.similarities(query, 1, vectors)
+	if len(similarities) != 10 {
+		t.Fatalf("compared %d vectors, want the 10 of the nearest list", len(similarities))
+	}
+	for i := 0; i < 10; i++ {
+		id := fmt.Sprint(i)
+		similarity, ok := similarities[id]
+		if !ok {
+			t.Errorf("%s wasn't compared", id)
+		} else if want := dotProduct(query, vectors[id]); similarity != want {
+			t.Errorf("%s: similarity %g, want %g", id, similarity, want)
+		}
+	}
+
+	delete(vectors, "0")
+	if _, ok := ix.similarities(query, 1, vectors)["0"]; ok {
+		t.Error("compared a vector that is gone")
+	}
+}
@@ -0,0 +1,221 @@
+package sego
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/bits"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// versions in folders like "docs.gl/gl3/" or "docs/v1.2/"
+const autoVersionPattern = `(?:^|/)([a-z]{0,3}\d+(?:\.\d+)*)/`
+
+// compileVersionPattern compiles a version pattern to match paths whatever
+// their case
+func compileVersionPattern(pattern string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		return nil, fmt.Errorf("version pattern %q: %w", pattern, err)
+	}
+	if re.NumSubexp() < 1 {
+		return nil, fmt.Errorf("version pattern %q has no group", pattern)
+	}
+	return re, nil
+}
+
+// setVersionPattern sets the version pattern of the index and compiles it.
+// It is only read afterwards, so searches can share it.
+func (m *Model) setVersionPattern(pattern string) error {
+	m.VersionPattern, m.versionRe = pattern, nil
+	if pattern == "" {
+		return nil
+	}
+	re, err := compileVersionPattern(pattern)
+	if err != nil {
+		return err
+	}
+	m.versionRe = re
+	return nil
+}
+
+// versionOf finds the version of the document at p with the index's version
+// pattern, "" if it has none
+func (m *Model) versionOf(p string) string {
+	if m.versionRe == nil {
+		return ""
+	}
+	match := m.versionRe.FindStringSubmatch(p)
+	if len(match) < 2 {
+		return ""
+	}
+	return strings.ToLower(match[1])
+}
+
+// splitVersion splits "gl3" into the family "gl" and its numbers [3],
+// versions of different families aren't compared
+func splitVersion(v string) (string, []int) {
+	i := strings.IndexFunc(v, func(r rune) bool { return r >= '0' && r <= '9' })
+	if i < 0 {
+		return v, nil
+	}
+	numbers := make([]int, 0)
+	for _, part := range strings.Split(v[i:], ".") {
+		n, _ := strconv.Atoi(part)
+		numbers = append(numbers, n)
+	}
+	return v[:i], numbers
+}
+
+// newerVersion reports whether a is a later version than b of the same family
+func newerVersion(a, b []int) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] > b[i]
+		}
+	}
+	return len(a) > len(b)
+}
+
+// latestVersions limits allowed to the newest version of each page in its
+// family, one only in older versions included, and documents without a
+// version, unless the query asks for a version with version:gl3 or
+// version:all
+func (m *Model) latestVersions(docs []string, filters []Filter, allowed bitmap) bitmap {
+	if m.VersionPattern == "" {
+		return allowed
+	}
+	for _, filter := range filters {
+		if filter.Field == "version" {
+			return allowed
+		}
+	}
+
+	// by family and the page's path without its version
+	pageKey := func(doc *Document) (string, []int) {
+		family, numbers := splitVersion(doc.Version)
+		return family + "\x00" + m.versionKey(doc.Path), numbers
+	}
+	newest := make(map[string][]int)
+	for _, id := range docs {
+		if doc, ok := m.Docs[id]; ok && doc.Version != "" {
+			key, numbers := pageKey(doc)
+			if n, ok := newest[key]; !ok || newerVersion(numbers, n) {
+				newest[key] = numbers
+			}
+		}
+	}
+	latest := newBitmap(len(docs))
+	for i, id := range docs {
+		doc, ok := m.Docs[id]
+		if !ok || doc.Version == "" {
+			latest.set(i)
+			continue
+		}
+		key, numbers := pageKey(doc)
+		if !newerVersion(newest[key], numbers) {
+			latest.set(i)
+		}
+	}
+	if allowed != nil {
+		latest.and(allowed)
+	}
+	return latest
+}
+
+// two versions of a page whose SimHashes differ in at most this many bits are
+// the same page
+const maxSimHashDistance = 3
+
+// simHash fingerprints a document by its terms so near-identical documents
+// get hashes differing in few bits
+func simHash(tf TermFreq) uint64 {
+	var weights [64]int
+	for term, n := range tf {
+		h := fnv.New64a()
+		h.Write([]byte(term))
+		bits := h.Sum64()
+		for i := range weights {
+			if bits&(1<<i) != 0 {
+				weights[i] += n
+			} else {
+				weights[i] -= n
+			}
+		}
+	}
+	var hash uint64
+	for i, w := range weights {
+		if w > 0 {
+			hash |= 1 << i
+		}
+	}
+	return hash
+}
+
+// versionKey is p with its version left out, the same page in other versions
+// has the same key
+func (m *Model) versionKey(p string) string {
+	if m.versionRe == nil {
+		return p
+	}
+	match := m.versionRe.FindStringSubmatchIndex(p)
+	if len(match) < 4 || match[2] < 0 {
+		return p
+	}
+	return p[:match[2]] + "*" + p[match[3]:]
+}
+
+// collapseVersions folds other versions of a page with near-identical content
+// into its best result, which lists the versions it is available in
+func (m *Model) collapseVersions(results SearchResults) SearchResults {
+	if m.VersionPattern == "" {
+		return results
+	}
+	byKey := make(map[string][]string)
+	for _, id := range m.docIDs() {
+		if doc, ok := m.Docs[id]; ok && doc.Version != "" && doc.SimHash != 0 {
+			key := m.versionKey(doc.Path)
+			byKey[key] = append(byKey[key], id)
+		}
+	}
+
+	collapsed := make(map[string]bool)
+	kept := make(SearchResults, 0, len(results))
+	for _, r := range results {
+		if collapsed[r.ID] {
+			continue
+		}
+		doc, ok := m.Docs[r.ID]
+		if !ok || doc.Version == "" || doc.SimHash == 0 {
+			kept = append(kept, r)
+			continue
+		}
+		versions := []string{doc.Version}
+		for _, other := range byKey[m.versionKey(doc.Path)] {
+			if o := m.Docs[other]; other != r.ID && bits.OnesCount64(o.SimHash^doc.SimHash) <= maxSimHashDistance {
+				versions = append(versions, o.Version)
+				collapsed[other] = true
+			}
+		}
+		if len(versions) > 1 {
+			sortVersions(versions)
+			r.Versions = versions
+		}
+		kept = append(kept, r)
+	}
+	return kept
+}
+
+// sortVersions orders versions by family, newest first
+func sortVersions(versions []string) {
+	sort.Slice(versions, func(i, j int) bool {
+		fi, ni := splitVersion(versions[i])
+		fj, nj := splitVersion(versions[j])
+		if fi != fj {
+			return fi < fj
+		}
+		return newerVersion(ni, nj)
+	})
+}
@@ -0,0 +1,79 @@
+package sego
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestVersionKey(t *testing.T) {
+	m := newModel()
+	if err := m.setVersionPattern(autoVersionPattern); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path, version, key string
+	}{
+		{"docs.gl/gl3/glClear.xhtml", "gl3", "docs.gl/*/glClear.xhtml"},
+		{"docs.gl/GL4/glClear.xhtml", "gl4", "docs.gl/*/glClear.xhtml"},
+		// lowercasing İ takes more bytes, the key is cut from the path as is
+		{"İstanbul/v1.2/Guide.md", "v1.2", "İstanbul/*/Guide.md"},
+		{"notes/readme.md", "", "notes/readme.md"},
+	}
+	for _, test := range tests {
+		if got := m.versionOf(test.path); got != test.version {
+			t.Errorf("versionOf(%q) = %q, want %q", test.path, got, test.version)
+		}
+		if got := m.versionKey(test.path); got != test.key {
+			t.Errorf("versionKey(%q) = %q, want %q", test.path, got, test.key)
+		}
+	}
+}
+
+func TestInvalidVersionPattern(t *testing.T) {
+	if err := newModel().setVersionPattern("("); err == nil {
+		t.Error("invalid pattern accepted")
+	}
+	if err := newModel().setVersionPattern("v[0-9]+"); err == nil {
+		t.Error("pattern without a group accepted")
+	}
+	if _, err := parseModel([]byte(`{"version_pattern": "("}`)); err == nil {
+		t.Error("index with an invalid pattern loaded")
+	}
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"version_pattern": "("}`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfig(path); err == nil {
+		t.Error("config with an invalid pattern loaded")
+	}
+}
+
+func TestLatestVersionsPerPage(t *testing.T) {
+	config := newConfig()
+	m := newModel()
+	if err := m.setVersionPattern(autoVersionPattern); err != nil {
+		t.Fatal(err)
+	}
+	for path, content := range map[string]string{
+		"docs/v2/setup.md":   "shader setup for version two",
+		"docs/v3/setup.md":   "shader setup rewritten for three",
+		"docs/v2/removed.md": "shader page dropped in three",
+		"notes.md":           "shader notes",
+	} {
+		if err := m.apply(&IngestMessage{Path: path, Content: content}, config); err != nil {
+			t.Fatal(err)
+		}
+	}
+	results, _ := m.searchTimed("shader")
+	got := make([]string, 0)
+	for _, r := range results {
+		got = append(got, r.Path)
+	}
+	sort.Strings(got)
+	if want := []string{"docs/v2/removed.md", "docs/v3/setup.md", "notes.md"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,77 @@
+package sego
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+)
+
+// wal is a write-ahead log of the changes made to a live index since it was
+// last saved, one JSON encoded IngestMessage per line
+type wal struct {
+	file *os.File
+}
+
+func openWAL(path string) (*wal, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, err
+	}
+	return &wal{file: file}, nil
+}
+
+// append makes msg durable before it is applied
+func (w *wal) append(msg *IngestMessage) error {
+	line, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if _, err := w.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return w.file.Sync()
+}
+
+// reset empties the log once the index it applies to was saved
+func (w *wal) reset() error {
+	if err := w.file.Truncate(0); err != nil {
+		return err
+	}
+	return w.file.Sync()
+}
+
+func (w *wal) Close() error {
+	return w.file.Close()
+}
+
+// replayWAL applies the changes logged at path and returns how many there
+// were. A torn last line from a crash mid-write is ignored.
+func replayWAL(path string, apply func(*IngestMessage) error) (int, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for scanner.Scan() {
+		var msg IngestMessage
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			log.Printf("Replaying %s: skipping entry %d: %s", path, n+1, err)
+			continue
+		}
+		if err := apply(&msg); err != nil {
+			log.Printf("Replaying %s: %s", path, err)
+		}
+		n++
+	}
+	return n, scanner.Err()
+}
@@ -0,0 +1,121 @@
+package sego
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"strings"
+)
+
+// walker collects the files below a directory. Files reachable through
+// several paths (hard links, symlinks) are only collected once.
+type walker struct {
+	followSymlinks bool
+	// path.Match patterns for names or paths relative to the root
+	exclude []string
+	root    string
+	// honor .gitignore files and leave out .git directories
+	gitignore bool
+	// file/dir identity => first path it was seen at
+	seen    map[string]string
+	paths   []string
+	skipped map[string]string
+}
+
+func newWalker(root string, config *Config) *walker {
+	return &walker{
+		followSymlinks: config.FollowSymlinks,
+		exclude:        config.Exclude,
+		root:           root,
+		gitignore:      config.Gitignore,
+		seen:           make(map[string]string),
+		paths:          make([]string, 0),
+		skipped:        make(map[string]string),
+	}
+}
+
+// visit reports whether the file or directory at p hasn't been seen before
+func (w *walker) visit(p string, info os.FileInfo) bool {
+	key := fileKey(p, info)
+	if first, ok := w.seen[key]; ok {
+		w.skipped[p] = fmt.Sprintf("same file as %s", first)
+		return false
+	}
+	w.seen[key] = p
+	return true
+}
+
+func (w *walker) excluded(p string) (string, bool) {
+	rel := strings.TrimPrefix(strings.TrimPrefix(p, w.root), "/")
+	for _, pattern := range w.exclude {
+		if ok, _ := path.Match(pattern, path.Base(p)); ok {
+			return pattern, true
+		}
+		if ok, _ := path.Match(pattern, rel); ok {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// walk collects the files below dirPath, rules are the .gitignore rules of
+// the directories above
+func (w *walker) walk(dirPath string, rules []ignoreRule) error {
+	dirContent, err := os.ReadDir(dirPath)
+	if err != nil {
+		return err
+	}
+	if w.gitignore {
+		rules = append(rules[:len(rules):len(rules)], readIgnoreFile(dirPath)...)
+	}
+
+	for _, entry := range dirContent {
+		p := path.Join(dirPath, entry.Name())
+		if pattern, ok := w.excluded(p); ok {
+			w.skipped[p] = fmt.Sprintf("excluded by %s", pattern)
+			continue
+		}
+
+		// os.Stat follows symlinks
+		info, err := os.Stat(p)
+		if err != nil {
+			log.Printf("Skipping: %s (%s)", p, err)
+			continue
+		}
+
+		if w.gitignore && ((info.IsDir() && entry.Name() == ".git") || ignored(rules, p, info.IsDir())) {
+			w.skipped[p] = "ignored by git"
+			continue
+		}
+
+		if entry.Type()&os.ModeSymlink != 0 && info.IsDir() && !w.followSymlinks {
+			w.skipped[p] = "symlinked directory"
+			continue
+		}
+
+		if !w.visit(p, info) {
+			continue
+		}
+
+		if info.IsDir() {
+			if err := w.walk(p, rules); err != nil {
+				return err
+			}
+		} else if info.Mode().IsRegular() {
+			w.paths = append(w.paths, p)
+		}
+	}
+
+	return nil
+}
+
+// isBinary guesses whether content is binary by looking for NUL bytes at the
+// start, the same heuristic git and grep use
+func isBinary(content []byte) bool {
+	if len(content) > 8000 {
+		content = content[:8000]
+	}
+	return bytes.IndexByte(content, 0) >= 0
+}
@@ -0,0 +1,166 @@
+package sego
+
+import (
+	"sync"
+	"unicode"
+)
+
+// scripts written without spaces between words that aren't CJK, their runs
+// are broken into words rather than indexed whole
+var spacelessScripts = []*unicode.RangeTable{unicode.Thai, unicode.Lao, unicode.Khmer, unicode.Myanmar}
+
+func isSpaceless(r rune) bool {
+	return unicode.In(r, spacelessScripts...) && (unicode.IsLetter(r) || unicode.IsMark(r))
+}
+
+// dictionary is a word list to break spaceless scripts into words with
+type dictionary struct {
+	words map[string]bool
+	// longest word in runes
+	longest int
+}
+
+// the dictionaries loaded so far by path
+var dictionaries sync.Map
+
+func loadDictionary(path string) (*dictionary, error) {
+	if dict, ok := dictionaries.Load(path); ok {
+		return dict.(*dictionary), nil
+	}
+	words, err := readWordList(path)
+	if err != nil {
+		return nil, err
+	}
+	dict := &dictionary{words: make(map[string]bool, len(words))}
+	for _, word := range words {
+		dict.words[word] = true
+		if n := len([]rune(word)); n > dict.longest {
+			dict.longest = n
+		}
+	}
+	dictionaries.Store(path, dict)
+	return dict, nil
+}
+
+// clusters splits text into letters with the marks following them, and
+// leading vowels like Thai "เ" with the consonant they are written before,
+// so no word boundary falls inside one
+func clusters(text []rune) [][]rune {
+	result := make([][]rune, 0, len(text))
+	start := 0
+	for i := 1; i <= len(text); i++ {
+		if i < len(text) && (unicode.IsMark(text[i]) || isLeadingVowel(text[i-1])) {
+			continue
+		}
+		result = append(result, text[start:i])
+		start = i
+	}
+	return result
+}
+
+// isLeadingVowel reports whether r is a Thai or Lao vowel written before the
+// consonant it follows in speech
+func isLeadingVowel(r rune) bool {
+	return (r >= 'เ' && r <= 'ไ') || (r >= 'ເ' && r <= 'ໄ')
+}
+
+// breakWords splits a run of a spaceless script into words. With a
+// dictionary it picks the split into the fewest words leaving the least text
+// unknown. Text not in it, or all without one, becomes overlapping pairs of
+// clusters, like CJK bigrams, so queries still match within words.
+func breakWords(text []rune, dict *dictionary) [][]rune {
+	parts := clusters(text)
+	if dict == nil {
+		return clusterPairs(text, parts)
+	}
+
+	// offsets[i] is where cluster i starts in text
+	offsets := make([]int, len(parts)+1)
+	for i, part := range parts {
+		offsets[i+1] = offsets[i] + len(part)
+	}
+	type split struct {
+		unknown, words int
+		// start of the last word and whether it is known
+		from  int
+		known bool
+	}
+	best := make([]split, len(parts)+1)
+	for end := 1; end <= len(parts); end++ {
+		best[end] = split{unknown: -1}
+		for start := end - 1; start >= 0 && offsets[end]-offsets[start] <= dict.longest; start-- {
+			if !dict.words[string(text[offsets[start]:offsets[end]])] {
+				continue
+			}
+			candidate := split{best[start].unknown, best[start].words + 1, start, true}
+			if better(candidate.unknown, candidate.words, best[end].unknown, best[end].words) {
+				best[end] = candidate
+			}
+		}
+		// or the cluster is unknown, joining unknown clusters before it
+		prev := best[end-1]
+		candidate := split{prev.unknown + 1, prev.words + 1, end - 1, false}
+		if end > 1 && !prev.known {
+			candidate.words, candidate.from = prev.words, prev.from
+		}
+		if better(candidate.unknown, candidate.words, best[end].unknown, best[end].words) {
+			best[end] = candidate
+		}
+	}
+
+	// the ends of the words, last first
+	ends := make([]int, 0, best[len(parts)].words)
+	for end := len(parts); end > 0; end = best[end].from {
+		ends = append(ends, end)
+	}
+	words := make([][]rune, 0, len(ends))
+	for i := len(ends) - 1; i >= 0; i-- {
+		end := ends[i]
+		from := best[end].from
+		word := text[offsets[from]:offsets[end]]
+		if best[end].known {
+			words = append(words, word)
+		} else {
+			words = append(words, clusterPairs(word, parts[from:end])...)
+		}
+	}
+	return words
+}
+
+// clusterPairs returns the overlapping pairs of the clusters parts of text
+func clusterPairs(text []rune, parts [][]rune) [][]rune {
+	if len(parts) == 1 {
+		return [][]rune{text}
+	}
+	pairs := make([][]rune, 0, len(parts)-1)
+	offset := 0
+	for i := 0; i+1 < len(parts); i++ {
+		pairs = append(pairs, text[offset:offset+len(parts[i])+len(parts[i+1])])
+		offset += len(parts[i])
+	}
+	return pairs
+}
+
+// better prefers less unknown text, then fewer words, unknown < 0 means none
+func better(unknown, words, thanUnknown, thanWords int) bool {
+	if thanUnknown < 0 {
+		return true
+	}
+	if unknown != thanUnknown {
+		return unknown < thanUnknown
+	}
+	return words < thanWords
+}
+
+// chopSpaceless chops a run of a spaceless script, returning its first word
+// and queuing the others
+func (l *lexer) chopSpaceless() []rune {
+	var dict *dictionary
+	if l.opts.Dictionary != "" {
+		// checked when the analyzer was set up
+		dict, _ = loadDictionary(l.opts.Dictionary)
+	}
+	words := breakWords(l.chopWhile(isSpaceless), dict)
+	l.pending = append(l.pending, words[1:]...)
+	return words[0]
+}
@@ -0,0 +1,47 @@
+package sego
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDictionaryPathAbsolute(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "words.txt"), []byte("สวัสดี\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	config := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(config, []byte(`{"analyzers": {"thai": {"dictionary": "words.txt"}}}`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	var opts LexerOptions
+	fs := flag.NewFlagSet("index", flag.ContinueOnError)
+	opts.registerFlags(fs)
+	if err := fs.Parse([]string{"-dictionary", "words.txt"}); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// found again from elsewhere
+	if err := os.Chdir(wd); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{opts.Dictionary, loaded.Analyzers["thai"].Dictionary} {
+		if _, err := os.Stat(path); err != nil || !filepath.IsAbs(path) {
+			t.Errorf("dictionary stored as %q", path)
+		}
+	}
+}